@@ -0,0 +1,134 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"ncdtree/pkg/ncd"
+	"ncdtree/pkg/phylocore"
+	"ncdtree/pkg/sysexits"
+	"os"
+	"slices"
+	"strings"
+
+	"github.com/akamensky/argparse"
+)
+
+// Read a distance matrix file in one of the formats read by nj
+func readMatrix(path string) ([]string, *ncd.TriangularMatrix) {
+	file, err := os.Open(path)
+	if err != nil {
+		sysexits.Exit(err, sysexits.NoInput)
+	}
+	defer file.Close()
+
+	scanner := bufio.NewScanner(file)
+	scanner.Buffer(nil, 1<<28) // Rows of large matrices are long
+	taxa, D, err := phylocore.ReadDistanceMatrix(scanner)
+	if err != nil {
+		sysexits.ExitMsg(path+": "+err.Error(), sysexits.InputCode(err))
+	}
+
+	return taxa.Names, D
+}
+
+// Print the taxa of a matrix that are missing from the other one
+func printMissing(path string, names []string, common []string) {
+	isCommon := make(map[string]bool, len(common))
+	for _, name := range common {
+		isCommon[name] = true
+	}
+
+	missing := make([]string, 0)
+	for _, name := range names {
+		if !isCommon[name] {
+			missing = append(missing, name)
+		}
+	}
+	if len(missing) == 0 {
+		return
+	}
+
+	fmt.Printf("\nOnly in %s:\n", path)
+	for _, name := range missing {
+		fmt.Println("  " + name)
+	}
+}
+
+func main() {
+	parser := argparse.NewParser(
+		"compare-matrices",
+		"Compare two distance matrices of the same taxa with correlation coefficients and Mantel tests. The matrix files, "+
+			"in the formats read by nj, are given before the options, as in \"compare-matrices MATRIX_A MATRIX_B [options]\"",
+	)
+	argPermutations := parser.Int(
+		"", "permutations",
+		&argparse.Options{Required: false, Default: 999, Help: "Number of permutations of the Mantel tests (no test if 0)"},
+	)
+	argSeed := parser.Int(
+		"", "seed",
+		&argparse.Options{Required: false, Default: 1, Help: "Seed for the permutations of the Mantel tests"},
+	)
+
+	// The matrix files are taken before parsing, as argparse would list positional arguments among the options
+	args := slices.Clone(os.Args)
+	files := make([]string, 0, 2)
+	for len(files) < 2 && len(args) > 1 && !strings.HasPrefix(args[1], "-") {
+		files = append(files, args[1])
+		args = slices.Delete(args, 1, 2)
+	}
+
+	err := parser.Parse(args)
+	if err != nil {
+		sysexits.ExitMsg(parser.Usage(err), sysexits.Usage)
+	}
+	if len(files) < 2 {
+		sysexits.ExitMsg(parser.Usage("two matrix files are needed"), sysexits.Usage)
+	}
+	fileA, fileB := files[0], files[1]
+	if *argPermutations < 0 {
+		sysexits.ExitMsg("--permutations must not be negative", sysexits.Usage)
+	}
+
+	namesA, A := readMatrix(fileA)
+	namesB, B := readMatrix(fileB)
+
+	common, A, B, err := ncd.AlignMatrices(namesA, A, namesB, B)
+	if err != nil {
+		sysexits.Exit(err, sysexits.DataErr)
+	}
+
+	fmt.Printf("%s: %d taxa\n", fileA, len(namesA))
+	fmt.Printf("%s: %d taxa\n", fileB, len(namesB))
+	fmt.Printf("Common taxa: %d (%d distances)\n", len(common), len(A.RawData))
+	printMissing(fileA, namesA, common)
+	printMissing(fileB, namesB, common)
+
+	pearson, err := ncd.MatrixCorrPearson(A, B)
+	if err != nil {
+		sysexits.Exit(err, sysexits.Software)
+	}
+	spearman, err := ncd.MatrixCorrSpearman(A, B)
+	if err != nil {
+		sysexits.Exit(err, sysexits.Software)
+	}
+
+	fmt.Println("\nCORRELATION")
+	fmt.Println("===========")
+	fmt.Printf("Pearson   r    %.6f\n", pearson)
+	fmt.Printf("Spearman  rho  %.6f\n", spearman)
+
+	if *argPermutations == 0 {
+		return
+	}
+
+	fmt.Println("\nMANTEL TEST")
+	fmt.Println("===========")
+	fmt.Printf("%d permutations, seed %d, one-sided p-values\n", *argPermutations, *argSeed)
+	for _, method := range []string{"Pearson", "Spearman"} {
+		res, err := ncd.MantelTest(A, B, *argPermutations, uint64(*argSeed), method == "Spearman")
+		if err != nil {
+			sysexits.Exit(err, sysexits.DataErr)
+		}
+		fmt.Printf("%-8s  r = %.6f  p = %.4g\n", method, res.R, res.P)
+	}
+}
@@ -0,0 +1,87 @@
+package main
+
+import (
+	"fmt"
+	"ncdtree/pkg/ncd"
+	"ncdtree/pkg/sysexits"
+	"os"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/akamensky/argparse"
+)
+
+func main() {
+	parser := argparse.NewParser(
+		"ncdcache",
+		"Inspect and prune the cache files of compressed sizes written by ncdtree --size-cache",
+	)
+
+	infoCmd := parser.NewCommand(
+		"info",
+		"Print the number of records of a cache file and the dates of the oldest and newest ones, as in \"ncdcache info FILE\"",
+	)
+
+	pruneCmd := parser.NewCommand(
+		"prune",
+		"Remove old and duplicate records from a cache file, which must not be in use, as in \"ncdcache prune FILE [options]\"",
+	)
+	argOlderThan := pruneCmd.Int(
+		"", "older-than",
+		&argparse.Options{Required: false, Default: 0, Help: "Remove the records added more than this number of days ago (only duplicates are removed if 0)"},
+	)
+
+	// The cache file is taken before parsing, as argparse would list a positional argument among the options
+	args := os.Args
+	cacheFile := ""
+	if len(args) > 2 && !strings.HasPrefix(args[1], "-") && !strings.HasPrefix(args[2], "-") {
+		cacheFile = args[2]
+		args = slices.Delete(slices.Clone(args), 2, 3)
+	}
+
+	if err := parser.Parse(args); err != nil {
+		sysexits.ExitMsg(parser.Usage(err), sysexits.Usage)
+	}
+	for _, cmd := range []*argparse.Command{infoCmd, pruneCmd} {
+		if cmd.Happened() && cacheFile == "" {
+			sysexits.ExitMsg(cmd.Usage("a cache file is needed"), sysexits.Usage)
+		}
+	}
+
+	switch {
+	case infoCmd.Happened():
+		file, err := os.Open(cacheFile)
+		if err != nil {
+			sysexits.Exit(err, sysexits.NoInput)
+		}
+		records, _, err := ncd.ReadSizeCache(file)
+		file.Close()
+		if err != nil {
+			sysexits.ExitMsg(cacheFile+": "+err.Error(), sysexits.DataErr)
+		}
+
+		keys := make(map[ncd.SizeKey]bool, len(records))
+		for _, r := range records {
+			keys[r.Key] = true
+		}
+		fmt.Printf("Records     %d\n", len(records))
+		fmt.Printf("Duplicates  %d\n", len(records)-len(keys))
+		if len(records) > 0 {
+			fmt.Printf("Oldest      %s\n", records[0].Added.Format(time.DateTime))
+			fmt.Printf("Newest      %s\n", records[len(records)-1].Added.Format(time.DateTime))
+		}
+	case pruneCmd.Happened():
+		if _, err := os.Stat(cacheFile); err != nil {
+			sysexits.Exit(err, sysexits.NoInput)
+		}
+		limit := time.Now().AddDate(0, 0, -*argOlderThan)
+		kept, removed, err := ncd.PruneSizeCache(cacheFile, func(r ncd.SizeRecord) bool {
+			return *argOlderThan <= 0 || r.Added.After(limit)
+		})
+		if err != nil {
+			sysexits.Exit(err, sysexits.IOErr)
+		}
+		fmt.Printf("Kept %d records, removed %d\n", kept, removed)
+	}
+}
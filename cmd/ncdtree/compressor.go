@@ -0,0 +1,73 @@
+package main
+
+import (
+	"fmt"
+	"ncdtree/pkg/ncd"
+	"strings"
+
+	"github.com/google/brotli/go/cbrotli"
+)
+
+// Compressor chosen on the command line, with its settings
+type compressorConfig struct {
+	name     string   // As given to --compressor
+	settings []string // "key=value" pairs, in a fixed order
+	packed   bool     // Nucleotides are packed into 2 bits before compression
+}
+
+// Make the compressor of a --compressor name
+func newCompressor(name string, packed bool) (ncd.ManagedCompressor, compressorConfig) {
+	config := compressorConfig{name: name, packed: packed}
+
+	var mc ncd.ManagedCompressor
+	switch name {
+	case "Brotli":
+		opts := cbrotli.WriterOptions{
+			Quality: 11, // Compression level
+			LGWin:   0,  // Automatic window
+		}
+		mc = ncd.NewManagedCompressorBrotli(opts)
+		config.settings = []string{fmt.Sprintf("quality=%d", opts.Quality), fmt.Sprintf("lgwin=%d", opts.LGWin)}
+	case "Gzip":
+		mc = ncd.NewManagedCompressorGzip()
+		config.settings = []string{"level=default"}
+	case "Zstd":
+		mc = ncd.NewManagedCompressorZstd()
+		config.settings = []string{"level=best", "window=24"}
+	}
+	if packed {
+		mc = ncd.NewManagedCompressorPacked(mc)
+	}
+
+	return mc, config
+}
+
+// Name of the compressor for reports
+func (c compressorConfig) displayName() string {
+	if c.packed {
+		return c.name + " (2-bit packing)"
+	}
+
+	return c.name
+}
+
+// Identify the compressor and its settings in the size cache, e.g. "brotli/quality=11/lgwin=0"
+func (c compressorConfig) id() string {
+	id := strings.ToLower(c.name) + "/" + strings.Join(c.settings, "/")
+	if c.packed {
+		id = "pack2/" + id
+	}
+
+	return id
+}
+
+// Settings as a map of keys to values
+func (c compressorConfig) settingsMap() map[string]string {
+	m := make(map[string]string, len(c.settings))
+	for _, s := range c.settings {
+		key, value, _ := strings.Cut(s, "=")
+		m[key] = value
+	}
+
+	return m
+}
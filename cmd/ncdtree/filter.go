@@ -0,0 +1,105 @@
+package main
+
+import (
+	"crypto/sha256"
+	"fmt"
+	"ncdtree/pkg/fasta"
+	"ncdtree/pkg/ncd"
+)
+
+// Criteria for removing taxa before the distances are computed, and for collapsing identical sequences
+type sequenceFilter struct {
+	minLength    int     // Minimum sequence length (no minimum if not positive)
+	maxLength    int     // Maximum sequence length (no maximum if not positive)
+	maxAmbiguous float64 // Maximum fraction of ambiguous bases (no maximum if 1 or more)
+	dedup        bool    // Collapse identical sequences into their first occurrence
+}
+
+// Tell if the filter can remove or collapse any taxon
+func (f sequenceFilter) active() bool {
+	return f.minLength > 0 || f.maxLength > 0 || f.maxAmbiguous < 1.0 || f.dedup
+}
+
+type removedTaxon struct {
+	index  int
+	reason string
+}
+
+// Outcome of filtering, with taxa referred to by their index in the input
+type filterResult struct {
+	kept       []int          // Taxa kept for the distance computation, in input order
+	removed    []removedTaxon // Taxa removed by the length and ambiguity criteria
+	duplicates map[int][]int  // Representative -> taxa with the same sequence, collapsed into it
+}
+
+// Return the number of collapsed taxa
+func (res *filterResult) nbCollapsed() int {
+	n := 0
+	for _, dups := range res.duplicates {
+		n += len(dups)
+	}
+
+	return n
+}
+
+// Return the items at the given indices
+func selectItems[T any](items []T, indices []int) []T {
+	selected := make([]T, len(indices))
+	for k, i := range indices {
+		selected[k] = items[i]
+	}
+
+	return selected
+}
+
+// Return the reason to remove a sequence, or "" to keep it
+func (f sequenceFilter) rejects(seq []byte) string {
+	switch {
+	case f.minLength > 0 && len(seq) < f.minLength:
+		return fmt.Sprintf("shorter than %d", f.minLength)
+	case f.maxLength > 0 && len(seq) > f.maxLength:
+		return fmt.Sprintf("longer than %d", f.maxLength)
+	}
+	if f.maxAmbiguous < 1.0 {
+		if ambiguous := fasta.AmbiguousFraction(seq); ambiguous > f.maxAmbiguous {
+			return fmt.Sprintf("%.3g ambiguous bases", ambiguous)
+		}
+	}
+
+	return ""
+}
+
+/*
+Filter the sequences of a source, reading each sequence once.
+
+Identical sequences are recognized by their SHA-256 digests.
+*/
+func (f sequenceFilter) apply(source ncd.SequenceSource) (*filterResult, error) {
+	res := &filterResult{make([]int, 0, source.Len()), make([]removedTaxon, 0), make(map[int][]int)}
+	representatives := make(map[[sha256.Size]byte]int)
+
+	for i := range source.Len() {
+		seq, err := source.Sequence(i)
+		if err != nil {
+			return nil, err
+		}
+
+		if reason := f.rejects(seq); reason != "" {
+			res.removed = append(res.removed, removedTaxon{i, reason})
+			continue
+		}
+
+		if f.dedup {
+			digest := sha256.Sum256(seq)
+			if rep, ok := representatives[digest]; ok {
+				res.duplicates[rep] = append(res.duplicates[rep], i)
+				continue
+			}
+			representatives[digest] = i
+		}
+
+		res.kept = append(res.kept, i)
+	}
+
+	return res, nil
+}
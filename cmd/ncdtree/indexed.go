@@ -0,0 +1,113 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"ncdtree/pkg/fasta"
+	"os"
+)
+
+/*
+Sequences of several indexed FASTA files, read on demand, with one taxon per record.
+
+Implements the ncd.SequenceSource interface.
+*/
+type indexedInputs struct {
+	files   []*fasta.IndexedFasta
+	taxa    [][2]int // Taxon -> file and record in the file
+	names   []string
+	lengths []int
+}
+
+// Create the index of a FASTA file if it is missing or older than the file
+func ensureIndex(path string) error {
+	input, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer input.Close()
+
+	info, err := input.Stat()
+	if err != nil {
+		return err
+	}
+	compression, err := fasta.DetectCompression(bufio.NewReader(input))
+	if err != nil {
+		return err
+	}
+	if compression != fasta.Uncompressed {
+		return fmt.Errorf("indexed input \"%s\" must be uncompressed (found %s compression)", path, compression)
+	}
+
+	indexInfo, err := os.Stat(fasta.IndexPath(path))
+	if err == nil && !indexInfo.ModTime().Before(info.ModTime()) {
+		return nil
+	}
+
+	return fasta.CreateIndex(path)
+}
+
+/*
+Open uncompressed FASTA files for random access, creating their .fai indexes next to them when needed.
+*/
+func openIndexedInputs(paths []string) (*indexedInputs, error) {
+	inputs := &indexedInputs{}
+	origins := make(map[string]string)
+
+	for _, path := range paths {
+		if err := ensureIndex(path); err != nil {
+			inputs.Close()
+			return nil, err
+		}
+		file, err := fasta.OpenIndexedFasta(path)
+		if err != nil {
+			inputs.Close()
+			return nil, err
+		}
+		inputs.files = append(inputs.files, file)
+
+		for k, entry := range file.Entries {
+			if firstOrigin, ok := origins[entry.Name]; ok {
+				inputs.Close()
+				return nil, fmt.Errorf("duplicate taxon name \"%s\" in \"%s\" and \"%s\"", entry.Name, firstOrigin, path)
+			}
+			origins[entry.Name] = path
+			inputs.taxa = append(inputs.taxa, [2]int{len(inputs.files) - 1, k})
+			inputs.names = append(inputs.names, entry.Name)
+			inputs.lengths = append(inputs.lengths, entry.Length)
+		}
+	}
+
+	return inputs, nil
+}
+
+func (in *indexedInputs) Len() int {
+	return len(in.taxa)
+}
+
+func (in *indexedInputs) Sequence(i int) ([]byte, error) {
+	return in.files[in.taxa[i][0]].Sequence(in.taxa[i][1])
+}
+
+func (in *indexedInputs) Close() {
+	for _, file := range in.files {
+		file.Close()
+	}
+}
+
+// Write the sequences to a FASTA file, one at a time, under the given names
+func (in *indexedInputs) writeFasta(w io.Writer, names []string, lineWidth int) error {
+	writer := fasta.NewWriter(w, lineWidth)
+	for i, name := range names {
+		seq, err := in.Sequence(i)
+		if err != nil {
+			return err
+		}
+		if err := writer.Write(&fasta.Record{ID: name, Seq: seq}); err != nil {
+			return err
+		}
+	}
+
+	return writer.Flush()
+}
@@ -0,0 +1,303 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"ncdtree/pkg/fasta"
+	"ncdtree/pkg/phylocore"
+	"os"
+	"path/filepath"
+)
+
+const inputBufSize = 64 * 1024
+
+var errEmptyInput = errors.New("empty input")
+
+/*
+Replace directories in a list of input paths by the sequence files that they contain (non-recursively, in
+lexicographic order)
+*/
+func expandInputPaths(paths []string) ([]string, error) {
+	files := make([]string, 0, len(paths))
+
+	for _, path := range paths {
+		info, err := os.Stat(path)
+		if err != nil {
+			return nil, err
+		}
+		if !info.IsDir() {
+			files = append(files, path)
+			continue
+		}
+
+		entries, err := os.ReadDir(path)
+		if err != nil {
+			return nil, err
+		}
+		nbFound := 0
+		for _, entry := range entries {
+			if entry.IsDir() || !fasta.IsSequenceFileName(entry.Name()) {
+				continue
+			}
+			files = append(files, filepath.Join(path, entry.Name()))
+			nbFound += 1
+		}
+		if nbFound == 0 {
+			return nil, fmt.Errorf("no sequence files found in directory \"%s\"", path)
+		}
+	}
+
+	return files, nil
+}
+
+// Options on how taxa are made from the inputs
+type readOptions struct {
+	perFile      bool   // Make a taxon of each input rather than of each record
+	separator    string // Separator between the joined records, reads or features of a taxon
+	readFilter   fasta.ReadFilter
+	nameTemplate string                // Template of the names of GenBank/EMBL records
+	feature      fasta.FeatureSelector // Features extracted from GenBank/EMBL records (whole sequence if no key)
+}
+
+/*
+Collects the taxa read from the inputs, keeping track of where each taxon name was found to report duplicates.
+
+In per-file mode, each input makes up one taxon, and the sequences of its records are joined with the separator.
+Otherwise each record is a taxon. FASTQ inputs are always read in per-file mode, keeping the reads that pass the
+read filter.
+*/
+type taxonCollector struct {
+	records []*fasta.Record
+	origins map[string]string // Taxon name -> input where it was found
+	opts    readOptions
+}
+
+func newTaxonCollector(opts readOptions) *taxonCollector {
+	return &taxonCollector{make([]*fasta.Record, 0), make(map[string]string), opts}
+}
+
+func (tc *taxonCollector) add(rec *fasta.Record, origin string) error {
+	if firstOrigin, ok := tc.origins[rec.ID]; ok {
+		if firstOrigin == origin {
+			return fmt.Errorf("duplicate taxon name \"%s\" in \"%s\"", rec.ID, origin)
+		}
+		return fmt.Errorf("duplicate taxon name \"%s\" in \"%s\" and \"%s\"", rec.ID, firstOrigin, origin)
+	}
+	tc.origins[rec.ID] = origin
+	tc.records = append(tc.records, rec)
+
+	return nil
+}
+
+// Join the sequences of several records (e.g. the contigs of an assembly) into a single record
+func joinRecords(name string, records []*fasta.Record, separator string) *fasta.Record {
+	seqs := make([][]byte, len(records))
+	for i, rec := range records {
+		seqs[i] = rec.Seq
+	}
+
+	return &fasta.Record{ID: name, Seq: bytes.Join(seqs, []byte(separator))}
+}
+
+// Add the records of an input joined into a single taxon, which fails if they hold no sequence at all
+func (tc *taxonCollector) addJoined(name string, records []*fasta.Record, origin string) error {
+	length := 0
+	for _, rec := range records {
+		length += len(rec.Seq)
+	}
+	if length == 0 {
+		return fmt.Errorf("%s: no sequence for taxon \"%s\"", origin, name)
+	}
+
+	return tc.add(joinRecords(name, records, tc.opts.separator), origin)
+}
+
+/*
+Read the records of a FASTA, FASTQ, GenBank or EMBL stream that may be compressed.
+
+In per-file mode, and for FASTQ, the records are joined into a single taxon with the given name.
+*/
+func (tc *taxonCollector) readStream(input io.Reader, origin string, name string) error {
+	decompressed, _, err := fasta.NewDecompressingReader(input)
+	if err != nil {
+		return err
+	}
+	defer decompressed.Close()
+
+	buffered := bufio.NewReaderSize(decompressed, inputBufSize)
+
+	// FASTQ headers start with '@', where FASTA headers start with '>'
+	head, _ := buffered.Peek(5)
+	if len(head) > 0 && head[0] == '@' {
+		return tc.readFastq(buffered, origin, name)
+	}
+	if fasta.IsAnnotatedFormat(head) {
+		return tc.readAnnotated(buffered, origin, name)
+	}
+
+	reader := fasta.NewReader(buffered)
+	records := make([]*fasta.Record, 0)
+
+	for rec, err := range reader.Records() {
+		if err != nil {
+			return fmt.Errorf("%s: %w", origin, err)
+		}
+		if tc.opts.perFile {
+			records = append(records, rec)
+		} else if err := tc.add(rec, origin); err != nil {
+			return err
+		}
+	}
+
+	if tc.opts.perFile {
+		return tc.addJoined(name, records, origin)
+	}
+
+	return nil
+}
+
+// Read the reads of a FASTQ stream as a single taxon with the given name
+func (tc *taxonCollector) readFastq(input io.Reader, origin string, name string) error {
+	reader := fasta.NewFastqReader(input)
+	seq, stats, err := fasta.ConcatenateReads(reader, tc.opts.readFilter, []byte(tc.opts.separator))
+	if err != nil {
+		return fmt.Errorf("%s: %w", origin, err)
+	}
+	if stats.NbKeptReads == 0 {
+		return fmt.Errorf("%s: no reads passed the read filter", origin)
+	}
+
+	description := fmt.Sprintf("%d of %d reads", stats.NbKeptReads, stats.NbReads)
+
+	return tc.add(&fasta.Record{ID: name, Description: description, Seq: seq}, origin)
+}
+
+/*
+Read the records of a GenBank or EMBL stream, named with the name template, or joined into a single taxon with the
+given name in per-file mode.
+
+If a feature is selected, the sequences of the matching features of each record are joined in place of the record
+sequence.
+*/
+func (tc *taxonCollector) readAnnotated(input io.Reader, origin string, name string) error {
+	reader := fasta.NewAnnotatedReader(input)
+	records := make([]*fasta.Record, 0)
+
+	for annotated, err := range reader.Records() {
+		if err != nil {
+			return fmt.Errorf("%s: %w", origin, err)
+		}
+
+		recName, err := annotated.Name(tc.opts.nameTemplate)
+		if err != nil {
+			return err
+		}
+		rec := &fasta.Record{ID: recName, Description: annotated.Definition, Seq: annotated.Seq}
+
+		if tc.opts.feature.Key != "" {
+			seqs, err := annotated.FeatureSequences(tc.opts.feature)
+			if err != nil {
+				return fmt.Errorf("%s: record %s: %w", origin, annotated.Accession, err)
+			}
+			if len(seqs) == 0 {
+				return fmt.Errorf("%s: record %s has no %s feature", origin, annotated.Accession, tc.opts.feature)
+			}
+			rec.Seq = bytes.Join(seqs, []byte(tc.opts.separator))
+		}
+
+		if tc.opts.perFile {
+			records = append(records, rec)
+		} else if err := tc.add(rec, origin); err != nil {
+			return err
+		}
+	}
+
+	if tc.opts.perFile {
+		return tc.addJoined(name, records, origin)
+	}
+
+	return nil
+}
+
+func (tc *taxonCollector) readFile(path string) error {
+	input, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer input.Close()
+
+	inputStat, err := input.Stat()
+	if err != nil {
+		return err
+	}
+	if inputStat.Size() == 0 {
+		return fmt.Errorf("%w file \"%s\"", errEmptyInput, path)
+	}
+
+	name, _ := fasta.TrimExtensions(filepath.Base(path))
+
+	return tc.readStream(input, path, name)
+}
+
+// Return the names and sequences of the collected taxa
+func (tc *taxonCollector) taxa() (*[]string, *[][]byte) {
+	names := make([]string, len(tc.records))
+	seqs := make([][]byte, len(tc.records))
+	for i, rec := range tc.records {
+		names[i] = rec.ID
+		seqs[i] = rec.Seq
+	}
+
+	return &names, &seqs
+}
+
+/*
+Read the sequences of a list of FASTA, FASTQ, GenBank or EMBL files.
+
+Taxa made from whole files are named after the file, without its extensions. Duplicate taxon names are reported with
+the files where they occur.
+*/
+func (tc *taxonCollector) readFiles(paths []string) error {
+	for _, path := range paths {
+		if err := tc.readFile(path); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// Write the sequences of the collected taxa to a FASTA file, under the given names
+func (tc *taxonCollector) writeFasta(w io.Writer, names []string, lineWidth int) error {
+	writer := fasta.NewWriter(w, lineWidth)
+	for i, rec := range tc.records {
+		if err := writer.Write(&fasta.Record{ID: names[i], Description: rec.Description, Seq: rec.Seq}); err != nil {
+			return err
+		}
+	}
+
+	return writer.Flush()
+}
+
+// Rename taxa with a tab-separated mapping file
+func renameTaxa(path string, names []string) ([]string, error) {
+	input, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer input.Close()
+
+	nameMap, err := phylocore.ReadNameMap(input)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	renamed, err := nameMap.Rename(names)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+
+	return renamed, nil
+}
@@ -1,167 +1,749 @@
 package main
 
 import (
-	"bufio"
+	"errors"
 	"fmt"
+	"io"
 	"ncdtree/pkg/fasta"
+	"ncdtree/pkg/kmer"
 	"ncdtree/pkg/ncd"
 	"ncdtree/pkg/phylocore"
+	"ncdtree/pkg/sysexits"
 	"os"
+	"slices"
 
 	"github.com/akamensky/argparse"
-	"github.com/google/brotli/go/cbrotli"
 )
 
-const inputBufSize = 64 * 1024
-
 func main() {
-	compressorList := []string{"Brotli", "Gzip"}
+	compressorList := []string{"Brotli", "Gzip", "Zstd"}
 
 	parser := argparse.NewParser(
 		"ncdtree",
 		"Estimate a phylogeny from DNA sequences using the normalized compression distance (NCD) and neighbour-joining",
 	)
-	argInfile := parser.String(
+	argInfiles := parser.StringList(
 		"f", "file",
-		&argparse.Options{Required: false, Help: "File with sequences in FASTA format (read from stdin if none is given)"},
+		&argparse.Options{Required: false, Help: "File with sequences in FASTA, FASTQ, GenBank or EMBL format, optionally compressed with gzip, bzip2 or zstd, or directory of such files. Can be given several times (read from stdin if none is given)"},
+	)
+	argTaxonMode := parser.Selector(
+		"", "taxon-mode",
+		[]string{"record", "file"},
+		&argparse.Options{Required: false, Default: "record", Help: "Make a taxon of each FASTA record, or of each input file (with its records joined)"},
+	)
+	argContigSep := parser.String(
+		"", "contig-separator",
+		&argparse.Options{Required: false, Help: "Separator inserted between the records of a file in per-file taxon mode (none by default)"},
+	)
+	argNameTemplate := parser.String(
+		"", "name-template",
+		&argparse.Options{Required: false, Default: fasta.DefaultNameTemplate, Help: "GenBank/EMBL: Template of taxon names, with fields {organism}, {accession}, {locus} and {definition}"},
+	)
+	argFeature := parser.String(
+		"", "feature",
+		&argparse.Options{Required: false, Help: "GenBank/EMBL: Use the sequences of the features with this key (e.g. CDS) instead of the whole record, joined with the contig separator"},
+	)
+	argFeatureName := parser.String(
+		"", "feature-name",
+		&argparse.Options{Required: false, Help: "GenBank/EMBL: Only use the features with this gene, product or locus tag (e.g. COX1)"},
+	)
+	argMinReadQuality := parser.Float(
+		"", "min-read-quality",
+		&argparse.Options{Required: false, Default: 0.0, Help: "FASTQ: Discard reads with a lower mean Phred quality"},
+	)
+	argTrimQuality := parser.Int(
+		"", "trim-quality",
+		&argparse.Options{Required: false, Default: 0, Help: "FASTQ: Trim bases with a lower Phred quality from both ends of reads"},
+	)
+	argMinReadLength := parser.Int(
+		"", "min-read-length",
+		&argparse.Options{Required: false, Default: 1, Help: "FASTQ: Discard reads that are shorter after trimming"},
+	)
+	argMaxReads := parser.Int(
+		"", "max-reads",
+		&argparse.Options{Required: false, Default: 0, Help: "FASTQ: Maximum number of reads kept per file (no limit if 0)"},
+	)
+	argSubsample := parser.Float(
+		"", "subsample",
+		&argparse.Options{Required: false, Default: 1.0, Help: "FASTQ: Fraction of reads randomly kept before filtering"},
+	)
+	argSeed := parser.Int(
+		"", "seed",
+		&argparse.Options{Required: false, Default: 1, Help: "Seed for random subsampling of reads and for the hash function of Mash sketches"},
+	)
+	argDistance := parser.Selector(
+		"", "distance",
+		[]string{"ncd", "mash"},
+		&argparse.Options{Required: false, Default: "ncd", Help: "Distance between sequences: normalized compression distance, or Mash distance estimated from MinHash sketches of k-mers"},
+	)
+	argKmerSize := parser.Int(
+		"", "kmer-size",
+		&argparse.Options{Required: false, Default: 21, Help: "Mash: Size of the k-mers (at most 32)"},
+	)
+	argSketchSize := parser.Int(
+		"", "sketch-size",
+		&argparse.Options{Required: false, Default: 1000, Help: "Mash: Number of hashes kept in each sketch"},
 	)
 	argAlgo := parser.Selector(
 		"Z", "compressor",
 		compressorList,
 		&argparse.Options{Required: false, Default: "Brotli", Help: "Compression algorithm"},
 	)
+	argPack := parser.Flag(
+		"", "pack",
+		&argparse.Options{Required: false, Help: "Pack nucleotides into 2 bits before compression, with ambiguous bases in a separate stream"},
+	)
+	argConditional := parser.Flag(
+		"", "conditional",
+		&argparse.Options{Required: false, Help: "Compute the NCD from the compressed sizes of each sequence with every other one as a dictionary, rather than from concatenations (Zstd only)"},
+	)
+	argCompareConditional := parser.Flag(
+		"", "compare-conditional",
+		&argparse.Options{Required: false, Help: "Compute the NCD both from concatenations and with dictionaries, and print how the matrices differ (Zstd only)"},
+	)
+	argSizeCache := parser.String(
+		"", "size-cache",
+		&argparse.Options{Required: false, Help: "File of compressed sizes reused across runs, created if it does not exist (see ncdcache to prune it). Not with --distance mash"},
+	)
+	argProgress := parser.Selector(
+		"", "progress",
+		progressModes,
+		&argparse.Options{Required: false, Default: "auto", Help: "Show the progress of the distance computation on stderr as a bar, as a log line every 10 seconds, or not at all. Default: a bar on terminals, log lines otherwise"},
+	)
 	argStats := parser.Flag(
 		"s", "stats",
 		&argparse.Options{Required: false, Help: "Print statistics"},
 	)
-	// argTag := parser.String(
-	// 	"t", "tag",
-	// 	&argparse.Options{Required: false, Default: "", Help: "Tag for names of output files"},
-	// )
+	argStatsFormat := parser.Selector(
+		"", "stats-format",
+		statsFormats,
+		&argparse.Options{Required: false, Default: "text", Help: "Format of the statistics printed with --stats: tables for reading, or a JSON document or a TSV table with the columns section, item, field and value for other programs"},
+	)
+	argOutStats := parser.String(
+		"", "out-stats",
+		&argparse.Options{Required: false, Default: stdoutPath, Help: "Output file for the statistics printed with --stats or --compare-conditional (\"-\" for stdout)"},
+	)
+	argPrefix := parser.String(
+		"p", "prefix",
+		&argparse.Options{Required: false, Help: "Prefix for the default names of output files"},
+	)
+	argOutMatrix := parser.String(
+		"", "out-matrix",
+		&argparse.Options{Required: false, Help: "Output file for the distance matrix (\"-\" for stdout). Default: ncd_matrix.<ext>"},
+	)
+	argMatrixFormat := parser.Selector(
+		"", "matrix-format",
+		ncd.MatrixFormatNames,
+		&argparse.Options{Required: false, Default: "lower", Help: "Format of the distance matrix file"},
+	)
+	argOutTree := parser.String(
+		"", "out-tree",
+		&argparse.Options{Required: false, Help: "Output file for the tree (\"-\" for stdout). Default: tree.<ext>"},
+	)
+	argTreeFormat := parser.Selector(
+		"", "tree-format",
+		phylocore.TreeFormatNames,
+		&argparse.Options{Required: false, Default: "newick", Help: "Format of the tree file"},
+	)
+	argOutFasta := parser.String(
+		"", "out-fasta",
+		&argparse.Options{Required: false, Help: "Output file for the input sequences, as read and joined (\"-\" for stdout)"},
+	)
+	argLineWidth := parser.Int(
+		"", "line-width",
+		&argparse.Options{Required: false, Default: 70, Help: "Line width of the sequences in --out-fasta (no wrapping if 0)"},
+	)
+	argIndexed := parser.Flag(
+		"", "indexed",
+		&argparse.Options{Required: false, Help: "Read uncompressed FASTA files on demand through their .fai index (created next to them when missing), rather than holding all the sequences in memory"},
+	)
+	argCacheSize := parser.Int(
+		"", "cache-size",
+		&argparse.Options{Required: false, Default: 256, Help: "Maximum size in MB of the sequences kept in memory with --indexed"},
+	)
+	argRename := parser.String(
+		"", "rename",
+		&argparse.Options{Required: false, Help: "Tab-separated file of taxon names (first column) to replace by other names (second column)"},
+	)
+	argNames := parser.Selector(
+		"", "names",
+		phylocore.NameModeNames,
+		&argparse.Options{Required: false, Default: "sanitize", Help: "Taxon names in the matrix and tree files: keep them (quoted in Newick if needed), sanitize them, or encode them as short codes"},
+	)
+	argOutNames := parser.String(
+		"", "out-names",
+		&argparse.Options{Required: false, Help: "Output file for the table of the names in the matrix and tree files and the original names. Default: taxon_names.tsv, only if names were changed"},
+	)
+	argMinLength := parser.Int(
+		"", "min-length",
+		&argparse.Options{Required: false, Default: 0, Help: "Remove the taxa with shorter sequences"},
+	)
+	argMaxLength := parser.Int(
+		"", "max-length",
+		&argparse.Options{Required: false, Default: 0, Help: "Remove the taxa with longer sequences (no maximum if 0)"},
+	)
+	argMaxAmbiguous := parser.Float(
+		"", "max-ambiguous",
+		&argparse.Options{Required: false, Default: 1.0, Help: "Remove the taxa with a larger fraction of ambiguous bases (other than A, C, G, T and U)"},
+	)
+	argDedup := parser.Flag(
+		"", "dedup",
+		&argparse.Options{Required: false, Help: "Compute the distances of identical sequences only once, and add the identical taxa back to the tree as zero-length sister tips"},
+	)
 	argNoTree := parser.Flag(
 		"", "notree",
 		&argparse.Options{Required: false, Help: "Do not estimate a tree. Only write out distance matrix."},
 	)
+	argCanonical := parser.Flag(
+		"", "canonical",
+		&argparse.Options{Required: false, Help: "Root the tree next to the first taxon in alphabetical order, and order the children of each node from the smallest to the largest clade, and by taxon names for clades of the same size, so that the same tree is always written the same way"},
+	)
+	argShowTree := parser.Flag(
+		"", "show-tree",
+		&argparse.Options{Required: false, Help: "Draw the tree on stdout with box-drawing characters"},
+	)
+	argCladogram := parser.Flag(
+		"", "cladogram",
+		&argparse.Options{Required: false, Help: "--show-tree, --out-svg, --out-heatmap: Ignore the branch lengths and align the tips"},
+	)
+	argTreeWidth := parser.Int(
+		"", "tree-width",
+		&argparse.Options{Required: false, Default: 0, Help: "--show-tree: Width of the drawing in characters (the COLUMNS environment variable, or 80, if 0)"},
+	)
+	argShowLengths := parser.Flag(
+		"", "show-lengths",
+		&argparse.Options{Required: false, Help: "--show-tree: Write the lengths on the branches that are long enough"},
+	)
+	argShowSupport := parser.Flag(
+		"", "show-support",
+		&argparse.Options{Required: false, Help: "--show-tree, --out-svg: Write the support values of the clades that have them"},
+	)
+	argLadderize := parser.Flag(
+		"", "ladderize",
+		&argparse.Options{Required: false, Help: "--show-tree, --out-svg, --out-heatmap: Draw the children of each node from the smallest to the largest clade"},
+	)
+	argOutSVG := parser.String(
+		"", "out-svg",
+		&argparse.Options{Required: false, Help: "Output file for a figure of the tree in SVG (\"-\" for stdout)"},
+	)
+	argSVGLayout := parser.Selector(
+		"", "svg-layout",
+		phylocore.TreeLayoutNames,
+		&argparse.Options{Required: false, Default: "rectangular", Help: "--out-svg: Layout of the tree, with the root on the left or in the centre"},
+	)
+	argSVGWidth := parser.Float(
+		"", "svg-width",
+		&argparse.Options{Required: false, Default: phylocore.DefaultSVGWidth, Help: "--out-svg: Width of the figure in pixels, enlarged if the labels are too long"},
+	)
+	argAlignTips := parser.Flag(
+		"", "align-tips",
+		&argparse.Options{Required: false, Help: "--out-svg: Align the tip labels, joined to the tips by dotted lines"},
+	)
+	argHighlight := parser.String(
+		"", "highlight",
+		&argparse.Options{Required: false, Help: "--out-svg: Tab-separated file of clades to colour, one per line with a name, a colour and the names of one or more of its taxa (the clade is the smallest one that contains them)"},
+	)
+	argOutHeatmap := parser.String(
+		"", "out-heatmap",
+		&argparse.Options{Required: false, Help: "Output file for a heatmap of the distance matrix in SVG, with the taxa in the order of the tree and the tree drawn beside it (\"-\" for stdout)"},
+	)
+	argForce := parser.Flag(
+		"", "force",
+		&argparse.Options{Required: false, Help: "Overwrite existing output files"},
+	)
 
-	parser.Parse(os.Args)
+	err := parser.Parse(os.Args)
+	if err != nil {
+		sysexits.ExitMsg(parser.Usage(err), sysexits.Usage)
+	}
 
-	var input *os.File
-	var err error
-	var taxonNames *[]string
-	var seqs *[][]byte
-	var inputStat os.FileInfo
+	report := newStatsReport(os.Args[1:])
 
-	if len(*argInfile) > 0 {
-		input, err = os.Open(*argInfile)
+	matrixFormat, err := ncd.ParseMatrixFormat(*argMatrixFormat)
+	if err != nil {
+		sysexits.Exit(err, sysexits.Usage)
+	}
+	treeFormat, err := phylocore.ParseTreeFormat(*argTreeFormat)
+	if err != nil {
+		sysexits.Exit(err, sysexits.Usage)
+	}
+	nameMode, err := phylocore.ParseNameMode(*argNames)
+	if err != nil {
+		sysexits.Exit(err, sysexits.Usage)
+	}
+	if *argSubsample <= 0 || *argSubsample > 1 {
+		sysexits.ExitMsg("--subsample must be a fraction of the reads, larger than 0 and at most 1", sysexits.Usage)
+	}
+	if *argMaxReads < 0 {
+		sysexits.ExitMsg("--max-reads must not be negative", sysexits.Usage)
+	}
+	if *argTreeWidth < 0 {
+		sysexits.ExitMsg("--tree-width must not be negative", sysexits.Usage)
+	}
+	if *argNoTree && (*argCanonical || *argShowTree || *argOutSVG != "" || *argOutHeatmap != "") {
+		sysexits.ExitMsg("--canonical, --show-tree, --out-svg and --out-heatmap need a tree, and cannot be combined with --notree", sysexits.Usage)
+	}
+	svgLayout, err := phylocore.ParseTreeLayout(*argSVGLayout)
+	if err != nil {
+		sysexits.Exit(err, sysexits.Usage)
+	}
+	if *argSVGWidth <= 0 {
+		sysexits.ExitMsg("--svg-width must be positive", sysexits.Usage)
+	}
+	var highlights []phylocore.CladeHighlight
+	if *argHighlight != "" {
+		highlightFile, err := os.Open(*argHighlight)
 		if err != nil {
-			panic(err)
+			sysexits.Exit(err, sysexits.NoInput)
 		}
-		inputStat, err = input.Stat()
+		highlights, err = phylocore.ReadCladeHighlights(highlightFile)
+		highlightFile.Close()
 		if err != nil {
-			panic(err)
+			sysexits.Exit(err, sysexits.InputCode(err))
 		}
-		if inputStat.Size() == 0 {
-			os.Stderr.WriteString("Empty input file.\n")
-			os.Exit(65)
+	}
+
+	outPathMatrix := *argOutMatrix
+	if outPathMatrix == "" {
+		outPathMatrix = *argPrefix + *argDistance + "_matrix." + matrixFormat.Extension()
+	}
+	outPathTree := *argOutTree
+	if outPathTree == "" {
+		outPathTree = *argPrefix + "tree." + treeFormat.Extension()
+	}
+	defaultPathNames := *argPrefix + "taxon_names.tsv"
+
+	// Fail early rather than after computing the matrix
+	outputs := []outputPath{{"--out-matrix", outPathMatrix}}
+	if *argOutNames != "" {
+		outputs = append(outputs, outputPath{"--out-names", *argOutNames})
+	}
+	if *argOutFasta != "" {
+		outputs = append(outputs, outputPath{"--out-fasta", *argOutFasta})
+	}
+	if !*argNoTree {
+		outputs = append(outputs, outputPath{"--out-tree", outPathTree})
+		if *argOutSVG != "" {
+			outputs = append(outputs, outputPath{"--out-svg", *argOutSVG})
 		}
-		defer input.Close()
-	} else {
-		input = os.Stdin
-		inputStat, err = input.Stat()
-		if err != nil {
-			panic(err)
+		if *argOutHeatmap != "" {
+			outputs = append(outputs, outputPath{"--out-heatmap", *argOutHeatmap})
 		}
-		if inputStat.Mode()&os.ModeCharDevice != 0 {
-			os.Stderr.WriteString("No input.\n")
-			os.Exit(66)
+		if *argShowTree {
+			outputs = append(outputs, outputPath{"--show-tree", stdoutPath})
 		}
 	}
+	// The drawing and the stats tables are both for reading, and can share stdout
+	writeStats := *argStats || *argCompareConditional
+	if writeStats && !(*argShowTree && *argStatsFormat == "text" && *argOutStats == stdoutPath) {
+		outputs = append(outputs, outputPath{"--out-stats", *argOutStats})
+	}
+	// The default name map is only written if names change, so it is not checked for an existing file
+	distinctOutputs := outputs
+	if *argOutNames == "" && (nameMode != phylocore.NamesKeep || *argRename != "") {
+		distinctOutputs = append(slices.Clip(outputs), outputPath{"--out-names", defaultPathNames})
+	}
+	if err := checkDistinctOutputs(distinctOutputs); err != nil {
+		sysexits.Exit(err, sysexits.Usage)
+	}
+	for _, out := range outputs {
+		if err := checkOutput(out.path, *argForce); err != nil {
+			sysexits.Exit(err, sysexits.CantCreate)
+		}
+	}
+	report.Run.TaxonMode = *argTaxonMode
+	report.Run.Distance = *argDistance
+	report.Run.OutMatrix = outPathMatrix
+	if !*argNoTree {
+		report.Run.OutTree = outPathTree
+	}
 
-	reader := bufio.NewReaderSize(input, inputBufSize)
-	taxonNames, seqs, err = fasta.ReadFasta(reader)
-	if err != nil {
-		panic(err)
+	if *argDistance == "mash" && (*argKmerSize < 1 || *argKmerSize > kmer.MaxK || *argSketchSize < 1) {
+		sysexits.ExitMsg(fmt.Sprintf("--kmer-size must be between 1 and %d, and --sketch-size positive", kmer.MaxK), sysexits.Usage)
 	}
 
-	N := len(*taxonNames)
+	if (*argConditional || *argCompareConditional) && (*argAlgo != "Zstd" || *argPack) {
+		sysexits.ExitMsg("--conditional and --compare-conditional require --compressor Zstd, without --pack", sysexits.Usage)
+	}
 
-	compressorName := *argAlgo
+	if *argSizeCache != "" && *argDistance == "mash" {
+		sysexits.ExitMsg("--size-cache only applies to compressed sizes, and cannot be combined with --distance mash", sysexits.Usage)
+	}
+	var sizeCache *ncd.SizeCache
+	if *argSizeCache != "" {
+		sizeCache, err = ncd.OpenSizeCache(*argSizeCache)
+		if err != nil {
+			sysexits.Exit(err, sysexits.CantCreate)
+		}
+	}
 
-	var mc ncd.ManagedCompressor
+	if *argFeatureName != "" && *argFeature == "" {
+		sysexits.ExitMsg("--feature-name requires --feature", sysexits.Usage)
+	}
 
-	switch compressorName {
-	case "Brotli":
-		opts := cbrotli.WriterOptions{
-			Quality: 11, // Compression level
-			LGWin:   0,  // Automatic window
-		}
-		mc = ncd.NewManagedCompressorBrotli(opts)
-	case "Gzip":
-		mc = ncd.NewManagedCompressorGzip()
+	var taxonNames *[]string
+	var seqSize []int
+	var source ncd.SequenceSource
+	var inputs interface {
+		writeFasta(w io.Writer, names []string, lineWidth int) error
 	}
 
-	cx := ncd.CXVector(seqs, mc)
-	cxx := ncd.CXXVector(seqs, mc)
+	if *argIndexed {
+		if len(*argInfiles) == 0 || *argTaxonMode != "record" {
+			sysexits.ExitMsg("--indexed requires input files (-f) and the record taxon mode", sysexits.Usage)
+		}
+		paths, err := expandInputPaths(*argInfiles)
+		if err != nil {
+			sysexits.Exit(err, sysexits.NoInput)
+		}
+		indexed, err := openIndexedInputs(paths)
+		if err != nil {
+			sysexits.Exit(err, sysexits.InputCode(err))
+		}
+		report.Run.Inputs = paths
+		defer indexed.Close()
 
-	if *argStats {
-		fmt.Println("COMPRESSOR")
-		fmt.Println("==========")
-		fmt.Println("Compressor " + compressorName + "\n")
-		fmt.Println("COMPRESSION METRICS")
-		fmt.Println("===================")
-		// fmt.Println("\n#\tTaxon\tSize\tCompressedSize\tCompressionRatio\tSelfNCD")
-		// fmt.Println("---------------------------------------------------------------------------------")
-		selfNCD := make([]float64, N)
-		for i := range N {
-			selfNCD[i] = ncd.NCD(cx[i], cx[i], cxx[i])
-		}
-
-		seqSize := make([]int, len(*seqs))
+		taxonNames, seqSize, inputs = &indexed.names, indexed.lengths, indexed
+		source = ncd.NewCachedSource(indexed, *argCacheSize<<20)
+	} else {
+		collector := newTaxonCollector(readOptions{
+			perFile:   *argTaxonMode == "file",
+			separator: *argContigSep,
+			readFilter: fasta.ReadFilter{
+				SubsampleFraction: *argSubsample,
+				Seed:              uint64(*argSeed),
+				TrimQuality:       *argTrimQuality,
+				MinMeanQuality:    *argMinReadQuality,
+				MinLength:         *argMinReadLength,
+				MaxReads:          *argMaxReads,
+			},
+			nameTemplate: *argNameTemplate,
+			feature:      fasta.FeatureSelector{Key: *argFeature, Name: *argFeatureName},
+		})
+
+		if len(*argInfiles) > 0 {
+			paths, err := expandInputPaths(*argInfiles)
+			if err != nil {
+				sysexits.Exit(err, sysexits.NoInput)
+			}
+			err = collector.readFiles(paths)
+			if err != nil {
+				sysexits.Exit(err, sysexits.InputCode(err))
+			}
+			report.Run.Inputs = paths
+		} else {
+			inputStat, err := os.Stdin.Stat()
+			if err != nil {
+				sysexits.Exit(err, sysexits.IOErr)
+			}
+			if inputStat.Mode()&os.ModeCharDevice != 0 {
+				sysexits.ExitMsg("No input.", sysexits.NoInput)
+			}
+			err = collector.readStream(os.Stdin, "stdin", "stdin")
+			if err != nil {
+				sysexits.Exit(err, sysexits.DataErr)
+			}
+			report.Run.Inputs = []string{"stdin"}
+		}
+
+		var seqs *[][]byte
+		taxonNames, seqs = collector.taxa()
+		seqSize = make([]int, len(*seqs))
 		for i, v := range *seqs {
 			seqSize[i] = len(v)
 		}
-		// var compressionRatio float64
+		inputs = collector
+		source = ncd.SliceSource(*seqs)
+	}
+
+	if *argRename != "" {
+		renamed, err := renameTaxa(*argRename, *taxonNames)
+		if err != nil {
+			sysexits.Exit(err, sysexits.InputCode(err))
+		}
+		taxonNames = &renamed
+	}
+
+	// Names used in the matrix and tree files
+	outputNames, restoreNames := phylocore.MakeSafeNames(*taxonNames, nameMode)
+	outPathNames := *argOutNames
+	if outPathNames == "" && restoreNames.Len() > 0 {
+		outPathNames = defaultPathNames
+	}
+	if outPathNames != "" {
+		outFileNames, err := createOutput(outPathNames, *argForce)
+		if err != nil {
+			sysexits.Exit(err, sysexits.CantCreate)
+		}
+		err = restoreNames.Write(outFileNames, "name", "original_name")
+		outFileNames.Close()
+		if err != nil {
+			sysexits.Exit(err, sysexits.IOErr)
+		}
+	}
+
+	if *argOutFasta != "" {
+		outFileFasta, err := createOutput(*argOutFasta, *argForce)
+		if err != nil {
+			sysexits.Exit(err, sysexits.CantCreate)
+		}
+		err = inputs.writeFasta(outFileFasta, *taxonNames, *argLineWidth)
+		outFileFasta.Close()
+		if err != nil {
+			sysexits.Exit(err, sysexits.IOErr)
+		}
+	}
+
+	// Remove and collapse taxa before computing distances
+	seqFilter := sequenceFilter{*argMinLength, *argMaxLength, *argMaxAmbiguous, *argDedup}
+	allNames := *taxonNames
+	report.Run.Taxa = len(allNames)
+	var filtered *filterResult
+	if seqFilter.active() {
+		filtered, err = seqFilter.apply(source)
+		if err != nil {
+			sysexits.Exit(err, sysexits.IOErr)
+		}
+		if len(filtered.kept) < 2 {
+			sysexits.ExitMsg(fmt.Sprintf("%d taxa left after filtering, at least 2 are needed", len(filtered.kept)), sysexits.DataErr)
+		}
+		source = ncd.SubsetSource{Source: source, Indices: filtered.kept}
+		keptNames := selectItems(*taxonNames, filtered.kept)
+		taxonNames = &keptNames
+		seqSize = selectItems(seqSize, filtered.kept)
+	}
+	allOutputNames := outputNames
+	if filtered != nil {
+		outputNames = selectItems(allOutputNames, filtered.kept)
+	}
+
+	if *argStats && filtered != nil {
+		report.Filtering = newFilterStats(allNames, filtered)
+	}
+
+	var D *ncd.TriangularMatrix
+	if *argDistance == "mash" {
+		sketches, err := kmer.SketchSource(source, *argKmerSize, *argSketchSize, uint64(*argSeed))
+		if err != nil {
+			sysexits.Exit(err, sysexits.IOErr)
+		}
+		if *argStats {
+			report.Sketches = newSketchStats(*taxonNames, seqSize, sketches, *argKmerSize, *argSketchSize, uint64(*argSeed))
+		}
+		D, err = kmer.MashMatrix(sketches)
+		if err != nil {
+			sysexits.Exit(err, sysexits.Software)
+		}
+	} else {
+		mc, compressor := newCompressor(*argAlgo, *argPack)
+		if sizeCache != nil {
+			mc = ncd.NewCachedCompressor(mc, compressor.id(), sizeCache)
+		}
 
-		// for i, taxonName := range *taxonNames {
-		// 	seqSize[i] = len((*seqs)[i])
-		// 	compressionRatio = cx[i] / float64(seqSize[i])
+		cx, err := ncd.CXVectorFrom(source, mc)
+		if err != nil {
+			sysexits.Exit(err, sysexits.IOErr)
+		}
+		cxx, err := ncd.CXXVectorFrom(source, mc)
+		if err != nil {
+			sysexits.Exit(err, sysexits.IOErr)
+		}
+
+		if *argStats {
+			report.Compressor = &compressorStats{
+				Name:        compressor.displayName(),
+				ID:          compressor.id(),
+				Settings:    compressor.settingsMap(),
+				Packed:      *argPack,
+				Conditional: *argConditional,
+			}
+			report.Compression = newCompressionStats(*taxonNames, seqSize, cx, cxx)
+		}
 
-		// 	fmt.Printf("%2d\t%-*s\t%d\t%.0f\t%.2g\t%.2g mit\n", i, 40, taxonName, seqSize[i], cx[i], compressionRatio, selfNCD[i])
-		// }
+		// Create the distance matrix
+		computeMatrix := func(conditional bool) (*ncd.TriangularMatrix, error) {
+			if conditional {
+				progress := newProgressDisplay(os.Stderr, *argProgress, "Conditional NCD")
+				return ncd.ConditionalNCDMatrixFrom(source, &cx, mc.(ncd.PrimableCompressor), progress)
+			}
+			progress := newProgressDisplay(os.Stderr, *argProgress, "NCD")
+			return ncd.NCDMatrixFrom(source, &cx, mc, progress)
+		}
+		D, err = computeMatrix(*argConditional)
+		if err != nil {
+			sysexits.Exit(err, sysexits.IOErr)
+		}
 
-		// slices.Sort(selfNCD) // In-place sorting
-		// if N%2 == 0 {
-		// 	selfNCDMedian = (selfNCD[N/2] + selfNCD[(N/2)+1]) / 2
-		// } else {
-		// 	selfNCDMedian = selfNCD[(N+1)/2]
-		// }
+		if *argCompareConditional {
+			other, err := computeMatrix(!*argConditional)
+			if err != nil {
+				sysexits.Exit(err, sysexits.IOErr)
+			}
+			concatenation, conditional := D, other
+			if *argConditional {
+				concatenation, conditional = other, D
+			}
+			report.Comparison = newComparisonStats(*taxonNames, concatenation, conditional)
+		}
 
-		writeStatsTable(os.Stdout, taxonNames, &seqSize, &cx, &selfNCD)
+		if sizeCache != nil {
+			if *argStats {
+				report.SizeCache = &sizeCacheStats{*argSizeCache, sizeCache.Hits, sizeCache.Misses, sizeCache.Len()}
+			}
+			if err := sizeCache.Close(); err != nil {
+				os.Stderr.WriteString("Could not save the size cache: " + err.Error() + "\n")
+			}
+		}
 	}
 
-	// Create the distance matrix
-	D := ncd.NCDMatrix(seqs, &cx, mc)
+	if *argStats {
+		md := ncd.DiagnoseMatrix(D, maxListedViolations, maxDeltaQuartets, uint64(*argSeed))
+		report.Matrix = newMatrixStats(*taxonNames, D, md)
+		report.Diagnostics, err = newDiagnosticsStats(*taxonNames, source, D, md, *argDistance)
+		if err != nil {
+			sysexits.Exit(err, sysexits.IOErr)
+		}
+	}
 
-	outFileMatrix, err := os.Create("ncd_matrix.txt")
+	outFileMatrix, err := createOutput(outPathMatrix, *argForce)
 	if err != nil {
-		panic(err)
+		sysexits.Exit(err, sysexits.CantCreate)
 	}
 	defer outFileMatrix.Close()
-	ncd.WriteLabelledTriangularMatrix(outFileMatrix, taxonNames, D, 9)
+	_, err = ncd.WriteMatrix(outFileMatrix, &outputNames, D, matrixFormat, 9)
+	if err != nil {
+		sysexits.Exit(err, sysexits.IOErr)
+	}
 
 	if !*argNoTree {
-		taxset, err := phylocore.NewTaxonSet(*taxonNames)
+		taxset, err := phylocore.NewTaxonSet(outputNames)
 		if err != nil {
-			panic(err)
+			sysexits.Exit(err, sysexits.DataErr)
 		}
-		outFileTree, err := os.Create("tree.nwk")
+		outFileTree, err := createOutput(outPathTree, *argForce)
 		if err != nil {
-			panic(err)
+			sysexits.Exit(err, sysexits.CantCreate)
 		}
 		defer outFileTree.Close()
-		tree := phylocore.NeighbourJoining(taxset, D)
+		// Neighbour-joining overwrites the matrix
+		var Dtree *ncd.TriangularMatrix
+		if *argStats || *argOutHeatmap != "" {
+			Dtree = D.Copy()
+		}
+		tree, err := phylocore.NeighbourJoining(taxset, D)
+		if err != nil {
+			sysexits.Exit(err, sysexits.DataErr)
+		}
+		if *argCanonical {
+			tree.Canonicalize()
+		}
+
+		// The fit and the heatmap are made before identical taxa are added, as they are not in the matrix
+		if *argStats && Dtree.N >= 3 {
+			fit, err := tree.Fit(Dtree, maxListedResiduals)
+			if err != nil {
+				sysexits.Exit(err, sysexits.DataErr)
+			}
+			report.Fit = newTreeFitStats(outputNames, fit)
+		}
+		if *argOutHeatmap != "" {
+			heatmapTaxa, err := phylocore.NewTaxonSet(*taxonNames)
+			if err != nil {
+				sysexits.Exit(err, sysexits.DataErr)
+			}
+			outFileHeatmap, err := createOutput(*argOutHeatmap, *argForce)
+			if err != nil {
+				sysexits.Exit(err, sysexits.CantCreate)
+			}
+			err = tree.WriteHeatmapSVG(outFileHeatmap, heatmapTaxa, Dtree, phylocore.HeatmapOptions{
+				Cladogram: *argCladogram,
+				Ladderize: *argLadderize,
+			})
+			outFileHeatmap.Close()
+			if errors.Is(err, phylocore.ErrMatrixSize) {
+				sysexits.Exit(err, sysexits.DataErr)
+			} else if err != nil {
+				sysexits.Exit(err, sysexits.IOErr)
+			}
+		}
+
+		if filtered != nil {
+			for k, rep := range filtered.kept {
+				dups := filtered.duplicates[rep]
+				err = tree.AddIdenticalTaxa(taxset, k, selectItems(allOutputNames, dups))
+				if err != nil {
+					sysexits.Exit(err, sysexits.DataErr)
+				}
+			}
+			if *argCanonical {
+				tree.Canonicalize()
+			}
+		}
+
+		err = tree.Write(outFileTree, taxset, treeFormat)
+		if err != nil {
+			sysexits.Exit(err, sysexits.IOErr)
+		}
+
+		if *argShowTree || *argOutSVG != "" {
+			// Drawings are for reading, so they have the original names rather than the names in the files
+			originalNames := make(map[string]string, len(allNames))
+			for i, name := range allOutputNames {
+				originalNames[name] = allNames[i]
+			}
+			for _, node := range tree.Nodes {
+				if node.IsOuter() {
+					node.Label = originalNames[node.Label]
+				}
+			}
+		}
 
-		outFileTree.WriteString(tree.NewickString())
+		if *argOutSVG != "" {
+			outFileSVG, err := createOutput(*argOutSVG, *argForce)
+			if err != nil {
+				sysexits.Exit(err, sysexits.CantCreate)
+			}
+			err = tree.WriteSVG(outFileSVG, phylocore.SVGOptions{
+				Layout:     svgLayout,
+				Width:      *argSVGWidth,
+				Cladogram:  *argCladogram,
+				AlignTips:  *argAlignTips,
+				Support:    *argShowSupport,
+				Ladderize:  *argLadderize,
+				Highlights: highlights,
+			})
+			outFileSVG.Close()
+			if errors.Is(err, phylocore.ErrTaxonNotInTree) {
+				sysexits.Exit(err, sysexits.DataErr)
+			} else if err != nil {
+				sysexits.Exit(err, sysexits.IOErr)
+			}
+		}
+
+		if *argShowTree {
+			err = tree.WriteText(os.Stdout, phylocore.TextPlotOptions{
+				Width:     *argTreeWidth,
+				Cladogram: *argCladogram,
+				Lengths:   *argShowLengths,
+				Support:   *argShowSupport,
+				Ladderize: *argLadderize,
+			})
+			if err != nil {
+				sysexits.Exit(err, sysexits.IOErr)
+			}
+			fmt.Println()
+		}
 	}
 
+	if *argStats || report.Comparison != nil {
+		outFileStats, err := createOutput(*argOutStats, *argForce)
+		if err != nil {
+			sysexits.Exit(err, sysexits.CantCreate)
+		}
+		err = report.write(outFileStats, *argStatsFormat)
+		outFileStats.Close()
+		if err != nil {
+			sysexits.Exit(err, sysexits.IOErr)
+		}
+	}
 }
@@ -0,0 +1,87 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+)
+
+// Path that stands for the standard output
+const stdoutPath = "-"
+
+type nopWriteCloser struct {
+	io.Writer
+}
+
+func (nopWriteCloser) Close() error {
+	return nil
+}
+
+// Check that an output file can be created without overwriting an existing file, unless force is set
+func checkOutput(path string, force bool) error {
+	if path == stdoutPath || force {
+		return nil
+	}
+
+	_, err := os.Stat(path)
+	if err == nil {
+		return fmt.Errorf("output file \"%s\" already exists (use --force to overwrite it)", path)
+	}
+	if !errors.Is(err, fs.ErrNotExist) {
+		return err
+	}
+
+	return nil
+}
+
+// Output of the program, with the option that sets its path
+type outputPath struct {
+	option string
+	path   string
+}
+
+// Check that no two outputs are written to the same file, or both to the standard output
+func checkDistinctOutputs(outputs []outputPath) error {
+	seen := make(map[string]string, len(outputs))
+	for _, out := range outputs {
+		key := out.path
+		if key != stdoutPath {
+			if abs, err := filepath.Abs(key); err == nil {
+				key = abs
+			} else {
+				key = filepath.Clean(key)
+			}
+		}
+		if other, ok := seen[key]; ok {
+			if key == stdoutPath {
+				return fmt.Errorf("%s and %s cannot both write to stdout", other, out.option)
+			}
+			return fmt.Errorf("%s and %s cannot both write to \"%s\"", other, out.option, out.path)
+		}
+		seen[key] = out.option
+	}
+
+	return nil
+}
+
+// Open an output file, or the standard output if the path is "-". Existing files are only overwritten if force is set.
+func createOutput(path string, force bool) (io.WriteCloser, error) {
+	if path == stdoutPath {
+		return nopWriteCloser{os.Stdout}, nil
+	}
+
+	flag := os.O_WRONLY | os.O_CREATE | os.O_TRUNC
+	if !force {
+		flag |= os.O_EXCL
+	}
+
+	file, err := os.OpenFile(path, flag, 0o644)
+	if errors.Is(err, fs.ErrExist) {
+		return nil, fmt.Errorf("output file \"%s\" already exists (use --force to overwrite it)", path)
+	}
+
+	return file, err
+}
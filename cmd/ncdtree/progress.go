@@ -0,0 +1,76 @@
+package main
+
+import (
+	"fmt"
+	"ncdtree/pkg/ncd"
+	"os"
+	"strings"
+	"time"
+)
+
+// Ways to show the progress of the distance computation
+var progressModes = []string{"auto", "bar", "log", "none"}
+
+const (
+	progressBarWidth    = 30
+	progressBarInterval = 100 * time.Millisecond
+	progressLogInterval = 10 * time.Second
+)
+
+// Tell if a file is a terminal
+func isTerminal(f *os.File) bool {
+	info, err := f.Stat()
+
+	return err == nil && info.Mode()&os.ModeCharDevice != 0
+}
+
+/*
+Create a function that shows the progress of a computation in a file (stderr, so that the results written to stdout
+can be piped): as a bar redrawn in place, or as a line every few seconds. In the "auto" mode, the bar is used if the
+file is a terminal. Returns nil in the "none" mode.
+*/
+func newProgressDisplay(f *os.File, mode string, label string) ncd.ProgressFunc {
+	if mode == "auto" {
+		mode = "log"
+		if isTerminal(f) {
+			mode = "bar"
+		}
+	}
+
+	var last time.Time
+	switch mode {
+	case "bar":
+		return func(p ncd.Progress) {
+			finished := p.Done == p.Total
+			if !finished && time.Since(last) < progressBarInterval {
+				return
+			}
+			last = time.Now()
+
+			fraction := 1.0
+			if p.Total > 0 {
+				fraction = float64(p.Done) / float64(p.Total)
+			}
+			filled := int(fraction * progressBarWidth)
+			bar := strings.Repeat("#", filled) + strings.Repeat("-", progressBarWidth-filled)
+			fmt.Fprintf(f, "\r\033[K%s [%s] %3.0f%%  %d/%d pairs  %.1f pairs/s  ETA %s",
+				label, bar, 100*fraction, p.Done, p.Total, p.Throughput(), p.ETA().Round(time.Second))
+			if finished {
+				fmt.Fprintln(f)
+			}
+		}
+	case "log":
+		return func(p ncd.Progress) {
+			finished := p.Done == p.Total
+			if p.Done > 0 && !finished && time.Since(last) < progressLogInterval {
+				return
+			}
+			last = time.Now()
+
+			fmt.Fprintf(f, "%s %s: %d of %d pairs, %.1f pairs/s, ETA %s\n",
+				last.Format(time.TimeOnly), label, p.Done, p.Total, p.Throughput(), p.ETA().Round(time.Second))
+		}
+	}
+
+	return nil
+}
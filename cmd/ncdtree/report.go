@@ -0,0 +1,726 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"math"
+	"ncdtree/pkg/fasta"
+	"ncdtree/pkg/kmer"
+	"ncdtree/pkg/ncd"
+	"ncdtree/pkg/phylocore"
+	"ncdtree/pkg/stats"
+	"runtime"
+	"runtime/debug"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Formats of the --stats report
+var statsFormats = []string{"text", "json", "tsv"}
+
+/*
+Version of the layout of the JSON and TSV reports. It changes when fields are renamed or removed, not when new ones are
+added.
+*/
+const statsSchemaVersion = 1
+
+// Float that is written as null in JSON if it is NaN or infinite
+type jsonFloat float64
+
+func (x jsonFloat) MarshalJSON() ([]byte, error) {
+	f := float64(x)
+	if math.IsNaN(f) || math.IsInf(f, 0) {
+		return []byte("null"), nil
+	}
+
+	return strconv.AppendFloat(nil, f, 'g', -1, 64), nil
+}
+
+/*
+Everything printed with --stats, filled in as the run goes.
+
+Sections are nil when they do not apply, e.g. the compression metrics with the Mash distance.
+*/
+type statsReport struct {
+	SchemaVersion int               `json:"schema_version"`
+	Run           runInfo           `json:"run"`
+	Filtering     *filterStats      `json:"filtering,omitempty"`
+	Sketches      *sketchStats      `json:"sketches,omitempty"`
+	Compressor    *compressorStats  `json:"compressor,omitempty"`
+	Compression   *compressionStats `json:"compression,omitempty"`
+	Diagnostics   *diagnosticsStats `json:"diagnostics,omitempty"`
+	Matrix        *matrixStats      `json:"matrix,omitempty"`
+	Comparison    *comparisonStats  `json:"comparison,omitempty"`
+	Fit           *treeFitStats     `json:"tree_fit,omitempty"`
+	SizeCache     *sizeCacheStats   `json:"size_cache,omitempty"`
+
+	started time.Time
+}
+
+type runInfo struct {
+	Program        string    `json:"program"`
+	Version        string    `json:"version"` // VCS revision of the build, or "(devel)"
+	GoVersion      string    `json:"go_version"`
+	Platform       string    `json:"platform"`
+	Arguments      []string  `json:"arguments"`
+	Started        string    `json:"started"` // RFC 3339
+	ElapsedSeconds jsonFloat `json:"elapsed_seconds"`
+	Inputs         []string  `json:"inputs"`
+	TaxonMode      string    `json:"taxon_mode"`
+	Distance       string    `json:"distance"`
+	Taxa           int       `json:"taxa"` // Number of taxa read, before filtering
+	OutMatrix      string    `json:"out_matrix"`
+	OutTree        string    `json:"out_tree,omitempty"`
+}
+
+type filterStats struct {
+	Kept      int              `json:"kept"`
+	Removed   []removedStats   `json:"removed"`
+	Collapsed []collapsedStats `json:"collapsed"`
+}
+
+type removedStats struct {
+	Taxon  string `json:"taxon"`
+	Reason string `json:"reason"`
+}
+
+type collapsedStats struct {
+	Taxon       string `json:"taxon"`
+	IdenticalTo string `json:"identical_to"`
+}
+
+type sketchStats struct {
+	K    int                `json:"k"`
+	Size int                `json:"size"`
+	Seed uint64             `json:"seed"`
+	Taxa []sketchTaxonStats `json:"taxa"`
+}
+
+type sketchTaxonStats struct {
+	Taxon  string `json:"taxon"`
+	Size   int    `json:"size"`
+	Hashes int    `json:"hashes"`
+}
+
+type compressorStats struct {
+	Name        string            `json:"name"`
+	ID          string            `json:"id"`
+	Settings    map[string]string `json:"settings"`
+	Packed      bool              `json:"packed"`
+	Conditional bool              `json:"conditional"`
+}
+
+type compressionStats struct {
+	Taxa    []compressionTaxonStats `json:"taxa"`
+	Summary compressionSummary      `json:"summary"`
+}
+
+type compressionTaxonStats struct {
+	Taxon            string    `json:"taxon"`
+	Size             int       `json:"size"`
+	CompressedSize   jsonFloat `json:"compressed_size"`
+	CompressionRatio jsonFloat `json:"compression_ratio"`
+	SelfNCD          jsonFloat `json:"self_ncd"`
+}
+
+type columnSummary struct {
+	Mean    jsonFloat `json:"mean"`
+	Median  jsonFloat `json:"median"`
+	Minimum jsonFloat `json:"minimum"`
+	Maximum jsonFloat `json:"maximum"`
+}
+
+type compressionSummary struct {
+	Size             columnSummary `json:"size"`
+	CompressedSize   columnSummary `json:"compressed_size"`
+	CompressionRatio columnSummary `json:"compression_ratio"`
+	SelfNCD          columnSummary `json:"self_ncd"`
+}
+
+/*
+Diagnostics of the taxa, from their sequences and their distances to the other taxa.
+
+A taxon is an outlier if its mean distance exceeds the median of the mean distances by more than outlierMADs times the
+scaled median absolute deviation.
+*/
+type diagnosticsStats struct {
+	Distance         string             `json:"distance"`
+	OutlierThreshold jsonFloat          `json:"outlier_threshold"` // Mean distances above it are flagged
+	Taxa             []taxonDiagnostics `json:"taxa"`
+	Summary          diagnosticsSummary `json:"summary"`
+}
+
+type taxonDiagnostics struct {
+	Taxon             string    `json:"taxon"`
+	GCContent         jsonFloat `json:"gc_content"`
+	AmbiguousFraction jsonFloat `json:"ambiguous_fraction"`
+	Entropy           jsonFloat `json:"entropy"` // Bits per character
+	MeanDistance      jsonFloat `json:"mean_distance"`
+	NearestNeighbour  string    `json:"nearest_neighbour"`
+	NearestDistance   jsonFloat `json:"nearest_distance"`
+	Outlier           bool      `json:"outlier"`
+	Delta             jsonFloat `json:"delta"` // Mean delta score of the quartets with the taxon
+}
+
+type diagnosticsSummary struct {
+	GCContent         columnSummary `json:"gc_content"`
+	AmbiguousFraction columnSummary `json:"ambiguous_fraction"`
+	Entropy           columnSummary `json:"entropy"`
+	MeanDistance      columnSummary `json:"mean_distance"`
+	NearestDistance   columnSummary `json:"nearest_distance"`
+	Delta             columnSummary `json:"delta"`
+}
+
+// Number of scaled median absolute deviations above the median at which a mean distance is an outlier (the moderately
+// conservative threshold of Leys et al. 2013)
+const outlierMADs = 2.5
+
+// Factor that scales the median absolute deviation to the standard deviation of normally distributed values
+const madScale = 1.4826
+
+// Checks of the distance matrix against the properties of a tree metric
+type matrixStats struct {
+	Entries          int              `json:"entries"`
+	OutOfRange       []matrixEntry    `json:"out_of_range"` // Values outside [0, 1]
+	Triples          int              `json:"triples"`
+	Violations       int              `json:"triangle_violations"`
+	LargestViolation []violationStats `json:"largest_violations"`
+	Quartets         int              `json:"quartets"`
+	SampledQuartets  bool             `json:"sampled_quartets"`
+	Delta            jsonFloat        `json:"delta"`
+}
+
+type matrixEntry struct {
+	Taxa  [2]string `json:"taxa"`
+	Value jsonFloat `json:"value"`
+}
+
+// Distance between two taxa that is longer than the path through a third one
+type violationStats struct {
+	Taxa     [2]string `json:"taxa"`
+	Via      string    `json:"via"`
+	Distance jsonFloat `json:"distance"`
+	Path     jsonFloat `json:"path"`
+	Excess   jsonFloat `json:"excess"`
+}
+
+// Number of triangle violations listed in the report
+const maxListedViolations = 10
+
+// Number of quartets above which the delta scores are estimated from a random sample of this size
+const maxDeltaQuartets = 1000000
+
+// Fit of the patristic distances of the tree to the distance matrix
+type treeFitStats struct {
+	CopheneticCorrelation jsonFloat       `json:"cophenetic_correlation"`
+	Stress                jsonFloat       `json:"stress"`
+	LargestResiduals      []residualStats `json:"largest_residuals"`
+}
+
+type residualStats struct {
+	Taxa      [2]string `json:"taxa"`
+	Distance  jsonFloat `json:"distance"`
+	Patristic jsonFloat `json:"patristic"`
+	Residual  jsonFloat `json:"residual"` // Patristic - distance
+}
+
+// Number of residuals of the tree fit listed in the report
+const maxListedResiduals = 10
+
+type comparisonStats struct {
+	Pairs                   int       `json:"pairs"`
+	MeanConcatenation       jsonFloat `json:"mean_concatenation"`
+	MeanConditional         jsonFloat `json:"mean_conditional"`
+	MeanAbsoluteDifference  jsonFloat `json:"mean_absolute_difference"`
+	LargestDifference       jsonFloat `json:"largest_difference"`
+	LargestDifferencePair   [2]string `json:"largest_difference_pair"`
+	LargestConcatenationNCD jsonFloat `json:"largest_difference_concatenation"`
+	LargestConditionalNCD   jsonFloat `json:"largest_difference_conditional"`
+}
+
+type sizeCacheStats struct {
+	Path   string `json:"path"`
+	Hits   int    `json:"hits"`
+	Misses int    `json:"misses"`
+	Sizes  int    `json:"sizes"`
+}
+
+// Start a report with the metadata of the run known from the command line
+func newStatsReport(args []string) *statsReport {
+	version := "(devel)"
+	if info, ok := debug.ReadBuildInfo(); ok {
+		for _, setting := range info.Settings {
+			if setting.Key == "vcs.revision" {
+				version = setting.Value
+			}
+		}
+	}
+
+	started := time.Now()
+
+	return &statsReport{
+		SchemaVersion: statsSchemaVersion,
+		Run: runInfo{
+			Program:   "ncdtree",
+			Version:   version,
+			GoVersion: runtime.Version(),
+			Platform:  runtime.GOOS + "/" + runtime.GOARCH,
+			Arguments: args,
+			Started:   started.Format(time.RFC3339),
+			Inputs:    []string{},
+		},
+		started: started,
+	}
+}
+
+func newFilterStats(names []string, res *filterResult) *filterStats {
+	fs := &filterStats{Kept: len(res.kept), Removed: []removedStats{}, Collapsed: []collapsedStats{}}
+	for _, r := range res.removed {
+		fs.Removed = append(fs.Removed, removedStats{names[r.index], r.reason})
+	}
+	for _, rep := range res.kept {
+		for _, dup := range res.duplicates[rep] {
+			fs.Collapsed = append(fs.Collapsed, collapsedStats{names[dup], names[rep]})
+		}
+	}
+
+	return fs
+}
+
+func newSketchStats(names []string, seqLen []int, sketches []*kmer.Sketch, k int, size int, seed uint64) *sketchStats {
+	ss := &sketchStats{K: k, Size: size, Seed: seed, Taxa: make([]sketchTaxonStats, len(names))}
+	for i, name := range names {
+		ss.Taxa[i] = sketchTaxonStats{name, seqLen[i], len(sketches[i].Hashes)}
+	}
+
+	return ss
+}
+
+func summarize[T stats.Numeric](X []T) columnSummary {
+	var mean float64
+	if len(X) > 0 {
+		for _, x := range X {
+			mean += float64(x)
+		}
+		mean /= float64(len(X))
+	} else {
+		mean = math.NaN()
+	}
+
+	return columnSummary{
+		Mean:    jsonFloat(mean),
+		Median:  jsonFloat(orNaN(stats.Median(&X))),
+		Minimum: jsonFloat(orNaN(stats.Minimum(&X))),
+		Maximum: jsonFloat(orNaN(stats.Maximum(&X))),
+	}
+}
+
+func newCompressionStats(names []string, seqLen []int, cx []float64, cxx []float64) *compressionStats {
+	cs := &compressionStats{Taxa: make([]compressionTaxonStats, len(names))}
+	ratios := make([]float64, len(names))
+	selfNCD := make([]float64, len(names))
+	for i, name := range names {
+		ratios[i] = float64(seqLen[i]) / cx[i]
+		selfNCD[i] = ncd.NCD(cx[i], cx[i], cxx[i])
+		cs.Taxa[i] = compressionTaxonStats{name, seqLen[i], jsonFloat(cx[i]), jsonFloat(ratios[i]), jsonFloat(selfNCD[i])}
+	}
+	cs.Summary = compressionSummary{
+		Size:             summarize(seqLen),
+		CompressedSize:   summarize(cx),
+		CompressionRatio: summarize(ratios),
+		SelfNCD:          summarize(selfNCD),
+	}
+
+	return cs
+}
+
+func newMatrixStats(names []string, D *ncd.TriangularMatrix, md *ncd.MatrixDiagnostics) *matrixStats {
+	ms := &matrixStats{
+		Entries:          D.N * (D.N - 1) / 2,
+		OutOfRange:       make([]matrixEntry, len(md.OutOfRange)),
+		Triples:          md.NbTriples,
+		Violations:       md.NbViolations,
+		LargestViolation: make([]violationStats, len(md.Violations)),
+		Quartets:         md.NbQuartets,
+		SampledQuartets:  md.Sampled,
+		Delta:            jsonFloat(md.Delta),
+	}
+	for k, e := range md.OutOfRange {
+		ms.OutOfRange[k] = matrixEntry{[2]string{names[e.I], names[e.J]}, jsonFloat(e.Value)}
+	}
+	for k, v := range md.Violations {
+		ms.LargestViolation[k] = violationStats{
+			Taxa:     [2]string{names[v.I], names[v.J]},
+			Via:      names[v.K],
+			Distance: jsonFloat(D.Get(v.I, v.J)),
+			Path:     jsonFloat(D.Get(v.I, v.K) + D.Get(v.K, v.J)),
+			Excess:   jsonFloat(v.Excess),
+		}
+	}
+
+	return ms
+}
+
+// Compute the diagnostics of the taxa of a source, whose distances are in D and were checked in md
+func newDiagnosticsStats(names []string, source ncd.SequenceSource, D *ncd.TriangularMatrix, md *ncd.MatrixDiagnostics, distance string) (*diagnosticsStats, error) {
+	n := len(names)
+	ds := &diagnosticsStats{Distance: distance, Taxa: make([]taxonDiagnostics, n)}
+	gc := make([]float64, n)
+	ambiguous := make([]float64, n)
+	entropy := make([]float64, n)
+	meanDistance := make([]float64, n)
+	nearestDistance := make([]float64, n)
+
+	for i, name := range names {
+		seq, err := source.Sequence(i)
+		if err != nil {
+			return nil, err
+		}
+		gc[i] = fasta.GCContent(seq)
+		ambiguous[i] = fasta.AmbiguousFraction(seq)
+		entropy[i] = fasta.ShannonEntropy(seq)
+		meanDistance[i] = D.RowMean(i)
+		nearest, d := D.RowMin(i)
+		nearestDistance[i] = d
+
+		ds.Taxa[i] = taxonDiagnostics{
+			Taxon:             name,
+			GCContent:         jsonFloat(gc[i]),
+			AmbiguousFraction: jsonFloat(ambiguous[i]),
+			Entropy:           jsonFloat(entropy[i]),
+			MeanDistance:      jsonFloat(meanDistance[i]),
+			NearestDistance:   jsonFloat(d),
+			Delta:             jsonFloat(md.TaxonDelta[i]),
+		}
+		if nearest >= 0 {
+			ds.Taxa[i].NearestNeighbour = names[nearest]
+		}
+	}
+
+	median := orNaN(stats.Median(&meanDistance))
+	mad := orNaN(stats.MedianAbsoluteDeviation(&meanDistance))
+	threshold := median + outlierMADs*madScale*mad
+	ds.OutlierThreshold = jsonFloat(threshold)
+	for i := range ds.Taxa {
+		ds.Taxa[i].Outlier = meanDistance[i] > threshold
+	}
+
+	ds.Summary = diagnosticsSummary{
+		GCContent:         summarize(gc),
+		AmbiguousFraction: summarize(ambiguous),
+		Entropy:           summarize(entropy),
+		MeanDistance:      summarize(meanDistance),
+		NearestDistance:   summarize(nearestDistance),
+		Delta:             summarize(md.TaxonDelta),
+	}
+
+	return ds, nil
+}
+
+func newTreeFitStats(names []string, fit *phylocore.TreeFit) *treeFitStats {
+	fs := &treeFitStats{
+		CopheneticCorrelation: jsonFloat(fit.CopheneticCorrelation),
+		Stress:                jsonFloat(fit.Stress),
+		LargestResiduals:      make([]residualStats, len(fit.Residuals)),
+	}
+	for k, r := range fit.Residuals {
+		fs.LargestResiduals[k] = residualStats{
+			Taxa:      [2]string{names[r.I], names[r.J]},
+			Distance:  jsonFloat(r.Distance),
+			Patristic: jsonFloat(r.Patristic),
+			Residual:  jsonFloat(r.Residual),
+		}
+	}
+
+	return fs
+}
+
+// Compare the NCD matrices computed from concatenations and with dictionaries
+func newComparisonStats(names []string, concatenation *ncd.TriangularMatrix, conditional *ncd.TriangularMatrix) *comparisonStats {
+	var sumConcatenation, sumConditional, sumDiff, maxDiff float64
+	maxI, maxJ := 1, 0
+	nbPairs := 0
+	for i := range names {
+		for j := range i {
+			a, b := concatenation.Get(i, j), conditional.Get(i, j)
+			sumConcatenation += a
+			sumConditional += b
+			sumDiff += math.Abs(a - b)
+			if math.Abs(a-b) > maxDiff {
+				maxDiff, maxI, maxJ = math.Abs(a-b), i, j
+			}
+			nbPairs += 1
+		}
+	}
+
+	cs := &comparisonStats{Pairs: nbPairs}
+	if nbPairs == 0 {
+		return cs
+	}
+	n := float64(nbPairs)
+	cs.MeanConcatenation = jsonFloat(sumConcatenation / n)
+	cs.MeanConditional = jsonFloat(sumConditional / n)
+	cs.MeanAbsoluteDifference = jsonFloat(sumDiff / n)
+	cs.LargestDifference = jsonFloat(maxDiff)
+	cs.LargestDifferencePair = [2]string{names[maxI], names[maxJ]}
+	cs.LargestConcatenationNCD = jsonFloat(concatenation.Get(maxI, maxJ))
+	cs.LargestConditionalNCD = jsonFloat(conditional.Get(maxI, maxJ))
+
+	return cs
+}
+
+// Write the report in one of the statsFormats
+func (r *statsReport) write(w io.Writer, format string) error {
+	r.Run.ElapsedSeconds = jsonFloat(time.Since(r.started).Seconds())
+
+	switch format {
+	case "json":
+		encoder := json.NewEncoder(w)
+		encoder.SetIndent("", "  ")
+		return encoder.Encode(r)
+	case "tsv":
+		return r.writeTSV(w)
+	default:
+		r.writeText(w)
+		return nil
+	}
+}
+
+// Write the sections of the report as the tables printed by earlier versions
+func (r *statsReport) writeText(w io.Writer) {
+	if r.Filtering != nil {
+		writeFilterReport(w, r.Run.Taxa, r.Filtering)
+	}
+	if r.Sketches != nil {
+		fmt.Fprintln(w, "DISTANCE")
+		fmt.Fprintln(w, "========")
+		fmt.Fprintf(w, "Mash distance (k = %d, sketch size = %d, seed = %d)\n\n", r.Sketches.K, r.Sketches.Size, r.Sketches.Seed)
+		var extra []tableColumn
+		if r.Diagnostics != nil {
+			extra = diagnosticColumns(r.Diagnostics)
+		}
+		writeSketchTable(w, r.Sketches, extra)
+	}
+	if r.Compressor != nil {
+		fmt.Fprintln(w, "COMPRESSOR")
+		fmt.Fprintln(w, "==========")
+		fmt.Fprintln(w, "Compressor "+r.Compressor.Name)
+		fmt.Fprintln(w, "Settings   "+r.Compressor.ID)
+		fmt.Fprintln(w)
+	}
+	if r.Compression != nil {
+		fmt.Fprintln(w, "COMPRESSION METRICS")
+		fmt.Fprintln(w, "===================")
+		n := len(r.Compression.Taxa)
+		names := make([]string, n)
+		seqLen := make([]int, n)
+		cx := make([]float64, n)
+		selfNCD := make([]float64, n)
+		for i, t := range r.Compression.Taxa {
+			names[i], seqLen[i], cx[i], selfNCD[i] = t.Taxon, t.Size, float64(t.CompressedSize), float64(t.SelfNCD)
+		}
+		var extra []tableColumn
+		if r.Diagnostics != nil {
+			extra = diagnosticColumns(r.Diagnostics)
+		}
+		writeStatsTable(w, &names, &seqLen, &cx, &selfNCD, extra)
+		fmt.Fprintln(w)
+	}
+	if r.Diagnostics != nil {
+		writeOutliers(w, r.Diagnostics)
+	}
+	if r.Matrix != nil {
+		writeMatrixDiagnostics(w, r.Matrix, r.Diagnostics)
+	}
+	if r.Comparison != nil {
+		writeMatrixComparison(w, r.Comparison)
+	}
+	if r.Fit != nil {
+		writeTreeFit(w, r.Fit)
+	}
+	if r.SizeCache != nil {
+		fmt.Fprintln(w, "SIZE CACHE")
+		fmt.Fprintln(w, "==========")
+		fmt.Fprintf(w, "%d hits, %d misses, %d sizes in %s\n\n", r.SizeCache.Hits, r.SizeCache.Misses, r.SizeCache.Sizes, r.SizeCache.Path)
+	}
+}
+
+// Replace the characters that would break a TSV field
+func tsvField(s string) string {
+	return strings.NewReplacer("\t", " ", "\n", " ", "\r", " ").Replace(s)
+}
+
+/*
+Write the report as a long table with the columns section, item, field and value.
+
+The item is empty for the fields of a whole section, and is a taxon name for per-taxon values, or a column name for
+summary statistics. Fields that hold lists are written on several rows.
+*/
+func (r *statsReport) writeTSV(w io.Writer) error {
+	var err error
+	row := func(section string, item string, field string, value any) {
+		if err != nil {
+			return
+		}
+		var s string
+		switch v := value.(type) {
+		case jsonFloat:
+			s = strconv.FormatFloat(float64(v), 'g', -1, 64)
+		case float64:
+			s = strconv.FormatFloat(v, 'g', -1, 64)
+		default:
+			s = fmt.Sprint(v)
+		}
+		_, err = fmt.Fprintf(w, "%s\t%s\t%s\t%s\n", section, tsvField(item), field, tsvField(s))
+	}
+	summaryRows := func(section string, column string, cs columnSummary) {
+		row(section, column, "mean", cs.Mean)
+		row(section, column, "median", cs.Median)
+		row(section, column, "minimum", cs.Minimum)
+		row(section, column, "maximum", cs.Maximum)
+	}
+
+	row("section", "item", "field", "value")
+	row("report", "", "schema_version", r.SchemaVersion)
+
+	run := r.Run
+	row("run", "", "program", run.Program)
+	row("run", "", "version", run.Version)
+	row("run", "", "go_version", run.GoVersion)
+	row("run", "", "platform", run.Platform)
+	row("run", "", "arguments", strings.Join(run.Arguments, " "))
+	row("run", "", "started", run.Started)
+	row("run", "", "elapsed_seconds", run.ElapsedSeconds)
+	for _, input := range run.Inputs {
+		row("run", "", "input", input)
+	}
+	row("run", "", "taxon_mode", run.TaxonMode)
+	row("run", "", "distance", run.Distance)
+	row("run", "", "taxa", run.Taxa)
+	row("run", "", "out_matrix", run.OutMatrix)
+	if run.OutTree != "" {
+		row("run", "", "out_tree", run.OutTree)
+	}
+
+	if fs := r.Filtering; fs != nil {
+		row("filtering", "", "kept", fs.Kept)
+		for _, t := range fs.Removed {
+			row("filtering", t.Taxon, "removed", t.Reason)
+		}
+		for _, t := range fs.Collapsed {
+			row("filtering", t.Taxon, "identical_to", t.IdenticalTo)
+		}
+	}
+
+	if ss := r.Sketches; ss != nil {
+		row("sketches", "", "k", ss.K)
+		row("sketches", "", "size", ss.Size)
+		row("sketches", "", "seed", ss.Seed)
+		for _, t := range ss.Taxa {
+			row("sketches", t.Taxon, "size", t.Size)
+			row("sketches", t.Taxon, "hashes", t.Hashes)
+		}
+	}
+
+	if c := r.Compressor; c != nil {
+		row("compressor", "", "name", c.Name)
+		row("compressor", "", "id", c.ID)
+		row("compressor", "", "packed", c.Packed)
+		row("compressor", "", "conditional", c.Conditional)
+		for _, s := range strings.Split(c.ID, "/")[1:] {
+			if key, value, ok := strings.Cut(s, "="); ok {
+				row("compressor", "", "setting."+key, value)
+			}
+		}
+	}
+
+	if cs := r.Compression; cs != nil {
+		for _, t := range cs.Taxa {
+			row("compression", t.Taxon, "size", t.Size)
+			row("compression", t.Taxon, "compressed_size", t.CompressedSize)
+			row("compression", t.Taxon, "compression_ratio", t.CompressionRatio)
+			row("compression", t.Taxon, "self_ncd", t.SelfNCD)
+		}
+		summaryRows("compression_summary", "size", cs.Summary.Size)
+		summaryRows("compression_summary", "compressed_size", cs.Summary.CompressedSize)
+		summaryRows("compression_summary", "compression_ratio", cs.Summary.CompressionRatio)
+		summaryRows("compression_summary", "self_ncd", cs.Summary.SelfNCD)
+	}
+
+	if ds := r.Diagnostics; ds != nil {
+		row("diagnostics", "", "distance", ds.Distance)
+		row("diagnostics", "", "outlier_threshold", ds.OutlierThreshold)
+		for _, t := range ds.Taxa {
+			row("diagnostics", t.Taxon, "gc_content", t.GCContent)
+			row("diagnostics", t.Taxon, "ambiguous_fraction", t.AmbiguousFraction)
+			row("diagnostics", t.Taxon, "entropy", t.Entropy)
+			row("diagnostics", t.Taxon, "mean_distance", t.MeanDistance)
+			row("diagnostics", t.Taxon, "nearest_neighbour", t.NearestNeighbour)
+			row("diagnostics", t.Taxon, "nearest_distance", t.NearestDistance)
+			row("diagnostics", t.Taxon, "outlier", t.Outlier)
+			row("diagnostics", t.Taxon, "delta", t.Delta)
+		}
+		summaryRows("diagnostics_summary", "gc_content", ds.Summary.GCContent)
+		summaryRows("diagnostics_summary", "ambiguous_fraction", ds.Summary.AmbiguousFraction)
+		summaryRows("diagnostics_summary", "entropy", ds.Summary.Entropy)
+		summaryRows("diagnostics_summary", "mean_distance", ds.Summary.MeanDistance)
+		summaryRows("diagnostics_summary", "nearest_distance", ds.Summary.NearestDistance)
+		summaryRows("diagnostics_summary", "delta", ds.Summary.Delta)
+	}
+
+	if ms := r.Matrix; ms != nil {
+		row("matrix", "", "entries", ms.Entries)
+		row("matrix", "", "out_of_range", len(ms.OutOfRange))
+		for _, e := range ms.OutOfRange {
+			row("matrix", e.Taxa[0]+" / "+e.Taxa[1], "value", e.Value)
+		}
+		row("matrix", "", "triples", ms.Triples)
+		row("matrix", "", "triangle_violations", ms.Violations)
+		for _, v := range ms.LargestViolation {
+			item := v.Taxa[0] + " / " + v.Taxa[1]
+			row("matrix", item, "via", v.Via)
+			row("matrix", item, "distance", v.Distance)
+			row("matrix", item, "path", v.Path)
+			row("matrix", item, "excess", v.Excess)
+		}
+		row("matrix", "", "quartets", ms.Quartets)
+		row("matrix", "", "sampled_quartets", ms.SampledQuartets)
+		row("matrix", "", "delta", ms.Delta)
+	}
+
+	if cs := r.Comparison; cs != nil {
+		row("comparison", "", "pairs", cs.Pairs)
+		if cs.Pairs > 0 {
+			row("comparison", "", "mean_concatenation", cs.MeanConcatenation)
+			row("comparison", "", "mean_conditional", cs.MeanConditional)
+			row("comparison", "", "mean_absolute_difference", cs.MeanAbsoluteDifference)
+			row("comparison", "", "largest_difference", cs.LargestDifference)
+			row("comparison", "", "largest_difference_taxon", cs.LargestDifferencePair[0])
+			row("comparison", "", "largest_difference_taxon", cs.LargestDifferencePair[1])
+			row("comparison", "", "largest_difference_concatenation", cs.LargestConcatenationNCD)
+			row("comparison", "", "largest_difference_conditional", cs.LargestConditionalNCD)
+		}
+	}
+
+	if fs := r.Fit; fs != nil {
+		row("tree_fit", "", "cophenetic_correlation", fs.CopheneticCorrelation)
+		row("tree_fit", "", "stress", fs.Stress)
+		for _, res := range fs.LargestResiduals {
+			item := res.Taxa[0] + " / " + res.Taxa[1]
+			row("tree_fit", item, "distance", res.Distance)
+			row("tree_fit", item, "patristic", res.Patristic)
+			row("tree_fit", item, "residual", res.Residual)
+		}
+	}
+
+	if sc := r.SizeCache; sc != nil {
+		row("size_cache", "", "path", sc.Path)
+		row("size_cache", "", "hits", sc.Hits)
+		row("size_cache", "", "misses", sc.Misses)
+		row("size_cache", "", "sizes", sc.Sizes)
+	}
+
+	return err
+}
@@ -1,10 +1,12 @@
 package main
 
 import (
+	"cmp"
 	"fmt"
 	"io"
 	"math"
 	"ncdtree/pkg/stats"
+	"slices"
 	"strconv"
 	"strings"
 )
@@ -59,6 +61,15 @@ func fmtFloatField(x float64, precision int, maxWidth int) string {
 	return sci
 }
 
+// Return a statistic as a float64, or NaN if it could not be computed
+func orNaN[T stats.Numeric](x T, err error) float64 {
+	if err != nil {
+		return math.NaN()
+	}
+
+	return float64(x)
+}
+
 func padLeft(s string, width int) string {
 	if len(s) >= width {
 		return s
@@ -73,7 +84,91 @@ func padRight(s string, width int) string {
 	return s + strings.Repeat(" ", width-len(s))
 }
 
-func writeStatsTable(w io.Writer, taxonNames *[]string, seqLen *[]int, cx *[]float64, selfNCD *[]float64) {
+// Column appended to a table, with one cell per taxon and the mean, median, minimum and maximum (or nil)
+type tableColumn struct {
+	title   string
+	cells   []string
+	summary []string
+}
+
+func (c tableColumn) width() int {
+	w := max(len(c.title), findStringMaxWidth(&c.cells))
+	if c.summary != nil {
+		w = max(w, findStringMaxWidth(&c.summary))
+	}
+
+	return w
+}
+
+// Write the cell i of extra columns (the title if i is -1)
+func writeExtraCells(w io.Writer, extra []tableColumn, i int, gapString string) {
+	for _, c := range extra {
+		s := c.title
+		if i >= 0 {
+			s = c.cells[i]
+		}
+		fmt.Fprint(w, gapString+padRight(s, c.width()))
+	}
+}
+
+// Write the summary statistic k of extra columns
+func writeExtraSummary(w io.Writer, extra []tableColumn, k int, gapString string) {
+	for _, c := range extra {
+		s := ""
+		if c.summary != nil {
+			s = c.summary[k]
+		}
+		fmt.Fprint(w, gapString+padRight(s, c.width()))
+	}
+}
+
+// Make the columns of the per-taxon diagnostics
+func diagnosticColumns(d *diagnosticsStats) []tableColumn {
+	distance := "Dist"
+	if d.Distance == "ncd" {
+		distance = "NCD"
+	}
+
+	n := len(d.Taxa)
+	columns := []tableColumn{
+		{"GC", make([]string, n), nil},
+		{"Ambiguous", make([]string, n), nil},
+		{"Entropy", make([]string, n), nil},
+		{"Mean" + distance, make([]string, n), nil},
+		{"Nearest" + distance, make([]string, n), nil},
+		{"Delta", make([]string, n), nil},
+		{"Outlier", make([]string, n), nil},
+		{"NearestNeighbour", make([]string, n), nil},
+	}
+	for i, t := range d.Taxa {
+		columns[0].cells[i] = fmt.Sprintf("%.4f", t.GCContent)
+		columns[1].cells[i] = fmt.Sprintf("%.4f", t.AmbiguousFraction)
+		columns[2].cells[i] = fmt.Sprintf("%.4f", t.Entropy)
+		columns[3].cells[i] = fmt.Sprintf("%.6f", t.MeanDistance)
+		columns[4].cells[i] = fmt.Sprintf("%.6f", t.NearestDistance)
+		columns[5].cells[i] = fmt.Sprintf("%.4f", t.Delta)
+		if t.Outlier {
+			columns[6].cells[i] = "yes"
+		}
+		columns[7].cells[i] = t.NearestNeighbour
+	}
+
+	summaries := []columnSummary{d.Summary.GCContent, d.Summary.AmbiguousFraction, d.Summary.Entropy, d.Summary.MeanDistance, d.Summary.NearestDistance, d.Summary.Delta}
+	precisions := []int{4, 4, 4, 6, 6, 4}
+	for k, cs := range summaries {
+		columns[k].summary = []string{
+			fmt.Sprintf("%.*f", precisions[k], cs.Mean),
+			fmt.Sprintf("%.*f", precisions[k], cs.Median),
+			fmt.Sprintf("%.*f", precisions[k], cs.Minimum),
+			fmt.Sprintf("%.*f", precisions[k], cs.Maximum),
+		}
+	}
+
+	return columns
+}
+
+// Write the compression metrics of the taxa, followed by extra columns
+func writeStatsTable(w io.Writer, taxonNames *[]string, seqLen *[]int, cx *[]float64, selfNCD *[]float64, extra []tableColumn) {
 	colTitles := [6]string{"#", "Taxon", "Size", "CompressedSize", "CompressionRatio", "SelfNCD"}
 	colWidths := make(map[string]int, 6)
 	n := len(*taxonNames)
@@ -102,6 +197,9 @@ func writeStatsTable(w io.Writer, taxonNames *[]string, seqLen *[]int, cx *[]flo
 	for _, v := range colWidths {
 		tableWidth += v
 	}
+	for _, c := range extra {
+		tableWidth += fieldGapSize + c.width()
+	}
 
 	// Print header
 	isFirst := true
@@ -113,6 +211,7 @@ func writeStatsTable(w io.Writer, taxonNames *[]string, seqLen *[]int, cx *[]flo
 		}
 		fmt.Fprintf(w, "%-*s", colWidths[s], s)
 	}
+	writeExtraCells(w, extra, -1, gapString)
 
 	fmt.Fprint(w, "\n")
 
@@ -131,6 +230,7 @@ func writeStatsTable(w io.Writer, taxonNames *[]string, seqLen *[]int, cx *[]flo
 		s = padRight(fmtFloatField((*selfNCD)[i], 6, colWidths["SelfNCD"]), colWidths["SelfNCD"])
 		// fmt.Fprintf(w, "%-*.g", colWidths["SelfNCD"], (*selfNCD)[i])
 		fmt.Fprintf(w, "%s", s)
+		writeExtraCells(w, extra, i, gapString)
 		fmt.Fprintln(w)
 	}
 
@@ -156,48 +256,203 @@ func writeStatsTable(w io.Writer, taxonNames *[]string, seqLen *[]int, cx *[]flo
 	colStat = stats.MeanFloat64(selfNCD)
 	s = padRight(fmtFloatField(colStat, colWidths["SelfNCD"]-3, colWidths["SelfNCD"]), colWidths["SelfNCD"])
 	fmt.Fprintf(w, "%s", s)
+	writeExtraSummary(w, extra, 0, gapString)
 	fmt.Fprintln(w)
 
 	// Median
 	fmt.Fprintf(w, "%*s%s", colWidths["StatTitle"], "Median", gapString)
-	colStat = stats.Median(seqLen)
+	colStat = orNaN(stats.Median(seqLen))
 	fmt.Fprintf(w, "%-*g%s", colWidths["Size"], colStat, gapString)
-	colStat = stats.Median(cx)
+	colStat = orNaN(stats.Median(cx))
 	fmt.Fprintf(w, "%-*g%s", colWidths["CompressedSize"], colStat, gapString)
-	colStat = stats.Median(&compressionRatios)
+	colStat = orNaN(stats.Median(&compressionRatios))
 	s = padRight(fmtFloatField(colStat, 8, colWidths["CompressionRatio"]), colWidths["CompressionRatio"])
 	fmt.Fprintf(w, "%s%s", s, gapString)
-	colStat = stats.Median(selfNCD)
+	colStat = orNaN(stats.Median(selfNCD))
 	s = padRight(fmtFloatField(colStat, 6, colWidths["SelfNCD"]), colWidths["SelfNCD"])
 	fmt.Fprintf(w, "%s", s)
+	writeExtraSummary(w, extra, 1, gapString)
 	fmt.Fprintln(w)
 
 	// Minimum
 	fmt.Fprintf(w, "%*s%s", colWidths["StatTitle"], "Minimum", gapString)
-	colStat = float64(stats.Minimum(seqLen))
+	colStat = orNaN(stats.Minimum(seqLen))
 	fmt.Fprintf(w, "%-*g%s", colWidths["Size"], colStat, gapString)
-	colStat = stats.Minimum(cx)
+	colStat = orNaN(stats.Minimum(cx))
 	fmt.Fprintf(w, "%-*g%s", colWidths["CompressedSize"], colStat, gapString)
-	colStat = stats.Minimum(&compressionRatios)
+	colStat = orNaN(stats.Minimum(&compressionRatios))
 	s = padRight(fmtFloatField(colStat, 8, colWidths["CompressionRatio"]), colWidths["CompressionRatio"])
 	fmt.Fprintf(w, "%s%s", s, gapString)
-	colStat = stats.Minimum(selfNCD)
+	colStat = orNaN(stats.Minimum(selfNCD))
 	s = padRight(fmtFloatField(colStat, 6, colWidths["SelfNCD"]), colWidths["SelfNCD"])
 	fmt.Fprintf(w, "%s", s)
+	writeExtraSummary(w, extra, 2, gapString)
 	fmt.Fprintln(w)
 
 	// Maximum
 	fmt.Fprintf(w, "%*s%s", colWidths["StatTitle"], "Maximum", gapString)
-	colStat = float64(stats.Maximum(seqLen))
+	colStat = orNaN(stats.Maximum(seqLen))
 	fmt.Fprintf(w, "%-*g%s", colWidths["Size"], colStat, gapString)
-	colStat = stats.Maximum(cx)
+	colStat = orNaN(stats.Maximum(cx))
 	fmt.Fprintf(w, "%-*g%s", colWidths["CompressedSize"], colStat, gapString)
-	colStat = stats.Maximum(&compressionRatios)
+	colStat = orNaN(stats.Maximum(&compressionRatios))
 	s = padRight(fmtFloatField(colStat, 8, colWidths["CompressionRatio"]), colWidths["CompressionRatio"])
 	fmt.Fprintf(w, "%s%s", s, gapString)
-	colStat = stats.Maximum(selfNCD)
+	colStat = orNaN(stats.Maximum(selfNCD))
 	s = padRight(fmtFloatField(colStat, 6, colWidths["SelfNCD"]), colWidths["SelfNCD"])
 	fmt.Fprintf(w, "%s", s)
+	writeExtraSummary(w, extra, 3, gapString)
 	fmt.Fprintln(w)
 
 }
+
+// Write the lists of the taxa removed and collapsed by the sequence filter, out of nbTaxa
+func writeFilterReport(w io.Writer, nbTaxa int, fs *filterStats) {
+	fmt.Fprintln(w, "FILTERING")
+	fmt.Fprintln(w, "=========")
+	fmt.Fprintf(w, "Kept %d of %d taxa, removed %d, collapsed %d duplicates\n", fs.Kept, nbTaxa, len(fs.Removed), len(fs.Collapsed))
+
+	if len(fs.Removed) > 0 {
+		fmt.Fprintln(w, "\nRemoved taxa:")
+		for _, r := range fs.Removed {
+			fmt.Fprintf(w, "  %s\t%s\n", r.Taxon, r.Reason)
+		}
+	}
+
+	if len(fs.Collapsed) > 0 {
+		fmt.Fprintln(w, "\nCollapsed taxa:")
+		for _, c := range fs.Collapsed {
+			fmt.Fprintf(w, "  %s\tidentical to %s\n", c.Taxon, c.IdenticalTo)
+		}
+	}
+	fmt.Fprintln(w)
+}
+
+// Write the sizes of the sequences and the number of hashes in their sketches, followed by extra columns
+func writeSketchTable(w io.Writer, ss *sketchStats, extra []tableColumn) {
+	names := make([]string, len(ss.Taxa))
+	seqLen := make([]int, len(ss.Taxa))
+	for i, t := range ss.Taxa {
+		names[i], seqLen[i] = t.Taxon, t.Size
+	}
+	widthId := len(strconv.Itoa(len(names)))
+	widthName := max(len("Taxon"), findStringMaxWidth(&names))
+	widthSize := max(len("Size"), findIntMaxWidth(&seqLen))
+	gapString := "  "
+
+	widthHashes := max(len("Hashes"), len(strconv.Itoa(ss.Size)))
+
+	header := fmt.Sprintf("%-*s%s%-*s%s%-*s%s%-*s", widthId, "#", gapString, widthName, "Taxon", gapString, widthSize, "Size", gapString, widthHashes, "Hashes")
+	tableWidth := len(header)
+	for _, c := range extra {
+		tableWidth += len(gapString) + c.width()
+	}
+	fmt.Fprint(w, header)
+	writeExtraCells(w, extra, -1, gapString)
+	fmt.Fprintln(w)
+	fmt.Fprintln(w, strings.Repeat("—", tableWidth))
+	for i, t := range ss.Taxa {
+		fmt.Fprintf(w, "%-*d%s%-*s%s%-*d%s%-*d", widthId, i+1, gapString, widthName, t.Taxon, gapString, widthSize, t.Size, gapString, widthHashes, t.Hashes)
+		writeExtraCells(w, extra, i, gapString)
+		fmt.Fprintln(w)
+	}
+	fmt.Fprintln(w)
+}
+
+// Write the taxa whose mean distance to the other taxa is unusually high
+func writeOutliers(w io.Writer, d *diagnosticsStats) {
+	outliers := make([]taxonDiagnostics, 0)
+	for _, t := range d.Taxa {
+		if t.Outlier {
+			outliers = append(outliers, t)
+		}
+	}
+	if len(outliers) == 0 {
+		return
+	}
+
+	fmt.Fprintln(w, "OUTLIERS")
+	fmt.Fprintln(w, "========")
+	fmt.Fprintf(w, "Taxa with a mean distance above %.6f (median + %g scaled MAD):\n", d.OutlierThreshold, outlierMADs)
+	for _, t := range outliers {
+		fmt.Fprintf(w, "  %s\t%.6f\n", t.Taxon, t.MeanDistance)
+	}
+	fmt.Fprintln(w)
+}
+
+// Write the checks of the distance matrix, and the taxa with the highest delta scores if d is not nil
+func writeMatrixDiagnostics(w io.Writer, ms *matrixStats, d *diagnosticsStats) {
+	fmt.Fprintln(w, "MATRIX DIAGNOSTICS")
+	fmt.Fprintln(w, "==================")
+	percent := func(k int, n int) float64 {
+		if n == 0 {
+			return 0.0
+		}
+		return 100.0 * float64(k) / float64(n)
+	}
+	fmt.Fprintf(w, "Values outside [0, 1]           %d of %d (%.2f%%)\n", len(ms.OutOfRange), ms.Entries, percent(len(ms.OutOfRange), ms.Entries))
+	fmt.Fprintf(w, "Triangle inequality violations  %d of %d triples (%.2f%%)\n", ms.Violations, ms.Triples, percent(ms.Violations, ms.Triples))
+	sampled := ""
+	if ms.SampledQuartets {
+		sampled = ", sampled"
+	}
+	fmt.Fprintf(w, "Delta score                     %.4f (%d quartets%s; 0 for a tree, higher values are less tree-like)\n", ms.Delta, ms.Quartets, sampled)
+
+	if len(ms.OutOfRange) > 0 {
+		fmt.Fprintln(w, "\nValues outside [0, 1]:")
+		for _, e := range ms.OutOfRange {
+			fmt.Fprintf(w, "  %s, %s\t%.6f\n", e.Taxa[0], e.Taxa[1], e.Value)
+		}
+	}
+
+	if len(ms.LargestViolation) > 0 {
+		fmt.Fprintln(w, "\nLargest violations:")
+		for _, v := range ms.LargestViolation {
+			fmt.Fprintf(w, "  %s, %s\t%.6f > %.6f via %s\n", v.Taxa[0], v.Taxa[1], v.Distance, v.Path, v.Via)
+		}
+	}
+
+	if d != nil && ms.Quartets > 0 {
+		taxa := slices.Clone(d.Taxa)
+		slices.SortStableFunc(taxa, func(a, b taxonDiagnostics) int {
+			return cmp.Compare(b.Delta, a.Delta)
+		})
+		fmt.Fprintln(w, "\nHighest delta scores:")
+		for _, t := range taxa[:min(5, len(taxa))] {
+			fmt.Fprintf(w, "  %s\t%.4f\n", t.Taxon, t.Delta)
+		}
+	}
+	fmt.Fprintln(w)
+}
+
+// Write the differences between the NCD matrices computed from concatenations and with dictionaries
+func writeMatrixComparison(w io.Writer, cs *comparisonStats) {
+	fmt.Fprintln(w, "CONCATENATION VS. CONDITIONAL NCD")
+	fmt.Fprintln(w, "=================================")
+	if cs.Pairs == 0 {
+		fmt.Fprintln(w)
+		return
+	}
+
+	fmt.Fprintf(w, "Mean NCD from concatenations  %.6f\n", cs.MeanConcatenation)
+	fmt.Fprintf(w, "Mean NCD with dictionaries    %.6f\n", cs.MeanConditional)
+	fmt.Fprintf(w, "Mean absolute difference      %.6f\n", cs.MeanAbsoluteDifference)
+	fmt.Fprintf(w, "Largest absolute difference   %.6f (%s, %s: %.6f vs. %.6f)\n\n",
+		cs.LargestDifference, cs.LargestDifferencePair[0], cs.LargestDifferencePair[1], cs.LargestConcatenationNCD, cs.LargestConditionalNCD)
+}
+
+// Write how well the patristic distances of the tree fit the distance matrix
+func writeTreeFit(w io.Writer, fs *treeFitStats) {
+	fmt.Fprintln(w, "TREE FIT")
+	fmt.Fprintln(w, "========")
+	fmt.Fprintf(w, "Cophenetic correlation  %.6f\n", fs.CopheneticCorrelation)
+	fmt.Fprintf(w, "Stress                  %.6f (0 for a perfect fit)\n", fs.Stress)
+
+	if len(fs.LargestResiduals) > 0 {
+		fmt.Fprintln(w, "\nLargest residuals (patristic - matrix distance):")
+		for _, r := range fs.LargestResiduals {
+			fmt.Fprintf(w, "  %s, %s\t%+.6f (%.6f on the tree, %.6f in the matrix)\n", r.Taxa[0], r.Taxa[1], r.Residual, r.Patristic, r.Distance)
+		}
+	}
+	fmt.Fprintln(w)
+}
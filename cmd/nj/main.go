@@ -2,19 +2,77 @@ package main
 
 import (
 	"bufio"
-	"fmt"
 	"ncdtree/pkg/phylocore"
+	"ncdtree/pkg/sysexits"
 	"os"
+	"slices"
+	"strings"
+
+	"github.com/akamensky/argparse"
 )
 
 func main() {
+	parser := argparse.NewParser(
+		"nj",
+		"Estimate a neighbour-joining tree from a distance matrix and print it to stdout. The matrix is read from the file "+
+			"given before the options, as in \"nj MATRIX [options]\", or from stdin if there is none",
+	)
+	argRename := parser.String(
+		"", "rename",
+		&argparse.Options{Required: false, Help: "Tab-separated file of taxon names (first column) to replace by other names (second column), e.g. the taxon_names.tsv of ncdtree to restore the original names"},
+	)
+	argTreeFormat := parser.Selector(
+		"", "tree-format",
+		phylocore.TreeFormatNames,
+		&argparse.Options{Required: false, Default: "newick", Help: "Format of the tree"},
+	)
+	argCanonical := parser.Flag(
+		"", "canonical",
+		&argparse.Options{Required: false, Help: "Root the tree next to the first taxon in alphabetical order, and order the children of each node from the smallest to the largest clade, and by taxon names for clades of the same size, so that the same tree is always written the same way"},
+	)
+	argShowTree := parser.Flag(
+		"", "show-tree",
+		&argparse.Options{Required: false, Help: "Draw the tree with box-drawing characters instead of printing it in --tree-format"},
+	)
+	argCladogram := parser.Flag(
+		"", "cladogram",
+		&argparse.Options{Required: false, Help: "--show-tree: Ignore the branch lengths and align the tips"},
+	)
+	argTreeWidth := parser.Int(
+		"", "tree-width",
+		&argparse.Options{Required: false, Default: 0, Help: "--show-tree: Width of the drawing in characters (the COLUMNS environment variable, or 80, if 0)"},
+	)
+	argShowLengths := parser.Flag(
+		"", "show-lengths",
+		&argparse.Options{Required: false, Help: "--show-tree: Write the lengths on the branches that are long enough"},
+	)
+	argLadderize := parser.Flag(
+		"", "ladderize",
+		&argparse.Options{Required: false, Help: "--show-tree: Draw the children of each node from the smallest to the largest clade"},
+	)
+
+	// The matrix file is taken before parsing, as argparse would list a positional argument among the options
+	args := os.Args
+	infile := ""
+	if len(args) > 1 && !strings.HasPrefix(args[1], "-") {
+		infile = args[1]
+		args = slices.Delete(slices.Clone(args), 1, 2)
+	}
+
+	err := parser.Parse(args)
+	if err != nil {
+		sysexits.ExitMsg(parser.Usage(err), sysexits.Usage)
+	}
+	if *argTreeWidth < 0 {
+		sysexits.ExitMsg("--tree-width must not be negative", sysexits.Usage)
+	}
+
 	var input *os.File
-	var err error
 
-	if len(os.Args) > 1 {
-		input, err = os.Open(os.Args[1])
+	if infile != "" {
+		input, err = os.Open(infile)
 		if err != nil {
-			panic(err)
+			sysexits.Exit(err, sysexits.NoInput)
 		}
 		defer input.Close()
 	} else {
@@ -25,10 +83,53 @@ func main() {
 
 	taxa, d, err := phylocore.ReadDistanceMatrix(scanner)
 	if err != nil {
-		panic(err)
+		sysexits.Exit(err, sysexits.InputCode(err))
+	}
+
+	treeFormat, err := phylocore.ParseTreeFormat(*argTreeFormat)
+	if err != nil {
+		sysexits.Exit(err, sysexits.Usage)
+	}
+
+	if *argRename != "" {
+		mapFile, err := os.Open(*argRename)
+		if err != nil {
+			sysexits.Exit(err, sysexits.NoInput)
+		}
+		nameMap, err := phylocore.ReadNameMap(mapFile)
+		mapFile.Close()
+		if err != nil {
+			sysexits.Exit(err, sysexits.InputCode(err))
+		}
+		names, err := nameMap.Rename(taxa.Names)
+		if err != nil {
+			sysexits.Exit(err, sysexits.DataErr)
+		}
+		taxa, err = phylocore.NewTaxonSet(names)
+		if err != nil {
+			sysexits.Exit(err, sysexits.DataErr)
+		}
 	}
 
-	tree := phylocore.NeighbourJoining(taxa, d)
+	tree, err := phylocore.NeighbourJoining(taxa, d)
+	if err != nil {
+		sysexits.Exit(err, sysexits.DataErr)
+	}
+	if *argCanonical {
+		tree.Canonicalize()
+	}
 
-	fmt.Print(tree.NewickString(), "\n")
+	if *argShowTree {
+		err = tree.WriteText(os.Stdout, phylocore.TextPlotOptions{
+			Width:     *argTreeWidth,
+			Cladogram: *argCladogram,
+			Lengths:   *argShowLengths,
+			Ladderize: *argLadderize,
+		})
+	} else {
+		err = tree.Write(os.Stdout, taxa, treeFormat)
+	}
+	if err != nil {
+		sysexits.Exit(err, sysexits.IOErr)
+	}
 }
@@ -5,3 +5,5 @@ go 1.24.3
 require github.com/google/brotli/go/cbrotli v1.1.0
 
 require github.com/akamensky/argparse v1.4.0
+
+require github.com/klauspost/compress v1.18.0
@@ -0,0 +1,75 @@
+package fasta
+
+import "math"
+
+/*
+Return the fraction of ambiguous bases in a nucleotide sequence, i.e. of the characters other than A, C, G, T and U
+in either case (N, IUPAC ambiguity codes, gaps, etc.). Returns 0 for an empty sequence.
+*/
+func AmbiguousFraction(seq []byte) float64 {
+	if len(seq) == 0 {
+		return 0.0
+	}
+
+	nbAmbiguous := 0
+	for _, b := range seq {
+		switch b | 0x20 { // Lower case
+		case 'a', 'c', 'g', 't', 'u':
+		default:
+			nbAmbiguous += 1
+		}
+	}
+
+	return float64(nbAmbiguous) / float64(len(seq))
+}
+
+/*
+Return the fraction of G and C among the unambiguous bases (A, C, G, T and U in either case) of a nucleotide
+sequence. Returns 0 if there is no unambiguous base.
+*/
+func GCContent(seq []byte) float64 {
+	nbGC, nbUnambiguous := 0, 0
+	for _, b := range seq {
+		switch b | 0x20 {
+		case 'g', 'c':
+			nbGC += 1
+			nbUnambiguous += 1
+		case 'a', 't', 'u':
+			nbUnambiguous += 1
+		}
+	}
+	if nbUnambiguous == 0 {
+		return 0.0
+	}
+
+	return float64(nbGC) / float64(nbUnambiguous)
+}
+
+/*
+Return the Shannon entropy in bits per character of a sequence, from the frequencies of its characters regardless of
+case. It is 2 for a random DNA sequence and lower for repetitive or biased ones. Returns 0 for an empty sequence.
+*/
+func ShannonEntropy(seq []byte) float64 {
+	if len(seq) == 0 {
+		return 0.0
+	}
+
+	var counts [256]int
+	for _, b := range seq {
+		if 'A' <= b && b <= 'Z' {
+			b |= 0x20
+		}
+		counts[b] += 1
+	}
+
+	n := float64(len(seq))
+	entropy := 0.0
+	for _, c := range counts {
+		if c > 0 {
+			p := float64(c) / n
+			entropy -= p * math.Log2(p)
+		}
+	}
+
+	return entropy
+}
@@ -0,0 +1,61 @@
+package fasta
+
+import (
+	"testing"
+)
+
+func TestAmbiguousFraction(t *testing.T) {
+	tests := []struct {
+		seq  string
+		want float64
+	}{
+		{"", 0},
+		{"ACGTacgtUu", 0},
+		{"ACGN", 0.25},
+		{"RYKM-nnnn", 1},
+	}
+
+	for _, tt := range tests {
+		if got := AmbiguousFraction([]byte(tt.seq)); got != tt.want {
+			t.Errorf("AmbiguousFraction(%q) = %v, want %v", tt.seq, got, tt.want)
+		}
+	}
+}
+
+func TestGCContent(t *testing.T) {
+	tests := []struct {
+		seq  string
+		want float64
+	}{
+		{"", 0},
+		{"NNNN", 0},
+		{"ACGT", 0.5},
+		{"gcGCat", 4.0 / 6.0},
+		{"GCNN", 1},
+	}
+
+	for _, tt := range tests {
+		if got := GCContent([]byte(tt.seq)); got != tt.want {
+			t.Errorf("GCContent(%q) = %v, want %v", tt.seq, got, tt.want)
+		}
+	}
+}
+
+func TestShannonEntropy(t *testing.T) {
+	tests := []struct {
+		seq  string
+		want float64
+	}{
+		{"", 0},
+		{"AAAA", 0},
+		{"AaAa", 0},
+		{"ACGT", 2},
+		{"AACC", 1},
+	}
+
+	for _, tt := range tests {
+		if got := ShannonEntropy([]byte(tt.seq)); got != tt.want {
+			t.Errorf("ShannonEntropy(%q) = %v, want %v", tt.seq, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,102 @@
+package fasta
+
+import (
+	"bufio"
+	"bytes"
+	"compress/bzip2"
+	"compress/gzip"
+	"errors"
+	"io"
+
+	"github.com/klauspost/compress/zstd"
+)
+
+// Compression format of an input stream
+type Compression int
+
+const (
+	Uncompressed Compression = iota
+	Gzip
+	Bzip2
+	Zstd
+)
+
+func (c Compression) String() string {
+	switch c {
+	case Gzip:
+		return "gzip"
+	case Bzip2:
+		return "bzip2"
+	case Zstd:
+		return "zstd"
+	default:
+		return "uncompressed"
+	}
+}
+
+var (
+	magicGzip  = []byte{0x1f, 0x8b}
+	magicBzip2 = []byte("BZh")
+	magicZstd  = []byte{0x28, 0xb5, 0x2f, 0xfd}
+)
+
+/*
+Detect the compression format of a stream from its magic bytes, without consuming any data.
+
+Streams that are too short to hold any magic bytes are reported as uncompressed.
+*/
+func DetectCompression(reader *bufio.Reader) (Compression, error) {
+	head, err := reader.Peek(len(magicZstd))
+	if err != nil && !errors.Is(err, io.EOF) {
+		return Uncompressed, err
+	}
+
+	switch {
+	case bytes.HasPrefix(head, magicGzip):
+		return Gzip, nil
+	case bytes.HasPrefix(head, magicBzip2):
+		return Bzip2, nil
+	case bytes.HasPrefix(head, magicZstd):
+		return Zstd, nil
+	default:
+		return Uncompressed, nil
+	}
+}
+
+type zstdReadCloser struct {
+	*zstd.Decoder
+}
+
+func (z zstdReadCloser) Close() error {
+	z.Decoder.Close()
+	return nil
+}
+
+/*
+Wrap a stream into a reader that decompresses it on the fly if it is compressed with gzip, bzip2 or zstd.
+
+Uncompressed streams are passed through. Closing the returned reader does not close the underlying stream.
+*/
+func NewDecompressingReader(r io.Reader) (io.ReadCloser, Compression, error) {
+	reader := bufio.NewReader(r)
+	compression, err := DetectCompression(reader)
+	if err != nil {
+		return nil, compression, err
+	}
+
+	switch compression {
+	case Gzip:
+		gz, err := gzip.NewReader(reader)
+		return gz, compression, err
+	case Bzip2:
+		return io.NopCloser(bzip2.NewReader(reader)), compression, nil
+	case Zstd:
+		zr, err := zstd.NewReader(reader, zstd.WithDecoderConcurrency(1))
+		if err != nil {
+			return nil, compression, err
+		}
+		return zstdReadCloser{zr}, compression, nil
+	default:
+		return io.NopCloser(reader), compression, nil
+	}
+}
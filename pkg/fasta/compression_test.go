@@ -0,0 +1,65 @@
+package fasta
+
+import (
+	"bytes"
+	"compress/gzip"
+	"io"
+	"testing"
+
+	"github.com/klauspost/compress/zstd"
+)
+
+const testFasta = ">a\nACGT\n>b\nGGCC\n"
+
+func TestNewDecompressingReader(t *testing.T) {
+	var gzBuf bytes.Buffer
+	gw := gzip.NewWriter(&gzBuf)
+	gw.Write([]byte(testFasta))
+	gw.Close()
+
+	var zstdBuf bytes.Buffer
+	zw, _ := zstd.NewWriter(&zstdBuf)
+	zw.Write([]byte(testFasta))
+	zw.Close()
+
+	// bzip2 of testFasta, as the standard library has no bzip2 compressor
+	bz2 := []byte{
+		0x42, 0x5a, 0x68, 0x39, 0x31, 0x41, 0x59, 0x26, 0x53, 0x59, 0xdf, 0xb5,
+		0x08, 0x10, 0x00, 0x00, 0x02, 0x4f, 0x00, 0x00, 0x10, 0x00, 0x01, 0x28,
+		0x80, 0x04, 0x00, 0x30, 0x00, 0x20, 0x00, 0x31, 0x0c, 0x08, 0x20, 0x62,
+		0x7a, 0x8a, 0x70, 0xed, 0x86, 0x25, 0xa5, 0x78, 0xbb, 0x92, 0x29, 0xc2,
+		0x84, 0x86, 0xfd, 0xa8, 0x40, 0x80,
+	}
+
+	tests := []struct {
+		name  string
+		input []byte
+		want  Compression
+	}{
+		{"plain", []byte(testFasta), Uncompressed},
+		{"empty", []byte{}, Uncompressed},
+		{"gzip", gzBuf.Bytes(), Gzip},
+		{"bzip2", bz2, Bzip2},
+		{"zstd", zstdBuf.Bytes(), Zstd},
+	}
+
+	for _, tt := range tests {
+		r, compression, err := NewDecompressingReader(bytes.NewReader(tt.input))
+		if err != nil {
+			t.Errorf("%s: unexpected error: %v", tt.name, err)
+			continue
+		}
+		if compression != tt.want {
+			t.Errorf("%s: compression = %v, want %v", tt.name, compression, tt.want)
+		}
+		got, err := io.ReadAll(r)
+		r.Close()
+		if err != nil {
+			t.Errorf("%s: unexpected read error: %v", tt.name, err)
+			continue
+		}
+		if len(tt.input) > 0 && string(got) != testFasta {
+			t.Errorf("%s: got %q, want %q", tt.name, got, testFasta)
+		}
+	}
+}
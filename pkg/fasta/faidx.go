@@ -0,0 +1,244 @@
+package fasta
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+)
+
+/*
+Entry of a FASTA index (.fai), in the format of samtools faidx.
+
+The sequence of a record starts at Offset in the file, in lines of LineBases bases that take LineWidth bytes with the
+line terminator. Only the last line of a record can be shorter.
+*/
+type FaiEntry struct {
+	Name      string
+	Length    int
+	Offset    int64
+	LineBases int
+	LineWidth int
+}
+
+// Return the path of the index of a FASTA file
+func IndexPath(path string) string {
+	return path + ".fai"
+}
+
+/*
+Build the index of an uncompressed FASTA stream.
+
+Returns an error if the lines of a record do not all have the same length (except for the last one), or if record
+names are duplicated.
+*/
+func BuildIndex(r io.Reader) ([]FaiEntry, error) {
+	reader := bufio.NewReader(r)
+	entries := make([]FaiEntry, 0)
+	names := make(map[string]bool)
+	var offset int64
+	var entry *FaiEntry
+	lastLineShort := false // A shorter (last) line was read in the current record
+	lineNo := 0
+
+	for {
+		line, err := reader.ReadString('\n')
+		if err != nil && err != io.EOF {
+			return nil, err
+		}
+		if len(line) == 0 {
+			break
+		}
+		lineNo += 1
+		lineStart := offset
+		offset += int64(len(line))
+		bases := len(strings.TrimRight(line, "\r\n"))
+
+		if strings.HasPrefix(line, ">") {
+			id, _ := splitHeader(strings.TrimSpace(line[1:]))
+			if id == "" {
+				return nil, fmt.Errorf("empty Fasta descriptor in line %d", lineNo)
+			}
+			if names[id] {
+				return nil, fmt.Errorf("duplicated identifier in Fasta file: %s", id)
+			}
+			names[id] = true
+			entries = append(entries, FaiEntry{Name: id, Offset: offset})
+			entry = &entries[len(entries)-1]
+			lastLineShort = false
+			continue
+		}
+
+		if bases == 0 {
+			if entry != nil {
+				lastLineShort = true
+			}
+			continue
+		}
+		if entry == nil {
+			return nil, fmt.Errorf("sequence before the first header in line %d", lineNo)
+		}
+		if lastLineShort {
+			return nil, fmt.Errorf("record \"%s\" has lines of different lengths (line %d)", entry.Name, lineNo)
+		}
+
+		width := int(offset - lineStart)
+		// The last line may lack its terminator
+		terminated := strings.HasSuffix(line, "\n")
+
+		if entry.LineBases == 0 {
+			entry.LineBases = bases
+			entry.LineWidth = width
+		} else if bases > entry.LineBases || (bases == entry.LineBases && terminated && width != entry.LineWidth) {
+			return nil, fmt.Errorf("record \"%s\" has lines of different lengths (line %d)", entry.Name, lineNo)
+		} else if bases < entry.LineBases {
+			lastLineShort = true
+		}
+		entry.Length += bases
+	}
+
+	return entries, nil
+}
+
+// Write index entries in the tab-separated .fai format
+func WriteIndex(w io.Writer, entries []FaiEntry) error {
+	writer := bufio.NewWriter(w)
+	for _, e := range entries {
+		fmt.Fprintf(writer, "%s\t%d\t%d\t%d\t%d\n", e.Name, e.Length, e.Offset, e.LineBases, e.LineWidth)
+	}
+
+	return writer.Flush()
+}
+
+// Read index entries in the tab-separated .fai format
+func ReadIndex(r io.Reader) ([]FaiEntry, error) {
+	scanner := bufio.NewScanner(r)
+	entries := make([]FaiEntry, 0)
+	lineNo := 0
+
+	for scanner.Scan() {
+		lineNo += 1
+		if strings.TrimSpace(scanner.Text()) == "" {
+			continue
+		}
+		fields := strings.Split(scanner.Text(), "\t")
+		if len(fields) < 5 {
+			return nil, fmt.Errorf("expected 5 fields in line %d of the index, found %d", lineNo, len(fields))
+		}
+
+		var e FaiEntry
+		var errs [4]error
+		e.Name = fields[0]
+		e.Length, errs[0] = strconv.Atoi(fields[1])
+		e.Offset, errs[1] = strconv.ParseInt(fields[2], 10, 64)
+		e.LineBases, errs[2] = strconv.Atoi(fields[3])
+		e.LineWidth, errs[3] = strconv.Atoi(fields[4])
+		if err := errors.Join(errs[:]...); err != nil {
+			return nil, fmt.Errorf("invalid number in line %d of the index: %w", lineNo, err)
+		}
+		if e.Length > 0 && (e.LineBases <= 0 || e.LineWidth < e.LineBases) {
+			return nil, fmt.Errorf("invalid line length in line %d of the index", lineNo)
+		}
+		entries = append(entries, e)
+	}
+
+	return entries, scanner.Err()
+}
+
+// Build the index of a FASTA file and write it next to the file
+func CreateIndex(path string) error {
+	input, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer input.Close()
+
+	entries, err := BuildIndex(input)
+	if err != nil {
+		return fmt.Errorf("%s: %w", path, err)
+	}
+
+	output, err := os.Create(IndexPath(path))
+	if err != nil {
+		return err
+	}
+	if err := WriteIndex(output, entries); err != nil {
+		output.Close()
+		return err
+	}
+
+	return output.Close()
+}
+
+/*
+FASTA file with random access to its sequences through its index.
+
+Sequences are read from the file on demand, so that only the index is held in memory.
+*/
+type IndexedFasta struct {
+	file    *os.File
+	Entries []FaiEntry
+}
+
+// Open a FASTA file and read its index, which must exist (see CreateIndex)
+func OpenIndexedFasta(path string) (*IndexedFasta, error) {
+	index, err := os.Open(IndexPath(path))
+	if err != nil {
+		return nil, err
+	}
+	defer index.Close()
+
+	entries, err := ReadIndex(index)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", IndexPath(path), err)
+	}
+
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+
+	return &IndexedFasta{file, entries}, nil
+}
+
+// Return the number of sequences
+func (f *IndexedFasta) Len() int {
+	return len(f.Entries)
+}
+
+// Read the i-th sequence from the file
+func (f *IndexedFasta) Sequence(i int) ([]byte, error) {
+	e := f.Entries[i]
+	if e.Length == 0 {
+		return []byte{}, nil
+	}
+
+	// Bytes from the first base to the last base, line terminators included
+	last := e.Length - 1
+	size := (last/e.LineBases)*e.LineWidth + last%e.LineBases + 1
+	raw := make([]byte, size)
+	if _, err := f.file.ReadAt(raw, e.Offset); err != nil {
+		return nil, fmt.Errorf("reading \"%s\" from %s: %w", e.Name, f.file.Name(), err)
+	}
+
+	seq := raw
+	if e.LineWidth > e.LineBases {
+		seq = make([]byte, 0, e.Length)
+		for line := range bytes.Lines(raw) {
+			seq = append(seq, bytes.TrimRight(line, "\r\n")...)
+		}
+	}
+	if len(seq) != e.Length {
+		return nil, fmt.Errorf("index of %s does not match the file for \"%s\"", f.file.Name(), e.Name)
+	}
+
+	return seq, nil
+}
+
+func (f *IndexedFasta) Close() error {
+	return f.file.Close()
+}
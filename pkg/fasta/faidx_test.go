@@ -0,0 +1,85 @@
+package fasta
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestBuildIndex(t *testing.T) {
+	input := ">a desc\nACGT\nACGT\nAC\n>b\r\nGGG\r\nT\r\n\n>c\n>d\nAAAA"
+	want := []FaiEntry{
+		{"a", 10, 8, 4, 5},
+		{"b", 4, 25, 3, 5},
+		{"c", 0, 37, 0, 0},
+		{"d", 4, 40, 4, 4},
+	}
+
+	got, err := BuildIndex(strings.NewReader(input))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("got %+v, want %+v", got, want)
+	}
+
+	var buf bytes.Buffer
+	if err := WriteIndex(&buf, got); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	read, err := ReadIndex(&buf)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if !reflect.DeepEqual(read, want) {
+		t.Errorf("round trip: got %+v, want %+v", read, want)
+	}
+}
+
+func TestBuildIndex_Errors(t *testing.T) {
+	inputs := []string{
+		">a\nACG\nACGT\n",    // Longer line after the first
+		">a\nACGT\nAC\nAC\n", // Short line before the last
+		">a\nACGT\n\nACGT\n", // Blank line within the sequence
+		">a\nAC\n>a\nGT\n",   // Duplicate name
+		"ACGT\n>a\nAC\n",     // Sequence before the first header
+		">a\nACGT\r\nACGT\n", // Mixed line terminators
+	}
+
+	for _, input := range inputs {
+		if _, err := BuildIndex(strings.NewReader(input)); err == nil {
+			t.Errorf("%q: expected error, got nil", input)
+		}
+	}
+}
+
+func TestIndexedFasta(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "seqs.fa")
+	input := ">a\nACGTA\nCGTAC\nGT\n>b\r\nGGGG\r\nTT\r\n>c\n>d\nACGTA"
+	if err := os.WriteFile(path, []byte(input), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := CreateIndex(path); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	f, err := OpenIndexedFasta(path)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	defer f.Close()
+
+	want := []string{"ACGTACGTACGT", "GGGGTT", "", "ACGTA"}
+	if f.Len() != len(want) {
+		t.Fatalf("got %d sequences, want %d", f.Len(), len(want))
+	}
+	for i := range want {
+		seq, err := f.Sequence(i)
+		if err != nil || string(seq) != want[i] {
+			t.Errorf("sequence %d: got %q, %v, want %q", i, seq, err, want[i])
+		}
+	}
+}
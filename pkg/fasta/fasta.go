@@ -2,67 +2,190 @@ package fasta
 
 import (
 	"bufio"
+	"bytes"
 	"errors"
 	"fmt"
 	"io"
+	"iter"
 	"strings"
+	"unicode"
 )
 
-// The id is the string after '>' up to the first whitespace.
-// Sequences are concatenated with newlines removed.
-func ReadFasta(reader *bufio.Reader) (*[]string, *[][]byte, error) {
-	nameList := make([]string, 0)
-	fastaStrings := make([][]byte, 0)
-	nameSet := make(map[string]int) // Set for checking duplicates of identifiers, with dummy int values
-	var curID string
-	var sb strings.Builder
-
-	flush := func() error {
-		if curID != "" {
-			fastaStrings = append(fastaStrings, []byte(sb.String()))
-			nameList = append(nameList, curID)
-			_, ok := nameSet[curID]
-			if ok {
-				return errors.New("duplicated identifier in Fasta file: " + curID)
+// A sequence record of a FASTA file
+type Record struct {
+	ID          string // The header up to the first whitespace
+	Description string // The rest of the header, without leading and trailing whitespace
+	Seq         []byte
+}
+
+// Return the header of the record, without the leading '>'
+func (rec *Record) Header() string {
+	if rec.Description == "" {
+		return rec.ID
+	}
+
+	return rec.ID + " " + rec.Description
+}
+
+/*
+Streaming reader of FASTA records.
+
+Sequences are concatenated with newlines and surrounding whitespace removed. Lines before the first header are
+ignored.
+*/
+type Reader struct {
+	lineReader
+	header string // Header line of the next record, without the '>'
+	done   bool
+}
+
+// Create a FASTA reader, which buffers the input stream unless it is already a *bufio.Reader
+func NewReader(r io.Reader) *Reader {
+	return &Reader{lineReader: newLineReader(r)}
+}
+
+// Keep a header line for the next record
+func (fr *Reader) setHeader(line string) error {
+	fr.header = strings.TrimSpace(line[1:])
+	if fr.header == "" {
+		return fmt.Errorf("empty Fasta descriptor in line %d: %q", fr.lineNo, line)
+	}
+
+	return nil
+}
+
+// Split a header into the identifier and the description
+func splitHeader(header string) (string, string) {
+	i := strings.IndexFunc(header, unicode.IsSpace)
+	if i < 0 {
+		return header, ""
+	}
+
+	return header[:i], strings.TrimSpace(header[i:])
+}
+
+/*
+Read the next record.
+
+Returns io.EOF when there are no more records.
+*/
+func (fr *Reader) Read() (*Record, error) {
+	if fr.done {
+		return nil, io.EOF
+	}
+
+	// Find the first header
+	for fr.header == "" {
+		line, err := fr.readLine()
+		if err != nil {
+			fr.done = true
+			return nil, err
+		}
+		if len(line) > 0 && line[0] == '>' {
+			if err := fr.setHeader(line); err != nil {
+				return nil, err
 			}
-			nameSet[curID] = 0
-			sb.Reset()
 		}
-		return nil
 	}
 
+	id, description := splitHeader(fr.header)
+	rec := &Record{ID: id, Description: description, Seq: make([]byte, 0)}
+	fr.header = ""
+
 	for {
-		line, err := reader.ReadString('\n')
-		line = strings.TrimRight(line, "\r\n")
-
-		if len(line) > 0 {
-			if line[0] == '>' {
-				// new record: flush previous
-				if flushErr := flush(); flushErr != nil {
-					return nil, nil, flushErr
-				}
-				rest := line[1:]
-				parts := strings.Fields(rest)
-				if len(parts) == 0 {
-					return nil, nil, fmt.Errorf("empty Fasta descriptor in line: %q", line)
-				}
-				curID = parts[0]
-			} else {
-				sb.WriteString(strings.TrimSpace(line))
-			}
+		line, err := fr.readLine()
+		if err == io.EOF {
+			fr.done = true
+			break
+		}
+		if err != nil {
+			return nil, err
 		}
 
-		if err == io.EOF {
+		if len(line) > 0 && line[0] == '>' {
+			if err := fr.setHeader(line); err != nil {
+				return nil, err
+			}
 			break
 		}
+		rec.Seq = append(rec.Seq, bytes.TrimSpace([]byte(line))...)
+	}
+
+	return rec, nil
+}
+
+// Iterate over the remaining records, yielding a non-nil error at most once, as the last element
+func (fr *Reader) Records() iter.Seq2[*Record, error] {
+	return records(fr.Read)
+}
+
+// The id is the string after '>' up to the first whitespace.
+// Sequences are concatenated with newlines removed.
+func ReadFasta(reader *bufio.Reader) (*[]string, *[][]byte, error) {
+	nameList := make([]string, 0)
+	fastaStrings := make([][]byte, 0)
+	nameSet := make(map[string]int) // Set for checking duplicates of identifiers, with dummy int values
+
+	for rec, err := range NewReader(reader).Records() {
 		if err != nil {
 			return nil, nil, err
 		}
+		if _, ok := nameSet[rec.ID]; ok {
+			return nil, nil, errors.New("duplicated identifier in Fasta file: " + rec.ID)
+		}
+		nameSet[rec.ID] = 0
+		nameList = append(nameList, rec.ID)
+		fastaStrings = append(fastaStrings, rec.Seq)
 	}
 
-	if err := flush(); err != nil {
-		return nil, nil, err
+	return &nameList, &fastaStrings, nil
+}
+
+/*
+Writer of FASTA records, with sequence lines wrapped at a fixed width.
+
+Call Flush after the last record.
+*/
+type Writer struct {
+	writer    *bufio.Writer
+	LineWidth int // Maximum length of sequence lines, no wrapping if not positive
+}
+
+// Create a FASTA writer with the given line width (no wrapping if not positive)
+func NewWriter(w io.Writer, lineWidth int) *Writer {
+	return &Writer{writer: bufio.NewWriter(w), LineWidth: lineWidth}
+}
+
+// Write a record
+func (fw *Writer) Write(rec *Record) error {
+	if rec.ID == "" {
+		return errors.New("cannot write Fasta record without identifier")
 	}
 
-	return &nameList, &fastaStrings, nil
+	fw.writer.WriteByte('>')
+	fw.writer.WriteString(rec.Header())
+	fw.writer.WriteByte('\n')
+
+	seq := rec.Seq
+	if fw.LineWidth > 0 {
+		for len(seq) > fw.LineWidth {
+			fw.writer.Write(seq[:fw.LineWidth])
+			fw.writer.WriteByte('\n')
+			seq = seq[fw.LineWidth:]
+		}
+	}
+	if len(seq) > 0 {
+		fw.writer.Write(seq)
+		fw.writer.WriteByte('\n')
+	}
+
+	// Errors are sticky in bufio.Writer, so checking the last write is enough
+	_, err := fw.writer.Write(nil)
+
+	return err
+}
+
+// Write any buffered data to the underlying writer
+func (fw *Writer) Flush() error {
+	return fw.writer.Flush()
 }
@@ -0,0 +1,93 @@
+package fasta
+
+import (
+	"bufio"
+	"bytes"
+	"strings"
+	"testing"
+)
+
+func TestReader_Records(t *testing.T) {
+	input := "ignored line\n>seq1 first record\r\nACGT\nAC \n\n>seq2\tdesc\twith tabs\nGG\n>seq3\n"
+	want := []Record{
+		{"seq1", "first record", []byte("ACGTAC")},
+		{"seq2", "desc\twith tabs", []byte("GG")},
+		{"seq3", "", []byte{}},
+	}
+
+	got := make([]*Record, 0)
+	for rec, err := range NewReader(strings.NewReader(input)).Records() {
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		got = append(got, rec)
+	}
+
+	if len(got) != len(want) {
+		t.Fatalf("got %d records, want %d", len(got), len(want))
+	}
+	for i := range want {
+		if got[i].ID != want[i].ID || got[i].Description != want[i].Description || !bytes.Equal(got[i].Seq, want[i].Seq) {
+			t.Errorf("record %d: got %+v, want %+v", i, *got[i], want[i])
+		}
+	}
+}
+
+func TestReader_EmptyHeader(t *testing.T) {
+	for _, input := range []string{">\nACGT\n", ">a\nAC\n>  \nGT\n"} {
+		var err error
+		for _, err = range NewReader(strings.NewReader(input)).Records() {
+		}
+		if err == nil {
+			t.Errorf("%q: expected error, got nil", input)
+		}
+	}
+}
+
+func TestReadFasta_Duplicates(t *testing.T) {
+	_, _, err := ReadFasta(bufio.NewReader(strings.NewReader(">a\nAC\n>a\nGT\n")))
+	if err == nil {
+		t.Errorf("expected error for duplicated identifier, got nil")
+	}
+}
+
+func TestWriter(t *testing.T) {
+	tests := []struct {
+		lineWidth int
+		rec       Record
+		want      string
+	}{
+		{4, Record{"a", "some desc", []byte("ACGTACGTA")}, ">a some desc\nACGT\nACGT\nA\n"},
+		{4, Record{"b", "", []byte("ACGT")}, ">b\nACGT\n"},
+		{0, Record{"c", "", []byte("ACGTACGTA")}, ">c\nACGTACGTA\n"},
+		{4, Record{"d", "", []byte{}}, ">d\n"},
+	}
+
+	for _, tt := range tests {
+		var buf bytes.Buffer
+		w := NewWriter(&buf, tt.lineWidth)
+		if err := w.Write(&tt.rec); err != nil {
+			t.Errorf("%s: unexpected error: %v", tt.rec.ID, err)
+		}
+		w.Flush()
+		if buf.String() != tt.want {
+			t.Errorf("%s: got %q, want %q", tt.rec.ID, buf.String(), tt.want)
+		}
+	}
+}
+
+func TestWriter_RoundTrip(t *testing.T) {
+	input := ">x desc\nACGTACGT\nAC\n>y\nTT\n"
+	var buf bytes.Buffer
+	w := NewWriter(&buf, 8)
+	for rec, err := range NewReader(strings.NewReader(input)).Records() {
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		w.Write(rec)
+	}
+	w.Flush()
+	if buf.String() != input {
+		t.Errorf("round-trip got %q, want %q", buf.String(), input)
+	}
+}
@@ -0,0 +1,184 @@
+package fasta
+
+import (
+	"fmt"
+	"io"
+	"iter"
+	"math/rand/v2"
+	"strings"
+)
+
+// Offset of the ASCII encoding of Phred quality scores (Sanger / Illumina 1.8+)
+const phredOffset = 33
+
+// A sequencing read of a FASTQ file
+type FastqRecord struct {
+	ID          string // The header up to the first whitespace
+	Description string // The rest of the header, without leading and trailing whitespace
+	Seq         []byte
+	Qual        []byte // Phred+33 encoded quality scores, one per base
+}
+
+// Return the Phred quality score of the base at position i
+func (rec *FastqRecord) Quality(i int) int {
+	return int(rec.Qual[i]) - phredOffset
+}
+
+// Return the mean Phred quality score of the read, or 0 for an empty read
+func (rec *FastqRecord) MeanQuality() float64 {
+	if len(rec.Qual) == 0 {
+		return 0.0
+	}
+
+	sum := 0
+	for i := range rec.Qual {
+		sum += rec.Quality(i)
+	}
+
+	return float64(sum) / float64(len(rec.Qual))
+}
+
+/*
+Remove the bases with a quality score below minQuality from both ends of the read.
+*/
+func (rec *FastqRecord) TrimQuality(minQuality int) {
+	start := 0
+	for start < len(rec.Qual) && rec.Quality(start) < minQuality {
+		start += 1
+	}
+	end := len(rec.Qual)
+	for end > start && rec.Quality(end-1) < minQuality {
+		end -= 1
+	}
+
+	rec.Seq = rec.Seq[start:end]
+	rec.Qual = rec.Qual[start:end]
+}
+
+/*
+Streaming reader of FASTQ records.
+
+Records must be in the four-line layout: header starting with '@', sequence, separator starting with '+', and qualities.
+*/
+type FastqReader struct {
+	lineReader
+}
+
+// Create a FASTQ reader, which buffers the input stream unless it is already a *bufio.Reader
+func NewFastqReader(r io.Reader) *FastqReader {
+	return &FastqReader{lineReader: newLineReader(r)}
+}
+
+/*
+Read the next record.
+
+Returns io.EOF when there are no more records.
+*/
+func (fr *FastqReader) Read() (*FastqRecord, error) {
+	var header string
+	var err error
+
+	// Skip blank lines between records
+	for header == "" {
+		header, err = fr.readLine()
+		if err != nil {
+			return nil, err
+		}
+	}
+	if header[0] != '@' {
+		return nil, fmt.Errorf("expected FASTQ header starting with '@' in line %d: %q", fr.lineNo, header)
+	}
+
+	id, description := splitHeader(strings.TrimSpace(header[1:]))
+	if id == "" {
+		return nil, fmt.Errorf("empty FASTQ header in line %d", fr.lineNo)
+	}
+
+	lines := [3]string{}
+	for i := range lines {
+		lines[i], err = fr.readLine()
+		if err == io.EOF {
+			return nil, fmt.Errorf("truncated FASTQ record \"%s\" at line %d", id, fr.lineNo)
+		}
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	if len(lines[1]) == 0 || lines[1][0] != '+' {
+		return nil, fmt.Errorf("expected FASTQ separator starting with '+' in line %d: %q", fr.lineNo-1, lines[1])
+	}
+	if len(lines[0]) != len(lines[2]) {
+		return nil, fmt.Errorf(
+			"FASTQ record \"%s\" has %d bases but %d quality scores", id, len(lines[0]), len(lines[2]),
+		)
+	}
+
+	return &FastqRecord{ID: id, Description: description, Seq: []byte(lines[0]), Qual: []byte(lines[2])}, nil
+}
+
+// Iterate over the remaining records, yielding a non-nil error at most once, as the last element
+func (fr *FastqReader) Records() iter.Seq2[*FastqRecord, error] {
+	return records(fr.Read)
+}
+
+/*
+Criteria for selecting reads before they are concatenated.
+
+Reads are first subsampled, then trimmed, then filtered by mean quality and length, until MaxReads are kept.
+*/
+type ReadFilter struct {
+	SubsampleFraction float64 // Probability of keeping each read (all reads are kept if not in (0, 1))
+	Seed              uint64  // Seed of the random number generator for subsampling
+	TrimQuality       int     // Bases below this quality are trimmed from both ends of reads
+	MinMeanQuality    float64 // Reads with a lower mean quality are discarded
+	MinLength         int     // Reads shorter than this after trimming are discarded
+	MaxReads          int     // Maximum number of reads kept (no limit if not positive)
+}
+
+// Counts of reads and bases processed by ConcatenateReads
+type ReadStats struct {
+	NbReads     int // Number of reads read from the input (reading stops once MaxReads are kept)
+	NbKeptReads int // Number of reads that passed the filter
+	NbBases     int // Number of bases in the kept reads, after trimming
+}
+
+/*
+Concatenate the sequences of the reads of a FASTQ stream that pass a filter, with a separator between reads.
+*/
+func ConcatenateReads(reader *FastqReader, filter ReadFilter, separator []byte) ([]byte, ReadStats, error) {
+	var stats ReadStats
+	seq := make([]byte, 0)
+	rng := rand.New(rand.NewPCG(filter.Seed, 0))
+	subsample := filter.SubsampleFraction > 0.0 && filter.SubsampleFraction < 1.0
+
+	for rec, err := range reader.Records() {
+		if err != nil {
+			return nil, stats, err
+		}
+		stats.NbReads += 1
+
+		if subsample && rng.Float64() >= filter.SubsampleFraction {
+			continue
+		}
+		if filter.TrimQuality > 0 {
+			rec.TrimQuality(filter.TrimQuality)
+		}
+		if len(rec.Seq) == 0 || len(rec.Seq) < filter.MinLength || rec.MeanQuality() < filter.MinMeanQuality {
+			continue
+		}
+
+		if stats.NbKeptReads > 0 {
+			seq = append(seq, separator...)
+		}
+		seq = append(seq, rec.Seq...)
+		stats.NbKeptReads += 1
+		stats.NbBases += len(rec.Seq)
+
+		if filter.MaxReads > 0 && stats.NbKeptReads >= filter.MaxReads {
+			break
+		}
+	}
+
+	return seq, stats, nil
+}
@@ -0,0 +1,116 @@
+package fasta
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+func TestFastqReader_Records(t *testing.T) {
+	input := "@r1 first read\r\nACGT\r\n+\r\nIIII\r\n\n@r2\nGG\n+r2\n#I\n"
+	want := []FastqRecord{
+		{"r1", "first read", []byte("ACGT"), []byte("IIII")},
+		{"r2", "", []byte("GG"), []byte("#I")},
+	}
+
+	got := make([]*FastqRecord, 0)
+	for rec, err := range NewFastqReader(strings.NewReader(input)).Records() {
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		got = append(got, rec)
+	}
+
+	if len(got) != len(want) {
+		t.Fatalf("got %d records, want %d", len(got), len(want))
+	}
+	for i := range want {
+		if got[i].ID != want[i].ID || got[i].Description != want[i].Description ||
+			!bytes.Equal(got[i].Seq, want[i].Seq) || !bytes.Equal(got[i].Qual, want[i].Qual) {
+			t.Errorf("record %d: got %+v, want %+v", i, *got[i], want[i])
+		}
+	}
+}
+
+func TestFastqReader_Malformed(t *testing.T) {
+	inputs := []string{
+		">r1\nACGT\n+\nIIII\n", // FASTA header
+		"@\nACGT\n+\nIIII\n",   // Empty header
+		"@r1\nACGT\n-\nIIII\n", // Bad separator
+		"@r1\nACGT\n+\nIII\n",  // Missing quality score
+		"@r1\nACGT\n+\n",       // Truncated record
+	}
+
+	for _, input := range inputs {
+		var err error
+		for _, err = range NewFastqReader(strings.NewReader(input)).Records() {
+		}
+		if err == nil {
+			t.Errorf("%q: expected error, got nil", input)
+		}
+	}
+}
+
+func TestFastqRecord_TrimQuality(t *testing.T) {
+	// '#' is quality 2, '5' is 20, 'I' is 40
+	rec := FastqRecord{"r", "", []byte("AACGTTT"), []byte("#5II#5#")}
+	if q := rec.MeanQuality(); q != 18.0 {
+		t.Errorf("mean quality: got %g, want 18", q)
+	}
+
+	rec.TrimQuality(20)
+	if string(rec.Seq) != "ACGTT" || string(rec.Qual) != "5II#5" {
+		t.Errorf("got %s/%s, want ACGTT/5II#5", rec.Seq, rec.Qual)
+	}
+
+	rec.TrimQuality(50)
+	if len(rec.Seq) != 0 || len(rec.Qual) != 0 {
+		t.Errorf("got %s/%s, want empty read", rec.Seq, rec.Qual)
+	}
+}
+
+func TestConcatenateReads(t *testing.T) {
+	input := "@r1\nAAAA\n+\nIIII\n@r2\nCCCC\n+\n####\n@r3\nGG\n+\nII\n@r4\nTTTT\n+\n#II#\n@r5\nACAC\n+\nIIII\n"
+
+	tests := []struct {
+		filter ReadFilter
+		want   string
+		stats  ReadStats
+	}{
+		{ReadFilter{}, "AAAA-CCCC-GG-TTTT-ACAC", ReadStats{5, 5, 18}},
+		{ReadFilter{MinMeanQuality: 20}, "AAAA-GG-TTTT-ACAC", ReadStats{5, 4, 14}},
+		{ReadFilter{TrimQuality: 20, MinLength: 3}, "AAAA-ACAC", ReadStats{5, 2, 8}},
+		{ReadFilter{TrimQuality: 20, MaxReads: 3}, "AAAA-GG-TT", ReadStats{4, 3, 8}},
+	}
+
+	for _, tt := range tests {
+		seq, stats, err := ConcatenateReads(NewFastqReader(strings.NewReader(input)), tt.filter, []byte("-"))
+		if err != nil {
+			t.Fatalf("%+v: unexpected error: %v", tt.filter, err)
+		}
+		if string(seq) != tt.want || stats != tt.stats {
+			t.Errorf("%+v: got %s %+v, want %s %+v", tt.filter, seq, stats, tt.want, tt.stats)
+		}
+	}
+}
+
+func TestConcatenateReads_Subsample(t *testing.T) {
+	var input strings.Builder
+	for range 1000 {
+		input.WriteString("@r\nACGT\n+\nIIII\n")
+	}
+	filter := ReadFilter{SubsampleFraction: 0.25, Seed: 42}
+
+	_, stats1, err := ConcatenateReads(NewFastqReader(strings.NewReader(input.String())), filter, nil)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	_, stats2, _ := ConcatenateReads(NewFastqReader(strings.NewReader(input.String())), filter, nil)
+
+	if stats1 != stats2 {
+		t.Errorf("subsampling with the same seed gave %+v and %+v", stats1, stats2)
+	}
+	if stats1.NbKeptReads < 200 || stats1.NbKeptReads > 300 {
+		t.Errorf("kept %d of 1000 reads, expected about 250", stats1.NbKeptReads)
+	}
+}
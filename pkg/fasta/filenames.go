@@ -0,0 +1,35 @@
+package fasta
+
+import "strings"
+
+// Extensions of sequence files, before any compression extension
+var SequenceExtensions = []string{".fa", ".fasta", ".fna", ".fas", ".ffn", ".faa", ".frn", ".mfa", ".fq", ".fastq", ".gb", ".gbk", ".gbff", ".embl"}
+
+// Extensions of compressed files
+var CompressionExtensions = []string{".gz", ".bz2", ".zst"}
+
+/*
+Strip a compression extension and a sequence file extension from a file name, and tell whether a sequence file
+extension was found after the compression extension, if any.
+*/
+func TrimExtensions(name string) (string, bool) {
+	for _, ext := range CompressionExtensions {
+		if strings.HasSuffix(name, ext) {
+			name = strings.TrimSuffix(name, ext)
+			break
+		}
+	}
+	for _, ext := range SequenceExtensions {
+		if strings.HasSuffix(name, ext) {
+			return strings.TrimSuffix(name, ext), true
+		}
+	}
+
+	return name, false
+}
+
+// Tell if a file name is that of a sequence file, possibly compressed, which is not hidden
+func IsSequenceFileName(name string) bool {
+	_, ok := TrimExtensions(name)
+	return ok && !strings.HasPrefix(name, ".")
+}
@@ -0,0 +1,45 @@
+package fasta
+
+import "testing"
+
+func TestIsSequenceFileName(t *testing.T) {
+	tests := []struct {
+		name string
+		want bool
+	}{
+		{"w.fa", true},
+		{"w.fasta.gz", true},
+		{"x.fastq.bz2", true},
+		{"r.gbk.zst", true},
+		{"ann.gff.gz", false},
+		{"md5.txt.gz", false},
+		{"w.gz", false},
+		{"w.fa.txt", false},
+		{"w.gz.fa", true},
+		{".hidden.fa", false},
+		{"README", false},
+	}
+	for _, tt := range tests {
+		if got := IsSequenceFileName(tt.name); got != tt.want {
+			t.Errorf("IsSequenceFileName(%q) = %v, want %v", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestTrimExtensions(t *testing.T) {
+	tests := []struct {
+		name string
+		base string
+		ok   bool
+	}{
+		{"whale.fasta", "whale", true},
+		{"whale.fna.gz", "whale", true},
+		{"reads.gz", "reads", false},
+		{"notes.txt", "notes.txt", false},
+	}
+	for _, tt := range tests {
+		if base, ok := TrimExtensions(tt.name); base != tt.base || ok != tt.ok {
+			t.Errorf("TrimExtensions(%q) = %q, %v, want %q, %v", tt.name, base, ok, tt.base, tt.ok)
+		}
+	}
+}
@@ -0,0 +1,507 @@
+package fasta
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"iter"
+	"strconv"
+	"strings"
+)
+
+// A feature of the feature table of an annotated record
+type Feature struct {
+	Key        string // Feature key, e.g. "CDS" or "rRNA"
+	Location   string // Location descriptor, e.g. "complement(join(1..20,30..>45))"
+	Qualifiers map[string][]string
+}
+
+// Return the first value of a qualifier, or "" if the feature does not have it
+func (f *Feature) Qualifier(name string) string {
+	values := f.Qualifiers[name]
+	if len(values) == 0 {
+		return ""
+	}
+
+	return values[0]
+}
+
+/*
+Extract the sequence of the feature from the sequence of its record.
+
+Locations on the complementary strand are reverse-complemented. Locations referring to other records are not
+supported.
+*/
+func (f *Feature) Extract(seq []byte) ([]byte, error) {
+	p := locationParser{strings.Join(strings.Fields(f.Location), ""), 0, seq}
+	extracted, err := p.parse()
+	if err == nil && p.pos < len(p.text) {
+		err = fmt.Errorf("unexpected \"%s\"", p.text[p.pos:])
+	}
+	if err != nil {
+		return nil, fmt.Errorf("feature %s at %s: %w", f.Key, f.Location, err)
+	}
+
+	return extracted, nil
+}
+
+// Recursive descent parser of location descriptors
+type locationParser struct {
+	text string
+	pos  int
+	seq  []byte
+}
+
+func (p *locationParser) parse() ([]byte, error) {
+	for _, op := range []string{"complement(", "join(", "order("} {
+		if !strings.HasPrefix(p.text[p.pos:], op) {
+			continue
+		}
+		p.pos += len(op)
+
+		parts := make([]byte, 0)
+		for {
+			part, err := p.parse()
+			if err != nil {
+				return nil, err
+			}
+			parts = append(parts, part...)
+			if p.pos < len(p.text) && p.text[p.pos] == ',' && op != "complement(" {
+				p.pos += 1
+				continue
+			}
+			break
+		}
+		if p.pos >= len(p.text) || p.text[p.pos] != ')' {
+			return nil, errors.New("missing closing parenthesis")
+		}
+		p.pos += 1
+
+		if op == "complement(" {
+			return ReverseComplement(parts), nil
+		}
+		return parts, nil
+	}
+
+	return p.parseRange()
+}
+
+// Parse a single base ("12"), a span ("<12..>40"), a site between two bases ("12^13") or a base within a span ("12.40")
+func (p *locationParser) parseRange() ([]byte, error) {
+	end := strings.IndexAny(p.text[p.pos:], ",)")
+	if end < 0 {
+		end = len(p.text) - p.pos
+	}
+	text := p.text[p.pos : p.pos+end]
+	p.pos += end
+
+	if strings.Contains(text, ":") {
+		return nil, fmt.Errorf("remote location \"%s\" is not supported", text)
+	}
+	if strings.Contains(text, "^") {
+		return []byte{}, nil
+	}
+
+	first, last, found := strings.Cut(text, "..")
+	if !found {
+		// A single base, possibly one base anywhere within a span, of which we take the first
+		first, _, _ = strings.Cut(text, ".")
+		last = first
+	}
+	start, err := strconv.Atoi(strings.TrimLeft(first, "<>"))
+	if err != nil {
+		return nil, fmt.Errorf("invalid location \"%s\"", text)
+	}
+	stop, err := strconv.Atoi(strings.TrimLeft(last, "<>"))
+	if err != nil {
+		return nil, fmt.Errorf("invalid location \"%s\"", text)
+	}
+	if start < 1 || stop < start || stop > len(p.seq) {
+		return nil, fmt.Errorf("location \"%s\" out of the sequence of length %d", text, len(p.seq))
+	}
+
+	return bytes.Clone(p.seq[start-1 : stop]), nil
+}
+
+var complementTable = func() [256]byte {
+	var table [256]byte
+	for i := range table {
+		table[i] = byte(i)
+	}
+	for _, pair := range []string{"AT", "CG", "RY", "KM", "BV", "DH", "at", "cg", "ry", "km", "bv", "dh"} {
+		table[pair[0]] = pair[1]
+		table[pair[1]] = pair[0]
+	}
+	table['U'] = 'A'
+	table['u'] = 'a'
+
+	return table
+}()
+
+// Return the reverse complement of a nucleotide sequence, with IUPAC ambiguity codes
+func ReverseComplement(seq []byte) []byte {
+	rc := make([]byte, len(seq))
+	for i, b := range seq {
+		rc[len(seq)-1-i] = complementTable[b]
+	}
+
+	return rc
+}
+
+/*
+Criteria for selecting the features of an annotated record.
+
+A feature is selected if it has the key, and if Name is not empty, if its gene, product or locus_tag qualifier is
+Name, ignoring case.
+*/
+type FeatureSelector struct {
+	Key  string // e.g. "CDS", "rRNA" or "gene"
+	Name string // e.g. "COX1" (any name if empty)
+}
+
+func (sel FeatureSelector) String() string {
+	if sel.Name == "" {
+		return sel.Key
+	}
+
+	return sel.Key + " " + sel.Name
+}
+
+// Tell if a feature is selected
+func (sel FeatureSelector) Matches(f *Feature) bool {
+	if f.Key != sel.Key {
+		return false
+	}
+	if sel.Name == "" {
+		return true
+	}
+	for _, qualifier := range []string{"gene", "product", "locus_tag"} {
+		for _, value := range f.Qualifiers[qualifier] {
+			if strings.EqualFold(value, sel.Name) {
+				return true
+			}
+		}
+	}
+
+	return false
+}
+
+// A sequence record of a GenBank or EMBL flat file
+type AnnotatedRecord struct {
+	Locus      string // Locus name (GenBank) or primary accession from the ID line (EMBL)
+	Accession  string // Accession with its version, e.g. "NC_005268.1", when the version is known
+	Definition string
+	Organism   string
+	Features   []*Feature
+	Seq        []byte // In upper case
+}
+
+// Return the sequences of the selected features, in the order of the feature table
+func (rec *AnnotatedRecord) FeatureSequences(sel FeatureSelector) ([][]byte, error) {
+	seqs := make([][]byte, 0)
+	for _, f := range rec.Features {
+		if !sel.Matches(f) {
+			continue
+		}
+		seq, err := f.Extract(rec.Seq)
+		if err != nil {
+			return nil, err
+		}
+		seqs = append(seqs, seq)
+	}
+
+	return seqs, nil
+}
+
+// Default template of taxon names built from annotated records
+const DefaultNameTemplate = "{organism}-{accession}"
+
+/*
+Build a name from a template with the fields of the record in braces: {organism}, {accession}, {locus} and
+{definition}.
+
+Whitespace in field values is replaced by underscores, so that "Balaena mysticetus" becomes "Balaena_mysticetus".
+*/
+func (rec *AnnotatedRecord) Name(template string) (string, error) {
+	fields := map[string]string{
+		"organism":   rec.Organism,
+		"accession":  rec.Accession,
+		"locus":      rec.Locus,
+		"definition": rec.Definition,
+	}
+
+	var name strings.Builder
+	rest := template
+	for {
+		start := strings.IndexByte(rest, '{')
+		if start < 0 {
+			name.WriteString(rest)
+			break
+		}
+		length := strings.IndexByte(rest[start:], '}')
+		if length < 0 {
+			return "", fmt.Errorf("unclosed brace in name template \"%s\"", template)
+		}
+		field := rest[start+1 : start+length]
+		value, ok := fields[field]
+		if !ok {
+			return "", fmt.Errorf("unknown field {%s} in name template \"%s\"", field, template)
+		}
+		name.WriteString(rest[:start])
+		name.WriteString(strings.Join(strings.Fields(value), "_"))
+		rest = rest[start+length+1:]
+	}
+
+	return name.String(), nil
+}
+
+// Tell if the start of a stream looks like a GenBank or EMBL flat file
+func IsAnnotatedFormat(head []byte) bool {
+	return bytes.HasPrefix(head, []byte("LOCUS")) || bytes.HasPrefix(head, []byte("ID   "))
+}
+
+/*
+Streaming reader of GenBank and EMBL flat files.
+
+Each record may be in either format. Only the fields of AnnotatedRecord are kept.
+*/
+type AnnotatedReader struct {
+	lineReader
+}
+
+// Create a GenBank/EMBL reader, which buffers the input stream unless it is already a *bufio.Reader
+func NewAnnotatedReader(r io.Reader) *AnnotatedReader {
+	return &AnnotatedReader{lineReader: newLineReader(r)}
+}
+
+// Builds the feature table from the feature lines of either format, once the line prefix is removed
+type featureTableBuilder struct {
+	features  []*Feature
+	qualifier string // Name of the qualifier being continued, "" while in the location
+}
+
+func (b *featureTableBuilder) addLine(line string) {
+	// The feature key starts at column 6, and its location and qualifiers at column 22
+	if len(line) > 5 && line[5] != ' ' {
+		key, location, _ := strings.Cut(strings.TrimSpace(line), " ")
+		b.features = append(b.features, &Feature{key, strings.TrimSpace(location), make(map[string][]string)})
+		b.qualifier = ""
+		return
+	}
+	if len(b.features) == 0 {
+		return
+	}
+
+	f := b.features[len(b.features)-1]
+	content := strings.TrimSpace(line)
+
+	if strings.HasPrefix(content, "/") {
+		name, value, _ := strings.Cut(content[1:], "=")
+		f.Qualifiers[name] = append(f.Qualifiers[name], value)
+		b.qualifier = name
+	} else if b.qualifier == "" {
+		f.Location += content
+	} else {
+		values := f.Qualifiers[b.qualifier]
+		separator := " "
+		if b.qualifier == "translation" {
+			separator = ""
+		}
+		values[len(values)-1] += separator + content
+	}
+}
+
+// Return the features, with the quotes of qualifier values removed
+func (b *featureTableBuilder) table() []*Feature {
+	for _, f := range b.features {
+		for _, values := range f.Qualifiers {
+			for i, v := range values {
+				if len(v) >= 2 && v[0] == '"' && v[len(v)-1] == '"' {
+					values[i] = strings.ReplaceAll(v[1:len(v)-1], `""`, `"`)
+				}
+			}
+		}
+	}
+
+	return b.features
+}
+
+// Append the letters of a sequence line, skipping spaces and base numbers
+func appendSequenceLine(seq []byte, line string) []byte {
+	for i := 0; i < len(line); i++ {
+		if c := line[i]; ('A' <= c && c <= 'Z') || ('a' <= c && c <= 'z') || c == '-' || c == '*' {
+			seq = append(seq, c)
+		}
+	}
+
+	return seq
+}
+
+/*
+Read the next record.
+
+Returns io.EOF when there are no more records.
+*/
+func (ar *AnnotatedReader) Read() (*AnnotatedRecord, error) {
+	var line string
+	var err error
+
+	// Skip blank lines between records
+	for strings.TrimSpace(line) == "" {
+		line, err = ar.readLine()
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	switch {
+	case strings.HasPrefix(line, "LOCUS"):
+		return ar.readGenBank(line)
+	case strings.HasPrefix(line, "ID   "):
+		return ar.readEMBL(line)
+	default:
+		return nil, fmt.Errorf("expected GenBank LOCUS line or EMBL ID line in line %d: %q", ar.lineNo, line)
+	}
+}
+
+// Read the rest of a record, calling handle on each line until the "//" terminator
+func (ar *AnnotatedReader) readUntilEnd(rec *AnnotatedRecord, handle func(line string)) (*AnnotatedRecord, error) {
+	for {
+		line, err := ar.readLine()
+		if err == io.EOF {
+			return nil, fmt.Errorf("record \"%s\" not terminated by \"//\" at line %d", rec.Locus, ar.lineNo)
+		}
+		if err != nil {
+			return nil, err
+		}
+		if strings.HasPrefix(line, "//") {
+			break
+		}
+		handle(line)
+	}
+
+	rec.Seq = bytes.ToUpper(rec.Seq)
+	if rec.Accession == "" {
+		rec.Accession = rec.Locus
+	}
+
+	return rec, nil
+}
+
+func (ar *AnnotatedReader) readGenBank(locusLine string) (*AnnotatedRecord, error) {
+	rec := &AnnotatedRecord{Seq: make([]byte, 0)}
+	if fields := strings.Fields(locusLine); len(fields) > 1 {
+		rec.Locus = fields[1]
+	}
+
+	var features featureTableBuilder
+	keyword := ""
+
+	rec, err := ar.readUntilEnd(rec, func(line string) {
+		// Keywords start at column 1, subkeywords at column 3, and values at column 13
+		if len(line) > 0 && line[0] != ' ' {
+			keyword, _, _ = strings.Cut(line, " ")
+		} else if len(line) > 2 && line[2] != ' ' && keyword != "FEATURES" && keyword != "ORIGIN" {
+			keyword, _, _ = strings.Cut(strings.TrimSpace(line), " ")
+			keyword = "  " + keyword
+		} else if keyword == "FEATURES" {
+			features.addLine(line)
+			return
+		} else if keyword == "ORIGIN" {
+			rec.Seq = appendSequenceLine(rec.Seq, line)
+			return
+		} else if keyword != "DEFINITION" {
+			// Continuation lines are only needed for the definition (the organism lineage is ignored)
+			return
+		}
+
+		value := ""
+		if len(line) > 12 {
+			value = strings.TrimSpace(line[12:])
+		}
+		switch keyword {
+		case "DEFINITION":
+			rec.Definition = strings.TrimSpace(rec.Definition + " " + value)
+		case "ACCESSION":
+			if rec.Accession == "" {
+				rec.Accession, _, _ = strings.Cut(value, " ")
+			}
+		case "VERSION":
+			if version, _, _ := strings.Cut(value, " "); version != "" {
+				rec.Accession = version
+			}
+		case "  ORGANISM":
+			rec.Organism = value
+		}
+	})
+	if err != nil {
+		return nil, err
+	}
+	rec.Definition = strings.TrimSuffix(rec.Definition, ".")
+	rec.Features = features.table()
+
+	return rec, nil
+}
+
+func (ar *AnnotatedReader) readEMBL(idLine string) (*AnnotatedRecord, error) {
+	rec := &AnnotatedRecord{Seq: make([]byte, 0)}
+
+	// e.g. "ID   X56734; SV 1; linear; mRNA; STD; PLN; 1859 BP."
+	idFields := strings.Split(idLine[5:], ";")
+	if fields := strings.Fields(idFields[0]); len(fields) > 0 {
+		rec.Locus = fields[0]
+	}
+	version := ""
+	if len(idFields) > 1 {
+		version = strings.TrimPrefix(strings.TrimSpace(idFields[1]), "SV ")
+	}
+
+	var features featureTableBuilder
+	inSequence := false
+
+	rec, err := ar.readUntilEnd(rec, func(line string) {
+		if inSequence {
+			rec.Seq = appendSequenceLine(rec.Seq, line)
+			return
+		}
+
+		code := line[:min(2, len(line))]
+		value := ""
+		if len(line) > 5 {
+			value = strings.TrimSpace(line[5:])
+		}
+		switch code {
+		case "AC":
+			if rec.Accession == "" {
+				accession, _, _ := strings.Cut(value, ";")
+				rec.Accession = strings.TrimSpace(accession)
+			}
+		case "DE":
+			rec.Definition = strings.TrimSpace(rec.Definition + " " + value)
+		case "OS":
+			if rec.Organism == "" {
+				rec.Organism = value
+			}
+		case "FT":
+			features.addLine("  " + line[2:])
+		case "SQ":
+			inSequence = true
+		}
+	})
+	if err != nil {
+		return nil, err
+	}
+	if version != "" && rec.Accession == rec.Locus {
+		rec.Accession += "." + version
+	}
+	rec.Definition = strings.TrimSuffix(rec.Definition, ".")
+	rec.Features = features.table()
+
+	return rec, nil
+}
+
+// Iterate over the remaining records, yielding a non-nil error at most once, as the last element
+func (ar *AnnotatedReader) Records() iter.Seq2[*AnnotatedRecord, error] {
+	return records(ar.Read)
+}
@@ -0,0 +1,197 @@
+package fasta
+
+import (
+	"strings"
+	"testing"
+)
+
+const testGenBank = `LOCUS       NC_000001              30 bp    DNA     circular MAM 01-JAN-2020
+DEFINITION  Balaena mysticetus mitochondrion, complete
+            genome.
+ACCESSION   NC_000001
+VERSION     NC_000001.1
+SOURCE      mitochondrion Balaena mysticetus (bowhead whale)
+  ORGANISM  Balaena mysticetus
+            Eukaryota; Metazoa; Chordata; Mammalia; Cetacea.
+FEATURES             Location/Qualifiers
+     source          1..30
+                     /organism="Balaena mysticetus"
+     CDS             1..6
+                     /gene="COX1"
+                     /product="cytochrome c oxidase
+                     subunit I"
+     CDS             complement(join(10..12,
+                     20..22))
+                     /gene="ND6"
+     tRNA            25^26
+ORIGIN
+        1 atgaaacccg ggtttacgta cgtacgtaaa
+//
+
+LOCUS       X2                     4 bp    DNA     linear   MAM 01-JAN-2020
+ACCESSION   X2
+  ORGANISM  Unknown species
+ORIGIN
+        1 acgt
+//
+`
+
+const testEMBL = `ID   X56734; SV 1; linear; mRNA; STD; PLN; 12 BP.
+XX
+AC   X56734; S46826;
+XX
+DE   Trifolium repens mRNA for
+DE   non-cyanogenic beta-glucosidase.
+OS   Trifolium repens (white clover)
+FT   source          1..12
+FT                   /organism="Trifolium repens"
+FT   CDS             <4..>9
+FT                   /product="beta-glucosidase"
+SQ   Sequence 12 BP; 3 A; 3 C; 3 G; 3 T; 0 other;
+     aaacccgggt tt                                                         12
+//
+`
+
+func readAnnotated(t *testing.T, input string) []*AnnotatedRecord {
+	records := make([]*AnnotatedRecord, 0)
+	for rec, err := range NewAnnotatedReader(strings.NewReader(input)).Records() {
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		records = append(records, rec)
+	}
+
+	return records
+}
+
+func TestAnnotatedReader_GenBank(t *testing.T) {
+	records := readAnnotated(t, testGenBank)
+	if len(records) != 2 {
+		t.Fatalf("got %d records, want 2", len(records))
+	}
+
+	rec := records[0]
+	if rec.Locus != "NC_000001" || rec.Accession != "NC_000001.1" || rec.Organism != "Balaena mysticetus" {
+		t.Errorf("got locus %q, accession %q, organism %q", rec.Locus, rec.Accession, rec.Organism)
+	}
+	if rec.Definition != "Balaena mysticetus mitochondrion, complete genome" {
+		t.Errorf("got definition %q", rec.Definition)
+	}
+	if string(rec.Seq) != "ATGAAACCCGGGTTTACGTACGTACGTAAA" {
+		t.Errorf("got sequence %s", rec.Seq)
+	}
+	if len(rec.Features) != 4 {
+		t.Fatalf("got %d features, want 4", len(rec.Features))
+	}
+	if product := rec.Features[1].Qualifier("product"); product != "cytochrome c oxidase subunit I" {
+		t.Errorf("got product %q", product)
+	}
+	if location := rec.Features[2].Location; location != "complement(join(10..12,20..22))" {
+		t.Errorf("got location %q", location)
+	}
+
+	if records[1].Accession != "X2" || string(records[1].Seq) != "ACGT" {
+		t.Errorf("second record: got %+v", *records[1])
+	}
+}
+
+func TestAnnotatedReader_EMBL(t *testing.T) {
+	records := readAnnotated(t, testEMBL)
+	if len(records) != 1 {
+		t.Fatalf("got %d records, want 1", len(records))
+	}
+
+	rec := records[0]
+	if rec.Accession != "X56734.1" || rec.Organism != "Trifolium repens (white clover)" {
+		t.Errorf("got accession %q, organism %q", rec.Accession, rec.Organism)
+	}
+	if rec.Definition != "Trifolium repens mRNA for non-cyanogenic beta-glucosidase" {
+		t.Errorf("got definition %q", rec.Definition)
+	}
+	if string(rec.Seq) != "AAACCCGGGTTT" {
+		t.Errorf("got sequence %s", rec.Seq)
+	}
+
+	seqs, err := rec.FeatureSequences(FeatureSelector{"CDS", "Beta-Glucosidase"})
+	if err != nil || len(seqs) != 1 || string(seqs[0]) != "CCCGGG" {
+		t.Errorf("got %q, %v, want [CCCGGG]", seqs, err)
+	}
+}
+
+func TestAnnotatedReader_Malformed(t *testing.T) {
+	inputs := []string{
+		">a\nACGT\n",
+		"LOCUS       A  4 bp\nORIGIN\n        1 acgt\n",
+	}
+
+	for _, input := range inputs {
+		var err error
+		for _, err = range NewAnnotatedReader(strings.NewReader(input)).Records() {
+		}
+		if err == nil {
+			t.Errorf("%q: expected error, got nil", input)
+		}
+	}
+}
+
+func TestAnnotatedRecord_FeatureSequences(t *testing.T) {
+	rec := readAnnotated(t, testGenBank)[0]
+
+	tests := []struct {
+		sel  FeatureSelector
+		want []string
+	}{
+		{FeatureSelector{"CDS", "cox1"}, []string{"ATGAAA"}},
+		{FeatureSelector{"CDS", "cytochrome c oxidase subunit I"}, []string{"ATGAAA"}},
+		{FeatureSelector{"CDS", ""}, []string{"ATGAAA", "CGTCCC"}},
+		{FeatureSelector{"tRNA", ""}, []string{""}},
+		{FeatureSelector{"rRNA", ""}, []string{}},
+	}
+
+	for _, tt := range tests {
+		seqs, err := rec.FeatureSequences(tt.sel)
+		if err != nil {
+			t.Fatalf("%s: unexpected error: %v", tt.sel, err)
+		}
+		if len(seqs) != len(tt.want) {
+			t.Fatalf("%s: got %q, want %q", tt.sel, seqs, tt.want)
+		}
+		for i := range seqs {
+			if string(seqs[i]) != tt.want[i] {
+				t.Errorf("%s: got %q, want %q", tt.sel, seqs, tt.want)
+			}
+		}
+	}
+}
+
+func TestFeature_ExtractErrors(t *testing.T) {
+	seq := []byte("ACGTACGT")
+	for _, location := range []string{"0..4", "3..20", "5..2", "join(1..2", "J00194.1:100..202", "1..2x"} {
+		f := Feature{"CDS", location, nil}
+		if _, err := f.Extract(seq); err == nil {
+			t.Errorf("%s: expected error, got nil", location)
+		}
+	}
+}
+
+func TestAnnotatedRecord_Name(t *testing.T) {
+	rec := AnnotatedRecord{Locus: "NC_005268", Accession: "NC_005268.1", Organism: "Balaena mysticetus"}
+
+	tests := []struct {
+		template string
+		want     string
+		ok       bool
+	}{
+		{DefaultNameTemplate, "Balaena_mysticetus-NC_005268.1", true},
+		{"{locus}_x", "NC_005268_x", true},
+		{"{species}", "", false},
+		{"{organism", "", false},
+	}
+
+	for _, tt := range tests {
+		got, err := rec.Name(tt.template)
+		if (err == nil) != tt.ok || got != tt.want {
+			t.Errorf("%s: got %q, %v, want %q", tt.template, got, err, tt.want)
+		}
+	}
+}
@@ -0,0 +1,50 @@
+package fasta
+
+import (
+	"bufio"
+	"io"
+	"iter"
+	"strings"
+)
+
+// Reader of the lines of a text stream, which counts them for error messages
+type lineReader struct {
+	reader *bufio.Reader
+	lineNo int // Number of the last line read
+}
+
+// Create a line reader, which buffers the input stream unless it is already a *bufio.Reader
+func newLineReader(r io.Reader) lineReader {
+	reader, ok := r.(*bufio.Reader)
+	if !ok {
+		reader = bufio.NewReader(r)
+	}
+
+	return lineReader{reader: reader}
+}
+
+// Read a line without its line terminator, returning io.EOF only when no data is left
+func (lr *lineReader) readLine() (string, error) {
+	line, err := lr.reader.ReadString('\n')
+	if err == io.EOF && len(line) > 0 {
+		err = nil
+	}
+	lr.lineNo += 1
+
+	return strings.TrimRight(line, "\r\n"), err
+}
+
+// Iterate over the records returned by read until io.EOF, yielding a non-nil error at most once, as the last element
+func records[T any](read func() (T, error)) iter.Seq2[T, error] {
+	return func(yield func(T, error) bool) {
+		for {
+			rec, err := read()
+			if err == io.EOF {
+				return
+			}
+			if !yield(rec, err) || err != nil {
+				return
+			}
+		}
+	}
+}
@@ -0,0 +1,193 @@
+/*
+Alignment-free distances between nucleotide sequences, estimated from MinHash sketches of their k-mers as in Mash
+(Ondov et al. 2016, https://doi.org/10.1186/s13059-016-0997-x).
+*/
+package kmer
+
+import (
+	"container/heap"
+	"errors"
+	"fmt"
+	"math"
+	"ncdtree/pkg/ncd"
+	"slices"
+)
+
+// Largest k-mer size, so that a k-mer fits in 64 bits with 2 bits per base
+const MaxK = 32
+
+// Finalizer of MurmurHash3, mixing the bits of the seeded k-mer
+func hashKmer(kmer uint64, seed uint64) uint64 {
+	h := kmer ^ (seed * 0x9e3779b97f4a7c15)
+	h ^= h >> 33
+	h *= 0xff51afd7ed558ccd
+	h ^= h >> 33
+	h *= 0xc4ceb9fe1a85ec53
+	h ^= h >> 33
+
+	return h
+}
+
+// Max-heap of hashes
+type hashHeap []uint64
+
+func (h hashHeap) Len() int           { return len(h) }
+func (h hashHeap) Less(i, j int) bool { return h[i] > h[j] }
+func (h hashHeap) Swap(i, j int)      { h[i], h[j] = h[j], h[i] }
+func (h *hashHeap) Push(x any)        { *h = append(*h, x.(uint64)) }
+func (h *hashHeap) Pop() any {
+	old := *h
+	x := old[len(old)-1]
+	*h = old[:len(old)-1]
+	return x
+}
+
+/*
+Bottom-s MinHash sketch of the k-mers of a sequence: the s smallest distinct hashes of its canonical k-mers.
+
+A k-mer and its reverse complement are the same canonical k-mer, so that sketches do not depend on the strand.
+K-mers with bases other than A, C, G and T (in either case) are skipped.
+*/
+type Sketch struct {
+	K      int
+	Size   int      // Maximum number of hashes (s)
+	Seed   uint64   // Seed of the hash function
+	Hashes []uint64 // In increasing order
+}
+
+// Build the sketch of a sequence
+func NewSketch(seq []byte, k int, size int, seed uint64) (*Sketch, error) {
+	if k < 1 || k > MaxK {
+		return nil, fmt.Errorf("k-mer size must be between 1 and %d, got %d", MaxK, k)
+	}
+	if size < 1 {
+		return nil, fmt.Errorf("sketch size must be positive, got %d", size)
+	}
+
+	mask := uint64(math.MaxUint64)
+	if k < MaxK {
+		mask = (uint64(1) << (2 * k)) - 1
+	}
+	shift := uint(2 * (k - 1))
+
+	bottom := make(hashHeap, 0, size)
+	members := make(map[uint64]struct{}, size)
+	var forward, reverse uint64
+	valid := 0 // Number of consecutive valid bases
+
+	for _, b := range seq {
+		code, ok := ncd.NucleotideCode(b)
+		if !ok {
+			valid = 0
+			continue
+		}
+		forward = ((forward << 2) | uint64(code)) & mask
+		reverse = (reverse >> 2) | (uint64(3-code) << shift)
+		valid += 1
+		if valid < k {
+			continue
+		}
+
+		h := hashKmer(min(forward, reverse), seed)
+		if len(bottom) == size && h >= bottom[0] {
+			continue
+		}
+		if _, ok := members[h]; ok {
+			continue
+		}
+		if len(bottom) == size {
+			delete(members, heap.Pop(&bottom).(uint64))
+		}
+		heap.Push(&bottom, h)
+		members[h] = struct{}{}
+	}
+
+	hashes := []uint64(bottom)
+	slices.Sort(hashes)
+
+	return &Sketch{k, size, seed, hashes}, nil
+}
+
+/*
+Estimate the Jaccard index of the k-mer sets of two sketched sequences.
+
+The estimate is the fraction of the s smallest hashes of the union of the sketches that are in both sketches.
+Returns 0 if a sketch is empty.
+*/
+func Jaccard(a *Sketch, b *Sketch) (float64, error) {
+	if a.K != b.K || a.Seed != b.Seed {
+		return 0.0, errors.New("sketches with different k-mer sizes or seeds cannot be compared")
+	}
+	size := min(a.Size, b.Size)
+
+	nbUnion, nbShared := 0, 0
+	i, j := 0, 0
+	for nbUnion < size && i < len(a.Hashes) && j < len(b.Hashes) {
+		switch {
+		case a.Hashes[i] < b.Hashes[j]:
+			i += 1
+		case a.Hashes[i] > b.Hashes[j]:
+			j += 1
+		default:
+			nbShared += 1
+			i += 1
+			j += 1
+		}
+		nbUnion += 1
+	}
+	// Hashes left in only one of the sketches
+	nbUnion = min(size, nbUnion+len(a.Hashes)-i+len(b.Hashes)-j)
+
+	if nbUnion == 0 {
+		return 0.0, nil
+	}
+
+	return float64(nbShared) / float64(nbUnion), nil
+}
+
+/*
+Mash distance for a Jaccard index j of k-mer sets: -1/k ln(2j / (1 + j)), an estimate of the mutation rate.
+
+Returns 1 when j is 0.
+*/
+func MashDistance(j float64, k int) float64 {
+	if j <= 0.0 {
+		return 1.0
+	}
+
+	return math.Min(1.0, -math.Log(2.0*j/(1.0+j))/float64(k))
+}
+
+// Build the sketches of the sequences of a source
+func SketchSource(source ncd.SequenceSource, k int, size int, seed uint64) ([]*Sketch, error) {
+	sketches := make([]*Sketch, source.Len())
+	for i := range source.Len() {
+		seq, err := source.Sequence(i)
+		if err != nil {
+			return nil, err
+		}
+		sketches[i], err = NewSketch(seq, k, size, seed)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return sketches, nil
+}
+
+// Create a matrix of the Mash distances between sketches
+func MashMatrix(sketches []*Sketch) (*ncd.TriangularMatrix, error) {
+	D := ncd.NewTriangularMatrix(len(sketches))
+
+	for i := range sketches {
+		for j := range i {
+			jaccard, err := Jaccard(sketches[i], sketches[j])
+			if err != nil {
+				return nil, err
+			}
+			D.Set(i, j, MashDistance(jaccard, sketches[i].K))
+		}
+	}
+
+	return D, nil
+}
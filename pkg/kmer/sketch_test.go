@@ -0,0 +1,141 @@
+package kmer
+
+import (
+	"math"
+	"math/rand"
+	"ncdtree/pkg/fasta"
+	"ncdtree/pkg/ncd"
+	"slices"
+	"strings"
+	"testing"
+)
+
+func randomSequence(rng *rand.Rand, n int) []byte {
+	seq := make([]byte, n)
+	for i := range seq {
+		seq[i] = "ACGT"[rng.Intn(4)]
+	}
+
+	return seq
+}
+
+func TestNewSketch(t *testing.T) {
+	rng := rand.New(rand.NewSource(1))
+	seq := randomSequence(rng, 5000)
+
+	sketch, err := NewSketch(seq, 21, 100, 42)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(sketch.Hashes) != 100 || !slices.IsSorted(sketch.Hashes) {
+		t.Fatalf("expected 100 sorted hashes, got %d", len(sketch.Hashes))
+	}
+	if len(slices.Compact(slices.Clone(sketch.Hashes))) != 100 {
+		t.Error("duplicate hashes in the sketch")
+	}
+
+	// Canonical k-mers: the reverse complement and lower case give the same sketch
+	rc, _ := NewSketch(fasta.ReverseComplement(seq), 21, 100, 42)
+	if !slices.Equal(sketch.Hashes, rc.Hashes) {
+		t.Error("the sketch of the reverse complement differs")
+	}
+	lower := make([]byte, len(seq))
+	for i, b := range seq {
+		lower[i] = b + 'a' - 'A'
+	}
+	low, _ := NewSketch(lower, 21, 100, 42)
+	if !slices.Equal(sketch.Hashes, low.Hashes) {
+		t.Error("the sketch of the lower case sequence differs")
+	}
+
+	other, _ := NewSketch(seq, 21, 100, 43)
+	if slices.Equal(sketch.Hashes, other.Hashes) {
+		t.Error("sketches with different seeds are identical")
+	}
+
+	// Short sequences and k-mers with ambiguous bases
+	short, _ := NewSketch([]byte("ACGTANACGTC"), 5, 100, 42)
+	if len(short.Hashes) != 2 {
+		t.Errorf("expected 2 hashes, got %d", len(short.Hashes))
+	}
+
+	if _, err := NewSketch(seq, 33, 100, 42); err == nil {
+		t.Error("expected an error for k > 32")
+	}
+	if _, err := NewSketch(seq, 21, 0, 42); err == nil {
+		t.Error("expected an error for an empty sketch size")
+	}
+}
+
+func TestJaccard(t *testing.T) {
+	rng := rand.New(rand.NewSource(2))
+	a := randomSequence(rng, 20000)
+	b := randomSequence(rng, 20000)
+
+	sa, _ := NewSketch(a, 21, 1000, 42)
+	sb, _ := NewSketch(b, 21, 1000, 42)
+
+	if j, _ := Jaccard(sa, sa); j != 1.0 {
+		t.Errorf("Jaccard of a sketch with itself = %v, want 1", j)
+	}
+	if j, _ := Jaccard(sa, sb); j != 0.0 {
+		t.Errorf("Jaccard of unrelated sequences = %v, want 0", j)
+	}
+
+	// Half of the k-mers of the concatenation are shared with a: the true index is about 1/2
+	sab, _ := NewSketch(append(slices.Clone(a), b...), 21, 1000, 42)
+	if j, _ := Jaccard(sa, sab); math.Abs(j-0.5) > 0.1 {
+		t.Errorf("Jaccard estimate %v, want about 0.5", j)
+	}
+
+	empty, _ := NewSketch([]byte("ACGT"), 21, 1000, 42)
+	if j, _ := Jaccard(empty, empty); j != 0.0 {
+		t.Errorf("Jaccard of empty sketches = %v, want 0", j)
+	}
+
+	other, _ := NewSketch(a, 15, 1000, 42)
+	if _, err := Jaccard(sa, other); err == nil {
+		t.Error("expected an error for sketches with different k")
+	}
+}
+
+func TestMashDistance(t *testing.T) {
+	if d := MashDistance(1.0, 21); d != 0.0 {
+		t.Errorf("MashDistance(1) = %v, want 0", d)
+	}
+	if d := MashDistance(0.0, 21); d != 1.0 {
+		t.Errorf("MashDistance(0) = %v, want 1", d)
+	}
+	want := -math.Log(2.0*0.5/1.5) / 21
+	if d := MashDistance(0.5, 21); math.Abs(d-want) > 1e-12 {
+		t.Errorf("MashDistance(0.5) = %v, want %v", d, want)
+	}
+}
+
+func TestMashMatrix(t *testing.T) {
+	rng := rand.New(rand.NewSource(3))
+	a := randomSequence(rng, 10000)
+
+	// Mutate 1% of the sites of a copy
+	b := slices.Clone(a)
+	for i := 0; i < len(b); i += 100 {
+		b[i] = "ACGT"[(strings.IndexByte("ACGT", b[i])+1)%4]
+	}
+	c := randomSequence(rng, 10000)
+
+	sketches, err := SketchSource(ncd.SliceSource{a, b, c}, 21, 1000, 42)
+	if err != nil {
+		t.Fatal(err)
+	}
+	D, err := MashMatrix(sketches)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if d := D.Get(1, 0); d < 0.005 || d > 0.02 {
+		t.Errorf("distance of sequences with 1%% mutations = %v, want about 0.01", d)
+	}
+	if d := D.Get(2, 0); d != 1.0 {
+		t.Errorf("distance of unrelated sequences = %v, want 1", d)
+	}
+}
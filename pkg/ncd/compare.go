@@ -0,0 +1,170 @@
+package ncd
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"math/rand/v2"
+	"ncdtree/pkg/stats"
+)
+
+/*========================================================================
+	MATRIX COMPARISON
+········································································*/
+
+// Two matrices have too few taxa in common to be compared
+var ErrTooFewCommonTaxa = errors.New("too few taxa in common")
+
+/*
+Restrict two matrices, with the taxon names of their rows, to the taxa that they have in common. The rows of the new
+matrices follow the order of the first matrix.
+
+Fails if a matrix has duplicate names or a number of names that does not match its size, and with ErrTooFewCommonTaxa
+if there are less than 3 common taxa (a single distance cannot be correlated).
+*/
+func AlignMatrices(namesA []string, A *TriangularMatrix, namesB []string, B *TriangularMatrix) ([]string, *TriangularMatrix, *TriangularMatrix, error) {
+	if len(namesA) != A.N || len(namesB) != B.N {
+		return nil, nil, nil, errors.New("the numbers of names and of matrix rows differ")
+	}
+
+	rowB := make(map[string]int, len(namesB))
+	for i, name := range namesB {
+		if _, ok := rowB[name]; ok {
+			return nil, nil, nil, fmt.Errorf("duplicate taxon name \"%s\" in the second matrix", name)
+		}
+		rowB[name] = i
+	}
+
+	seen := make(map[string]bool, len(namesA))
+	common := make([]string, 0)
+	indexA := make([]int, 0)
+	indexB := make([]int, 0)
+	for i, name := range namesA {
+		if seen[name] {
+			return nil, nil, nil, fmt.Errorf("duplicate taxon name \"%s\" in the first matrix", name)
+		}
+		seen[name] = true
+		if j, ok := rowB[name]; ok {
+			common = append(common, name)
+			indexA = append(indexA, i)
+			indexB = append(indexB, j)
+		}
+	}
+	if len(common) < 3 {
+		return nil, nil, nil, fmt.Errorf("%w: %d, at least 3 are needed", ErrTooFewCommonTaxa, len(common))
+	}
+
+	n := len(common)
+	A2 := NewTriangularMatrix(n)
+	B2 := NewTriangularMatrix(n)
+	for i := range n {
+		for j := range i {
+			A2.Set(i, j, A.Get(indexA[i], indexA[j]))
+			B2.Set(i, j, B.Get(indexB[i], indexB[j]))
+		}
+	}
+
+	return common, A2, B2, nil
+}
+
+// Return the Pearson correlation between the distances of two matrices of the same taxa
+func MatrixCorrPearson(A *TriangularMatrix, B *TriangularMatrix) (float64, error) {
+	return stats.CorrPearson(&A.RawData, &B.RawData, false)
+}
+
+// Return the Spearman rank correlation between the distances of two matrices of the same taxa
+func MatrixCorrSpearman(A *TriangularMatrix, B *TriangularMatrix) (float64, error) {
+	return stats.CorrSpearman(&A.RawData, &B.RawData)
+}
+
+// Outcome of a Mantel test
+type MantelResult struct {
+	R            float64 // Correlation between the matrices
+	P            float64 // One-sided p-value of a correlation at least as large
+	Permutations int
+}
+
+// Standardize values to a mean of 0 and a standard deviation of 1, which fails if they are constant
+func standardize(X []float64) ([]float64, error) {
+	mean := stats.MeanFloat64(&X)
+	sd := stats.StandardDeviation(&X, false)
+	if sd == 0.0 || math.IsNaN(sd) {
+		return nil, errors.New("the distances of a matrix are constant")
+	}
+
+	Z := make([]float64, len(X))
+	for i, x := range X {
+		Z[i] = (x - mean) / sd
+	}
+
+	return Z, nil
+}
+
+/*
+Test the correlation between two matrices of the same taxa with the Mantel permutation test.
+
+The taxa of B are randomly permuted (rows and columns together) with an RNG seeded with seed, and the p-value is the
+fraction of permutations, counting the observed order, that give a correlation at least as large as the observed one.
+The correlation is Pearson's, or Spearman's if spearman is set.
+*/
+func MantelTest(A *TriangularMatrix, B *TriangularMatrix, permutations int, seed uint64, spearman bool) (MantelResult, error) {
+	if A.N != B.N {
+		return MantelResult{}, fmt.Errorf("matrices of different sizes: %d and %d", A.N, B.N)
+	}
+	if A.N < 3 {
+		return MantelResult{}, fmt.Errorf("%w: %d, at least 3 are needed", ErrTooFewCommonTaxa, A.N)
+	}
+
+	x, y := A.RawData, B.RawData
+	if spearman {
+		x, y = stats.Ranks(&x), stats.Ranks(&y)
+	}
+	zx, err := standardize(x)
+	if err != nil {
+		return MantelResult{}, err
+	}
+	zy, err := standardize(y)
+	if err != nil {
+		return MantelResult{}, err
+	}
+	Zy := &TriangularMatrix{B.N, zy, B.Active}
+
+	// The mean and standard deviation of the distances do not change when the taxa are permuted, so the correlation
+	// is the mean product of the standardized distances
+	n := A.N
+	m := float64(len(zx))
+	correlation := func(perm []int) float64 {
+		sum := 0.0
+		k := 0
+		for i := range n {
+			for j := range i {
+				sum += zx[k] * Zy.Get(perm[i], perm[j])
+				k += 1
+			}
+		}
+		return sum / m
+	}
+
+	perm := make([]int, n)
+	for i := range perm {
+		perm[i] = i
+	}
+	r := correlation(perm)
+
+	rng := rand.New(rand.NewPCG(seed, 0))
+	nbAsLarge := 0
+	for range permutations {
+		rng.Shuffle(n, func(i, j int) {
+			perm[i], perm[j] = perm[j], perm[i]
+		})
+		if correlation(perm) >= r-diagnosticsTolerance {
+			nbAsLarge += 1
+		}
+	}
+
+	return MantelResult{
+		R:            r,
+		P:            float64(nbAsLarge+1) / float64(permutations+1),
+		Permutations: permutations,
+	}, nil
+}
@@ -0,0 +1,116 @@
+package ncd
+
+import (
+	"errors"
+	"math"
+	"math/rand/v2"
+	"slices"
+	"testing"
+)
+
+func randomMatrix(rng *rand.Rand, n int) *TriangularMatrix {
+	M := NewTriangularMatrix(n)
+	for i := range M.RawData {
+		M.RawData[i] = rng.Float64()
+	}
+
+	return M
+}
+
+func TestAlignMatrices(t *testing.T) {
+	A := additiveMatrix()
+	namesA := []string{"a", "b", "c", "d", "e"}
+
+	// The same distances, in another order and with an extra taxon
+	namesB := []string{"d", "x", "b", "a", "c"}
+	B := NewTriangularMatrix(5)
+	rowA := map[string]int{"a": 0, "b": 1, "c": 2, "d": 3}
+	for i := range 5 {
+		for j := range i {
+			ai, okI := rowA[namesB[i]]
+			aj, okJ := rowA[namesB[j]]
+			if okI && okJ {
+				B.Set(i, j, A.Get(ai, aj))
+			} else {
+				B.Set(i, j, 9)
+			}
+		}
+	}
+
+	common, A2, B2, err := AlignMatrices(namesA, A, namesB, B)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !slices.Equal(common, []string{"a", "b", "c", "d"}) {
+		t.Errorf("common taxa %v", common)
+	}
+	if !slices.Equal(A2.RawData, B2.RawData) {
+		t.Errorf("aligned matrices differ: %v and %v", A2.RawData, B2.RawData)
+	}
+
+	if _, _, _, err := AlignMatrices(namesA, A, []string{"a", "b", "x", "y", "z"}, B); !errors.Is(err, ErrTooFewCommonTaxa) {
+		t.Errorf("expected ErrTooFewCommonTaxa, got %v", err)
+	}
+	if _, _, _, err := AlignMatrices(namesA, A, []string{"a", "b", "c", "d", "a"}, B); err == nil {
+		t.Error("expected an error for duplicate names")
+	}
+	if _, _, _, err := AlignMatrices(namesA[:4], A, namesB, B); err == nil {
+		t.Error("expected an error for a wrong number of names")
+	}
+}
+
+func TestMatrixCorrelations(t *testing.T) {
+	A := additiveMatrix()
+	B := A.Copy()
+	for i, v := range B.RawData {
+		B.RawData[i] = v * v // Monotonic but not linear
+	}
+
+	if r, err := MatrixCorrPearson(A, A); err != nil || math.Abs(r-1.0) > 1e-12 {
+		t.Errorf("Pearson correlation of a matrix with itself = %v, %v", r, err)
+	}
+	if r, err := MatrixCorrPearson(A, B); err != nil || r > 0.999 {
+		t.Errorf("Pearson correlation = %v, %v, want less than 1", r, err)
+	}
+	if r, err := MatrixCorrSpearman(A, B); err != nil || math.Abs(r-1.0) > 1e-12 {
+		t.Errorf("Spearman correlation = %v, %v, want 1", r, err)
+	}
+}
+
+func TestMantelTest(t *testing.T) {
+	rng := rand.New(rand.NewPCG(1, 2))
+	A := randomMatrix(rng, 12)
+	B := A.Copy()
+	for i := range B.RawData {
+		B.RawData[i] += 0.1 * rng.Float64()
+	}
+
+	res, err := MantelTest(A, B, 999, 1, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	r, _ := MatrixCorrPearson(A, B)
+	if math.Abs(res.R-r) > 1e-9 {
+		t.Errorf("Mantel r = %v, want the Pearson correlation %v", res.R, r)
+	}
+	if res.P > 0.01 || res.Permutations != 999 {
+		t.Errorf("p-value of correlated matrices = %v with %d permutations", res.P, res.Permutations)
+	}
+
+	again, _ := MantelTest(A, B, 999, 1, false)
+	if again != res {
+		t.Errorf("the same seed gave different results: %+v and %+v", res, again)
+	}
+
+	res, err = MantelTest(A, randomMatrix(rng, 12), 999, 1, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if res.P < 0.01 {
+		t.Errorf("p-value of unrelated matrices = %v", res.P)
+	}
+
+	if _, err := MantelTest(A, NewTriangularMatrix(12), 99, 1, false); err == nil {
+		t.Error("expected an error for a constant matrix")
+	}
+}
@@ -0,0 +1,174 @@
+package ncd
+
+import (
+	"cmp"
+	"math"
+	"math/rand/v2"
+	"slices"
+)
+
+/*========================================================================
+	MATRIX DIAGNOSTICS
+········································································*/
+
+// Distances that differ by less than this are treated as equal by the diagnostics
+const diagnosticsTolerance = 1e-12
+
+// Entry of a distance matrix
+type MatrixEntry struct {
+	I, J  int
+	Value float64
+}
+
+// Triple of taxa where the distance between I and J is longer than the path through K
+type TriangleViolation struct {
+	I, J, K int
+	Excess  float64 // D(I, J) - (D(I, K) + D(K, J))
+}
+
+/*
+Checks of how far a distance matrix is from a tree metric.
+
+NCD values are not a true metric: they can fall outside [0, 1] and violate the triangle inequality. The delta score of
+Holland et al. (2002) measures how far the matrix is from satisfying the four-point condition, from 0 for distances
+that fit a tree exactly to 1 for distances with no tree-like signal.
+*/
+type MatrixDiagnostics struct {
+	OutOfRange   []MatrixEntry       // Values outside [0, 1]
+	NbTriples    int                 // Number of triples of taxa checked for the triangle inequality
+	NbViolations int                 // Number of triples that violate the triangle inequality
+	Violations   []TriangleViolation // The largest violations, by decreasing excess
+	NbQuartets   int                 // Number of quartets used for the delta scores
+	Sampled      bool                // The quartets are a random sample rather than all of them
+	Delta        float64             // Mean delta score of the quartets
+	TaxonDelta   []float64           // Mean delta score of the quartets that contain each taxon
+}
+
+// Delta score of a quartet: (m1 - m2) / (m1 - m3) for the sums of the distances of its three splits, largest first
+func quartetDelta(D *TriangularMatrix, x int, y int, u int, v int) float64 {
+	s := [3]float64{
+		D.Get(x, y) + D.Get(u, v),
+		D.Get(x, u) + D.Get(y, v),
+		D.Get(x, v) + D.Get(y, u),
+	}
+	m1 := max(s[0], s[1], s[2])
+	m3 := min(s[0], s[1], s[2])
+	m2 := s[0] + s[1] + s[2] - m1 - m3
+	if m1-m3 < diagnosticsTolerance {
+		return 0.0
+	}
+
+	return (m1 - m2) / (m1 - m3)
+}
+
+/*
+Check the values of a distance matrix, the triangle inequality, and the four-point condition.
+
+At most maxListed triangle violations are kept in the result, but all are counted. The delta scores are computed from
+all the quartets of taxa, or from maxQuartets random quartets drawn with the seed if there are more (all the quartets
+are used if maxQuartets is 0). The time is cubic in the number of taxa for the triangle inequality.
+*/
+func DiagnoseMatrix(D *TriangularMatrix, maxListed int, maxQuartets int, seed uint64) *MatrixDiagnostics {
+	n := D.N
+	diag := &MatrixDiagnostics{
+		OutOfRange: make([]MatrixEntry, 0),
+		Violations: make([]TriangleViolation, 0),
+		TaxonDelta: make([]float64, n),
+	}
+
+	for i := range n {
+		for j := range i {
+			if v := D.Get(i, j); v < 0.0 || v > 1.0 {
+				diag.OutOfRange = append(diag.OutOfRange, MatrixEntry{i, j, v})
+			}
+		}
+	}
+
+	// Only one of the three inequalities of a triple can be violated when the distances are not negative
+	for i := range n {
+		for j := range i {
+			for k := range j {
+				diag.NbTriples += 1
+				for _, t := range [3][3]int{{i, j, k}, {i, k, j}, {j, k, i}} {
+					excess := D.Get(t[0], t[1]) - D.Get(t[0], t[2]) - D.Get(t[2], t[1])
+					if excess > diagnosticsTolerance {
+						diag.NbViolations += 1
+						diag.Violations = append(diag.Violations, TriangleViolation{t[0], t[1], t[2], excess})
+						break
+					}
+				}
+			}
+		}
+		// Keep the list short on large matrices
+		if len(diag.Violations) > 4*maxListed+1024 {
+			sortViolations(diag.Violations)
+			diag.Violations = diag.Violations[:maxListed]
+		}
+	}
+	sortViolations(diag.Violations)
+	if len(diag.Violations) > maxListed {
+		diag.Violations = diag.Violations[:maxListed]
+	}
+
+	diag.Delta = math.NaN()
+	for i := range diag.TaxonDelta {
+		diag.TaxonDelta[i] = math.NaN()
+	}
+	if n < 4 {
+		return diag
+	}
+
+	sums := make([]float64, n)
+	counts := make([]int, n)
+	total := 0.0
+	addQuartet := func(q [4]int) {
+		delta := quartetDelta(D, q[0], q[1], q[2], q[3])
+		total += delta
+		for _, x := range q {
+			sums[x] += delta
+			counts[x] += 1
+		}
+		diag.NbQuartets += 1
+	}
+
+	nbAll := float64(n) * float64(n-1) * float64(n-2) * float64(n-3) / 24.0
+	if maxQuartets > 0 && nbAll > float64(maxQuartets) {
+		diag.Sampled = true
+		rng := rand.New(rand.NewPCG(seed, 0))
+		for range maxQuartets {
+			var q [4]int
+			for k := range q {
+				q[k] = rng.IntN(n)
+				for slices.Contains(q[:k], q[k]) {
+					q[k] = rng.IntN(n)
+				}
+			}
+			addQuartet(q)
+		}
+	} else {
+		for x := range n {
+			for y := range x {
+				for u := range y {
+					for v := range u {
+						addQuartet([4]int{x, y, u, v})
+					}
+				}
+			}
+		}
+	}
+
+	diag.Delta = total / float64(diag.NbQuartets)
+	for i := range n {
+		if counts[i] > 0 {
+			diag.TaxonDelta[i] = sums[i] / float64(counts[i])
+		}
+	}
+
+	return diag
+}
+
+func sortViolations(violations []TriangleViolation) {
+	slices.SortStableFunc(violations, func(a, b TriangleViolation) int {
+		return cmp.Compare(b.Excess, a.Excess)
+	})
+}
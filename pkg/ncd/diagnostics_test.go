@@ -0,0 +1,105 @@
+package ncd
+
+import (
+	"math"
+	"testing"
+)
+
+// Distances between the leaves of the tree ((a:1,b:2):1,(c:1,d:3):2,e:1)
+func additiveMatrix() *TriangularMatrix {
+	D := NewTriangularMatrix(5)
+	D.Set(1, 0, 3)
+	D.Set(2, 0, 5)
+	D.Set(3, 0, 7)
+	D.Set(4, 0, 3)
+	D.Set(2, 1, 6)
+	D.Set(3, 1, 8)
+	D.Set(4, 1, 4)
+	D.Set(3, 2, 4)
+	D.Set(4, 2, 4)
+	D.Set(4, 3, 6)
+	for i := range D.RawData {
+		D.RawData[i] /= 10
+	}
+
+	return D
+}
+
+func TestDiagnoseMatrixAdditive(t *testing.T) {
+	diag := DiagnoseMatrix(additiveMatrix(), 10, 0, 1)
+
+	if len(diag.OutOfRange) != 0 {
+		t.Errorf("expected no values out of range, got %v", diag.OutOfRange)
+	}
+	if diag.NbTriples != 10 || diag.NbViolations != 0 {
+		t.Errorf("expected 10 triples without violations, got %d and %d", diag.NbTriples, diag.NbViolations)
+	}
+	if diag.NbQuartets != 5 || diag.Sampled {
+		t.Errorf("expected all 5 quartets, got %d (sampled: %v)", diag.NbQuartets, diag.Sampled)
+	}
+	if diag.Delta > 1e-9 {
+		t.Errorf("delta of an additive matrix = %v, want 0", diag.Delta)
+	}
+	for i, d := range diag.TaxonDelta {
+		if d > 1e-9 {
+			t.Errorf("delta of taxon %d = %v, want 0", i, d)
+		}
+	}
+}
+
+func TestDiagnoseMatrixViolations(t *testing.T) {
+	D := NewTriangularMatrix(4)
+	D.Set(1, 0, 1.0)
+	D.Set(2, 0, 0.9)
+	D.Set(3, 0, 0.5)
+	D.Set(2, 1, 0.5)
+	D.Set(3, 1, 0.9)
+	D.Set(3, 2, 1.0)
+
+	diag := DiagnoseMatrix(D, 10, 0, 1)
+	if math.Abs(diag.Delta-0.2) > 1e-12 {
+		t.Errorf("delta = %v, want 0.2", diag.Delta)
+	}
+	if diag.NbViolations != 0 {
+		t.Errorf("expected no violations, got %v", diag.Violations)
+	}
+
+	D.Set(1, 0, 1.6)
+	D.Set(3, 2, -0.1)
+	diag = DiagnoseMatrix(D, 1, 0, 1)
+	if len(diag.OutOfRange) != 2 {
+		t.Errorf("expected 2 values out of range, got %v", diag.OutOfRange)
+	}
+	// D(0, 1) is longer than the paths through 2 and 3 by 0.2, and the negative D(2, 3) makes shortcuts of 0.5
+	if diag.NbViolations != 4 {
+		t.Errorf("expected 4 violations, got %d", diag.NbViolations)
+	}
+	if len(diag.Violations) != 1 {
+		t.Fatalf("expected 1 listed violation, got %d", len(diag.Violations))
+	}
+	v := diag.Violations[0]
+	if math.Abs(v.Excess-0.5) > 1e-12 {
+		t.Errorf("largest violation %+v, want an excess of 0.5", v)
+	}
+}
+
+func TestDiagnoseMatrixSampled(t *testing.T) {
+	D := NewTriangularMatrix(8)
+	for i := range D.RawData {
+		D.RawData[i] = 0.5 + 0.01*float64(i%7)
+	}
+
+	diag := DiagnoseMatrix(D, 10, 20, 1)
+	if !diag.Sampled || diag.NbQuartets != 20 {
+		t.Errorf("expected 20 sampled quartets, got %d (sampled: %v)", diag.NbQuartets, diag.Sampled)
+	}
+	again := DiagnoseMatrix(D, 10, 20, 1)
+	if diag.Delta != again.Delta {
+		t.Errorf("the same seed gave different deltas: %v and %v", diag.Delta, again.Delta)
+	}
+
+	small := DiagnoseMatrix(NewTriangularMatrix(3), 10, 0, 1)
+	if small.NbQuartets != 0 || !math.IsNaN(small.Delta) {
+		t.Errorf("expected no quartet and a NaN delta with 3 taxa, got %d and %v", small.NbQuartets, small.Delta)
+	}
+}
@@ -18,15 +18,26 @@ Creates a vector with the compressed sizes of a list of sequences.
 Vector element type is float64 for other NCD calculations.
 */
 func CXVector(seqs *[][]byte, mc ManagedCompressor) []float64 {
-	N := len(*seqs)
+	cx, _ := CXVectorFrom(SliceSource(*seqs), mc)
+
+	return cx
+}
+
+// Same as CXVector, with the sequences fetched from a source
+func CXVectorFrom(source SequenceSource, mc ManagedCompressor) ([]float64, error) {
+	N := source.Len()
 	cx := make([]float64, N)
 
-	for i, s := range *seqs {
+	for i := range N {
+		s, err := source.Sequence(i)
+		if err != nil {
+			return nil, err
+		}
 		mc.Send(s)
 		cx[i] = float64(mc.Process())
 	}
 
-	return cx
+	return cx, nil
 }
 
 /*
@@ -34,39 +45,145 @@ Creates a vector with the compressed sizes of a list of sequences concatenated w
 Vector element type is float64 for consistance with CXVector.
 */
 func CXXVector(seqs *[][]byte, mc ManagedCompressor) []float64 {
-	N := len(*seqs)
-	cxx := make([]float64, N)
+	cxx, _ := CXXVectorFrom(SliceSource(*seqs), mc)
 
-	// mc.Process()
+	return cxx
+}
+
+// Same as CXXVector, with the sequences fetched from a source
+func CXXVectorFrom(source SequenceSource, mc ManagedCompressor) ([]float64, error) {
+	N := source.Len()
+	cxx := make([]float64, N)
 
-	for i, s := range *seqs {
+	for i := range N {
+		s, err := source.Sequence(i)
+		if err != nil {
+			return nil, err
+		}
 		mc.Send(s)
 		mc.Send(s)
 		cxx[i] = float64(mc.Process())
 	}
 
-	return cxx
+	return cxx, nil
 }
 
 /*
 Creates an NCD matrix from a list of sequences, using a pre-computed vector compressed sizes
 */
 func NCDMatrix(seqs *[][]byte, cx *[]float64, mc ManagedCompressor) *TriangularMatrix {
-	N := len(*seqs)
+	D, _ := NCDMatrixFrom(SliceSource(*seqs), cx, mc, nil)
+
+	return D
+}
+
+/*
+Same as NCDMatrix, with the sequences fetched from a source.
+
+Each row is traversed in the opposite direction of the previous one, so that a source with a cache of recently used
+sequences (CachedSource) finds the sequences at the start of a row among those it has just read. The progress function,
+if not nil, is called after each of the N(N-1)/2 pairs.
+*/
+func NCDMatrixFrom(source SequenceSource, cx *[]float64, mc ManagedCompressor, progress ProgressFunc) (*TriangularMatrix, error) {
+	N := source.Len()
 	D := NewTriangularMatrix(N)
+	counter := newProgressCounter(progress, N*(N-1)/2)
 
 	mc.Process()
 
 	for i := 0; i < N; i += 1 {
+		a, err := source.Sequence(i)
+		if err != nil {
+			return nil, err
+		}
 		ca := (*cx)[i]
-		for j := 0; j < i; j += 1 {
+		for k := 0; k < i; k += 1 {
+			j := k
+			if i%2 == 1 {
+				j = i - 1 - k
+			}
+			b, err := source.Sequence(j)
+			if err != nil {
+				return nil, err
+			}
 			cb := (*cx)[j]
-			mc.Send((*seqs)[i])
-			mc.Send((*seqs)[j])
+			mc.Send(a)
+			mc.Send(b)
 			cab := float64(mc.Process())
 			D.Set(i, j, NCD(ca, cb, cab))
+			counter.step()
 		}
 	}
 
-	return D
+	return D, nil
+}
+
+/*
+Compute the NCD distance between two sequences from their compressed sizes x and y, and their conditional compressed
+sizes x|y (of x with y as the reference of the compressor) and y|x.
+
+Formula after Li et al. (2004): NCD(x, y) = max(x|y, y|x) / max(x, y)
+*/
+func ConditionalNCD(x float64, y float64, xGivenY float64, yGivenX float64) float64 {
+	return max(xGivenY, yGivenX) / max(x, y)
+}
+
+/*
+Creates an NCD matrix from conditional compressed sizes (see ConditionalNCD), with the sequences fetched from a source.
+
+The compressor is primed once with each sequence, which is then the reference for the compression of all the other
+sequences. This takes twice as many compressions as NCDMatrixFrom, but of single sequences rather than
+concatenations, which is faster for long sequences. The progress function, if not nil, is called after each of the
+N(N-1) ordered pairs.
+*/
+func ConditionalNCDMatrixFrom(source SequenceSource, cx *[]float64, pc PrimableCompressor, progress ProgressFunc) (*TriangularMatrix, error) {
+	N := source.Len()
+	D := NewTriangularMatrix(N)
+	given := NewTriangularMatrix(N) // C(x_i | x_j) for i > j, and C(x_j | x_i) in D
+	counter := newProgressCounter(progress, N*(N-1))
+
+	pc.Process()
+
+	for i := 0; i < N; i += 1 {
+		reference, err := source.Sequence(i)
+		if err != nil {
+			return nil, err
+		}
+		if err := pc.Prime(reference); err != nil {
+			return nil, err
+		}
+		for k := 0; k < N; k += 1 {
+			j := k
+			if i%2 == 1 {
+				j = N - 1 - k
+			}
+			if j == i {
+				continue
+			}
+			b, err := source.Sequence(j)
+			if err != nil {
+				return nil, err
+			}
+			pc.Send(b)
+			c := float64(pc.Process())
+			if j < i {
+				D.Set(i, j, c)
+			} else {
+				given.Set(j, i, c)
+			}
+			counter.step()
+		}
+	}
+
+	if err := pc.Prime(nil); err != nil {
+		return nil, err
+	}
+
+	for i := 0; i < N; i += 1 {
+		for j := 0; j < i; j += 1 {
+			D.Set(i, j, ConditionalNCD((*cx)[i], (*cx)[j], given.Get(i, j), D.Get(i, j)))
+		}
+	}
+
+	return D, nil
 }
@@ -2,6 +2,8 @@ package ncd
 
 import (
 	// "bytes"
+	"math"
+	"math/rand"
 	"testing"
 )
 
@@ -19,6 +21,35 @@ func (fc *fakeCompressor) Process() int {
 	return out
 }
 
+/*
+Compressor that counts the bytes sent, less the length of their common prefix with the reference, so that C(x|y) is
+the length of x without its longest prefix shared with y.
+*/
+type fakePrimableCompressor struct {
+	fakeCompressor
+	reference []byte
+	data      []byte
+}
+
+func (fc *fakePrimableCompressor) Send(data []byte) (int, error) {
+	fc.data = append(fc.data, data...)
+	return fc.fakeCompressor.Send(data)
+}
+
+func (fc *fakePrimableCompressor) Process() int {
+	shared := 0
+	for shared < min(len(fc.data), len(fc.reference)) && fc.data[shared] == fc.reference[shared] {
+		shared += 1
+	}
+	fc.data = fc.data[:0]
+	return fc.fakeCompressor.Process() - shared
+}
+
+func (fc *fakePrimableCompressor) Prime(reference []byte) error {
+	fc.reference = reference
+	return nil
+}
+
 func TestNCD_CXVector_CXXVector_NCDMatrix(t *testing.T) {
 	seqInputs := [][]byte{
 		[]byte("A"),
@@ -110,3 +141,89 @@ func TestNCD(t *testing.T) {
 		}
 	}
 }
+
+func TestConditionalNCD(t *testing.T) {
+	tests := []struct {
+		x, y, xGivenY, yGivenX float64
+		want                   float64
+	}{
+		{1, 1, 1, 1, 1},
+		{2, 4, 1, 2, 0.5},
+		{4, 2, 2, 1, 0.5},
+		{4, 4, 0, 0, 0},
+	}
+	for _, tt := range tests {
+		got := ConditionalNCD(tt.x, tt.y, tt.xGivenY, tt.yGivenX)
+		if got != tt.want {
+			t.Errorf("ConditionalNCD(%v,%v,%v,%v) = %v, want %v", tt.x, tt.y, tt.xGivenY, tt.yGivenX, got, tt.want)
+		}
+	}
+}
+
+func TestConditionalNCDMatrixFrom(t *testing.T) {
+	seqs := SliceSource{
+		[]byte("ABCD"),
+		[]byte("ABCDEF"),
+		[]byte("ABXY"),
+		[]byte("ZZ"),
+	}
+	fc := &fakePrimableCompressor{}
+	cx, _ := CXVectorFrom(seqs, fc)
+	D, err := ConditionalNCDMatrixFrom(seqs, &cx, fc, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// C(x|y) = len(x) - len(common prefix)
+	want := map[[2]int]float64{
+		{1, 0}: 2.0 / 6.0, // max(2, 0) / 6
+		{2, 0}: 2.0 / 4.0,
+		{2, 1}: 4.0 / 6.0, // max(2, 4) / 6
+		{3, 0}: 4.0 / 4.0,
+		{3, 1}: 6.0 / 6.0,
+		{3, 2}: 4.0 / 4.0,
+	}
+	for ij, w := range want {
+		if got := D.Get(ij[0], ij[1]); math.Abs(got-w) > 1e-12 {
+			t.Errorf("D[%d,%d] = %v, want %v", ij[0], ij[1], got, w)
+		}
+	}
+	if fc.reference != nil {
+		t.Error("the compressor is still primed")
+	}
+}
+
+// Zstd estimates of the NCD from concatenations and from conditional compression should agree
+func TestConditionalNCDMatrixZstd(t *testing.T) {
+	rng := rand.New(rand.NewSource(1))
+	base := make([]byte, 20000)
+	for i := range base {
+		base[i] = "ACGT"[rng.Intn(4)]
+	}
+	seqs := SliceSource{base, append([]byte{}, base...), make([]byte, 20000)}
+	for i := 0; i < len(base); i += 50 {
+		seqs[1][i] = 'A'
+	}
+	for i := range seqs[2] {
+		seqs[2][i] = "ACGT"[rng.Intn(4)]
+	}
+
+	mc := NewManagedCompressorZstd()
+	cx, _ := CXVectorFrom(seqs, mc)
+	concatenation, _ := NCDMatrixFrom(seqs, &cx, mc, nil)
+	conditional, err := ConditionalNCDMatrixFrom(seqs, &cx, mc, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for i := range 3 {
+		for j := range i {
+			if d := math.Abs(concatenation.Get(i, j) - conditional.Get(i, j)); d > 0.05 {
+				t.Errorf("NCD[%d,%d]: concatenation %v, conditional %v", i, j, concatenation.Get(i, j), conditional.Get(i, j))
+			}
+		}
+	}
+	if conditional.Get(1, 0) > 0.5 || conditional.Get(2, 0) < 0.9 {
+		t.Errorf("unexpected conditional NCD: similar %v, unrelated %v", conditional.Get(1, 0), conditional.Get(2, 0))
+	}
+}
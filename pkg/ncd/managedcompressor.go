@@ -4,6 +4,7 @@ import (
 	"compress/gzip"
 
 	"github.com/google/brotli/go/cbrotli"
+	"github.com/klauspost/compress/zstd"
 )
 
 /*========================================================================
@@ -19,6 +20,17 @@ type ManagedCompressor interface {
 	Process() int
 }
 
+/*
+Compressor that can be primed with a reference, which it uses as a dictionary to compress the data sent after it.
+The compressed size of y with x as the reference is an estimate of the conditional compressed size C(y|x).
+*/
+type PrimableCompressor interface {
+	ManagedCompressor
+
+	// Sets the reference of the data sent until the next call to Prime (no reference if nil)
+	Prime(reference []byte) error
+}
+
 /*========================================================================
 	BYTE COUNTER
 ········································································*/
@@ -112,3 +124,71 @@ func (mc *ManagedCompressorBrotli) Process() int {
 
 	return b
 }
+
+/*=======================================================================
+	ZSTD
+·······································································*/
+
+// Window size of the Zstd compressor (16 MiB, as the largest Brotli window)
+const zstdWindowSize = 1 << 24
+
+/*
+Wrapper for the Zstd compressor at its best compression level, implements the PrimableCompressor interface.
+
+The data sent is buffered, and compressed as a single frame by Process, since the encoder has to load the
+reference again at the start of each frame. Brotli also supports dictionaries in its C library, but not through its
+Go bindings, so only Zstd can be primed.
+*/
+type ManagedCompressorZstd struct {
+	data       []byte
+	compressed []byte
+	compressor *zstd.Encoder
+}
+
+func newZstdEncoder(reference []byte) (*zstd.Encoder, error) {
+	opts := []zstd.EOption{
+		zstd.WithEncoderLevel(zstd.SpeedBestCompression),
+		zstd.WithWindowSize(zstdWindowSize),
+		zstd.WithEncoderConcurrency(1),
+		zstd.WithEncoderCRC(false),
+	}
+	if reference != nil {
+		opts = append(opts, zstd.WithEncoderDictRaw(1, reference))
+	}
+
+	return zstd.NewWriter(nil, opts...)
+}
+
+func NewManagedCompressorZstd() *ManagedCompressorZstd {
+	compressor, err := newZstdEncoder(nil)
+	if err != nil {
+		panic(err) // The options are valid
+	}
+
+	return &ManagedCompressorZstd{compressor: compressor}
+}
+
+func (mc *ManagedCompressorZstd) Send(data []byte) (int, error) {
+	mc.data = append(mc.data, data...)
+
+	return len(data), nil
+}
+
+func (mc *ManagedCompressorZstd) Process() int {
+	mc.compressed = mc.compressor.EncodeAll(mc.data, mc.compressed[:0])
+	mc.data = mc.data[:0]
+
+	return len(mc.compressed)
+}
+
+func (mc *ManagedCompressorZstd) Prime(reference []byte) error {
+	compressor, err := newZstdEncoder(reference)
+	if err != nil {
+		return err
+	}
+	mc.compressor.Close()
+	mc.data = mc.data[:0]
+	mc.compressor = compressor
+
+	return nil
+}
@@ -0,0 +1,196 @@
+package ncd
+
+import (
+	"encoding/csv"
+	"fmt"
+	"io"
+	"strconv"
+)
+
+// File format for writing distance matrices
+type MatrixFormat int
+
+const (
+	// Labelled lower triangle, without the diagonal
+	FormatLower MatrixFormat = iota
+	// Square matrix preceded by the number of taxa, as used by PHYLIP
+	FormatPhylip
+	// Square matrix with a header of taxon names, comma-separated
+	FormatCSV
+	// Square matrix with taxon names in the first column, tab-separated
+	FormatTSV
+)
+
+// Names of the matrix formats, in the order of the MatrixFormat constants
+var MatrixFormatNames = []string{"lower", "phylip", "csv", "tsv"}
+
+func (format MatrixFormat) String() string {
+	return MatrixFormatNames[format]
+}
+
+// Return the usual file extension of the matrix format, without the leading dot
+func (format MatrixFormat) Extension() string {
+	switch format {
+	case FormatPhylip:
+		return "phy"
+	case FormatCSV:
+		return "csv"
+	case FormatTSV:
+		return "tsv"
+	default:
+		return "txt"
+	}
+}
+
+// Get the matrix format that matches a name in MatrixFormatNames
+func ParseMatrixFormat(name string) (MatrixFormat, error) {
+	for i, s := range MatrixFormatNames {
+		if s == name {
+			return MatrixFormat(i), nil
+		}
+	}
+
+	return FormatLower, fmt.Errorf("unknown matrix format \"%s\"", name)
+}
+
+/*
+Write a labelled matrix in the given format, with p significant digits.
+
+Returns the number of bytes written.
+*/
+func WriteMatrix(buf io.Writer, labels *[]string, M *TriangularMatrix, format MatrixFormat, p int) (int, error) {
+	switch format {
+	case FormatPhylip:
+		return WritePhylipMatrix(buf, labels, M, p)
+	case FormatCSV:
+		return WriteCSVMatrix(buf, labels, M, p)
+	case FormatTSV:
+		return WriteSquareTSVMatrix(buf, labels, M, p)
+	default:
+		return WriteLabelledTriangularMatrix(buf, labels, M, p)
+	}
+}
+
+// Get the value of position (i, j) in the full square matrix, with zeros in the diagonal
+func (m *TriangularMatrix) getSquare(i int, j int) float64 {
+	if i == j {
+		return 0.0
+	}
+
+	return m.Get(i, j)
+}
+
+/*
+Write a square matrix in the PHYLIP distance matrix format.
+
+The first line holds the number of taxa. Taxon names are padded to at least 10 characters, as in the relaxed PHYLIP
+format.
+*/
+func WritePhylipMatrix(buf io.Writer, labels *[]string, M *TriangularMatrix, p int) (int, error) {
+	if len(*labels) != M.N {
+		return 0, fmt.Errorf("number of labels differs (%d) from the number of rows (%d)", len(*labels), M.N)
+	}
+
+	b := 0  // Count of written bytes
+	bb := 0 // Count of written bytes by a single write attempt
+	var err error
+
+	printWidth := 10
+	for _, s := range *labels {
+		printWidth = max(printWidth, len(s)+1)
+	}
+
+	bb, err = fmt.Fprintf(buf, "%d\n", M.N)
+	b += bb
+	if err != nil {
+		return b, err
+	}
+
+	for i := range M.N {
+		bb, err = fmt.Fprintf(buf, "%-*s", printWidth, (*labels)[i])
+		b += bb
+		if err != nil {
+			return b, err
+		}
+		for j := range M.N {
+			if j > 0 {
+				bb, _ = fmt.Fprint(buf, " ")
+				b += bb
+			}
+			bb, err = fmt.Fprint(buf, strconv.FormatFloat(M.getSquare(i, j), 'g', p, 64))
+			b += bb
+			if err != nil {
+				return b, err
+			}
+		}
+		bb, _ = fmt.Fprint(buf, "\n")
+		b += bb
+	}
+
+	return b, nil
+}
+
+/*
+Write a square matrix in CSV format.
+
+The first row is a header with the taxon names, preceded by an empty field. Each of the other rows starts with the
+name of a taxon.
+*/
+func WriteCSVMatrix(buf io.Writer, labels *[]string, M *TriangularMatrix, p int) (int, error) {
+	if len(*labels) != M.N {
+		return 0, fmt.Errorf("number of labels differs (%d) from the number of rows (%d)", len(*labels), M.N)
+	}
+
+	counter := &ByteCounter{}
+	writer := csv.NewWriter(io.MultiWriter(buf, counter))
+
+	record := make([]string, M.N+1)
+	copy(record[1:], *labels)
+	writer.Write(record)
+
+	for i := range M.N {
+		record[0] = (*labels)[i]
+		for j := range M.N {
+			record[j+1] = strconv.FormatFloat(M.getSquare(i, j), 'g', p, 64)
+		}
+		writer.Write(record)
+	}
+
+	writer.Flush()
+
+	return counter.nBytes, writer.Error()
+}
+
+/*
+Write a square matrix with tab-separated fields.
+
+There is no header. The first field of each row is the name of a taxon, as expected by phylocore.ReadDistanceMatrix.
+*/
+func WriteSquareTSVMatrix(buf io.Writer, labels *[]string, M *TriangularMatrix, p int) (int, error) {
+	if len(*labels) != M.N {
+		return 0, fmt.Errorf("number of labels differs (%d) from the number of rows (%d)", len(*labels), M.N)
+	}
+
+	b := 0  // Count of written bytes
+	bb := 0 // Count of written bytes by a single write attempt
+	var err error
+
+	for i := range M.N {
+		bb, err = fmt.Fprint(buf, (*labels)[i])
+		b += bb
+		if err != nil {
+			return b, err
+		}
+		for j := range M.N {
+			bb, err = fmt.Fprint(buf, "\t", strconv.FormatFloat(M.getSquare(i, j), 'g', p, 64))
+			b += bb
+			if err != nil {
+				return b, err
+			}
+		}
+		bb, _ = fmt.Fprint(buf, "\n")
+		b += bb
+	}
+
+	return b, nil
+}
@@ -0,0 +1,53 @@
+package ncd
+
+import (
+	"bytes"
+	"testing"
+)
+
+func makeLabelledMatrix() (*[]string, *TriangularMatrix) {
+	labels := []string{"A", "B", "C"}
+	m := NewTriangularMatrix(3)
+	m.Set(1, 0, 0.5)
+	m.Set(2, 0, 0.25)
+	m.Set(2, 1, 0.75)
+
+	return &labels, m
+}
+
+func TestWriteMatrix(t *testing.T) {
+	tests := []struct {
+		format MatrixFormat
+		want   string
+	}{
+		{FormatPhylip, "3\nA         0 0.5 0.25\nB         0.5 0 0.75\nC         0.25 0.75 0\n"},
+		{FormatCSV, ",A,B,C\nA,0,0.5,0.25\nB,0.5,0,0.75\nC,0.25,0.75,0\n"},
+		{FormatTSV, "A\t0\t0.5\t0.25\nB\t0.5\t0\t0.75\nC\t0.25\t0.75\t0\n"},
+	}
+	for _, tt := range tests {
+		labels, m := makeLabelledMatrix()
+		var buf bytes.Buffer
+		n, err := WriteMatrix(&buf, labels, m, tt.format, 4)
+		if err != nil {
+			t.Errorf("%s: unexpected error: %v", tt.format, err)
+			continue
+		}
+		if buf.String() != tt.want {
+			t.Errorf("%s: got %q, want %q", tt.format, buf.String(), tt.want)
+		}
+		if n != buf.Len() {
+			t.Errorf("%s: reported %d bytes written, want %d", tt.format, n, buf.Len())
+		}
+	}
+}
+
+func TestWriteMatrix_LabelMismatch(t *testing.T) {
+	labels := []string{"A", "B"}
+	m := NewTriangularMatrix(3)
+	for _, format := range []MatrixFormat{FormatLower, FormatPhylip, FormatCSV, FormatTSV} {
+		var buf bytes.Buffer
+		if _, err := WriteMatrix(&buf, &labels, m, format, 4); err == nil {
+			t.Errorf("%s: expected error, got nil", format)
+		}
+	}
+}
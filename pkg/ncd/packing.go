@@ -0,0 +1,199 @@
+package ncd
+
+import (
+	"encoding/binary"
+)
+
+/*========================================================================
+	2-BIT NUCLEOTIDE PACKING
+········································································*/
+
+// 2-bit codes of the nucleotides, 0xff for the other bytes
+var nucleotideCodes = func() [256]byte {
+	var codes [256]byte
+	for i := range codes {
+		codes[i] = 0xff
+	}
+	for code, pair := range []string{"Aa", "Cc", "Gg", "Tt"} {
+		codes[pair[0]] = byte(code)
+		codes[pair[1]] = byte(code)
+	}
+
+	return codes
+}()
+
+const nucleotideLetters = "ACGT"
+
+// Return the 2-bit code of a nucleotide (A, C, G or T, in either case), and false for the other bytes
+func NucleotideCode(b byte) (byte, bool) {
+	code := nucleotideCodes[b]
+	return code, code != 0xff
+}
+
+/*
+Streaming encoder of nucleotide sequences into 2 bits per base, four bases per byte.
+
+Each chunk of bases is packed from a byte boundary, so that a compressor can match a chunk with an earlier copy of it
+(as when a sequence is concatenated with itself). Bytes other than A, C, G and T (in either case) go to a separate
+exception stream as runs of identical bytes, each coded as the distance from the end of the previous run, the length
+of the run and the byte, and are packed as 'A'. Case is not preserved.
+*/
+type nucleotidePacker struct {
+	packed     []byte // Packed bytes of the last chunk
+	nbBases    int    // Total number of bases written
+	exceptions []byte
+	nbRuns     int  // Number of runs in exceptions
+	runByte    byte // Byte of the current run of exceptions
+	runStart   int  // Position of the current run (-1 if none)
+	runLength  int
+	lastEnd    int // End position of the last finished run
+}
+
+func newNucleotidePacker() *nucleotidePacker {
+	return &nucleotidePacker{runStart: -1}
+}
+
+func (p *nucleotidePacker) endRun() {
+	if p.runStart < 0 {
+		return
+	}
+	p.exceptions = binary.AppendUvarint(p.exceptions, uint64(p.runStart-p.lastEnd))
+	p.exceptions = binary.AppendUvarint(p.exceptions, uint64(p.runLength))
+	p.exceptions = append(p.exceptions, p.runByte)
+	p.lastEnd = p.runStart + p.runLength
+	p.runStart = -1
+	p.nbRuns += 1
+}
+
+// Pack a chunk of bases, and return the packed bytes (valid until the next call)
+func (p *nucleotidePacker) write(data []byte) []byte {
+	p.packed = p.packed[:0]
+	var current byte
+
+	for k, b := range data {
+		code, ok := NucleotideCode(b)
+		if !ok {
+			if p.runStart >= 0 && b == p.runByte && p.runStart+p.runLength == p.nbBases {
+				p.runLength += 1
+			} else {
+				p.endRun()
+				p.runByte, p.runStart, p.runLength = b, p.nbBases, 1
+			}
+			code = 0
+		}
+
+		current |= code << (6 - 2*(k%4))
+		p.nbBases += 1
+		if k%4 == 3 {
+			p.packed = append(p.packed, current)
+			current = 0
+		}
+	}
+	if len(data)%4 != 0 {
+		p.packed = append(p.packed, current)
+	}
+
+	return p.packed
+}
+
+/*
+Finish the packing, and return the exception stream, preceded by its number of runs. The packer is reset.
+*/
+func (p *nucleotidePacker) finish() []byte {
+	p.endRun()
+
+	exceptions := binary.AppendUvarint(nil, uint64(p.nbRuns))
+	exceptions = append(exceptions, p.exceptions...)
+
+	*p = nucleotidePacker{packed: p.packed, exceptions: p.exceptions[:0], runStart: -1}
+
+	return exceptions
+}
+
+/*
+Pack a nucleotide sequence into 2 bits per base, with a separate stream for the other bytes.
+
+See UnpackNucleotides for the reverse operation.
+*/
+func PackNucleotides(seq []byte) ([]byte, []byte) {
+	p := newNucleotidePacker()
+	packed := append([]byte{}, p.write(seq)...)
+
+	return packed, p.finish()
+}
+
+/*
+Unpack n bases packed by PackNucleotides, in upper case except for the exceptions.
+
+Returns nil if the streams are inconsistent.
+*/
+func UnpackNucleotides(packed []byte, exceptions []byte, n int) []byte {
+	if len(packed) != (n+3)/4 {
+		return nil
+	}
+
+	seq := make([]byte, n)
+	for i := range n {
+		seq[i] = nucleotideLetters[(packed[i/4]>>(6-2*(i%4)))&3]
+	}
+
+	nbRuns, k := binary.Uvarint(exceptions)
+	if k <= 0 {
+		return nil
+	}
+	rest := exceptions[k:]
+	pos := 0
+	for range nbRuns {
+		gap, k1 := binary.Uvarint(rest)
+		if k1 <= 0 {
+			return nil
+		}
+		length, k2 := binary.Uvarint(rest[k1:])
+		if k2 <= 0 || len(rest) < k1+k2+1 {
+			return nil
+		}
+		start := pos + int(gap)
+		if start+int(length) > n {
+			return nil
+		}
+		for i := start; i < start+int(length); i++ {
+			seq[i] = rest[k1+k2]
+		}
+		pos = start + int(length)
+		rest = rest[k1+k2+1:]
+	}
+
+	return seq
+}
+
+/*
+Wrapper of a compressor that packs nucleotide sequences into 2 bits per base before compression, with a separate
+exception stream for N, IUPAC ambiguity codes and other bytes.
+
+The data of each call to Send starts on a byte boundary. The exception stream of all the data sent is compressed
+after the packed bases. Implements the ManagedCompressor interface.
+*/
+type ManagedCompressorPacked struct {
+	compressor ManagedCompressor
+	packer     *nucleotidePacker
+}
+
+func NewManagedCompressorPacked(compressor ManagedCompressor) *ManagedCompressorPacked {
+	return &ManagedCompressorPacked{compressor, newNucleotidePacker()}
+}
+
+func (mc *ManagedCompressorPacked) Send(data []byte) (int, error) {
+	if packed := mc.packer.write(data); len(packed) > 0 {
+		if _, err := mc.compressor.Send(packed); err != nil {
+			return 0, err
+		}
+	}
+
+	return len(data), nil
+}
+
+func (mc *ManagedCompressorPacked) Process() int {
+	mc.compressor.Send(mc.packer.finish())
+
+	return mc.compressor.Process()
+}
@@ -0,0 +1,88 @@
+package ncd
+
+import (
+	"math/rand/v2"
+	"testing"
+)
+
+func TestPackNucleotides_RoundTrip(t *testing.T) {
+	tests := []struct {
+		seq  string
+		want string
+	}{
+		{"", ""},
+		{"A", "A"},
+		{"ACGTACG", "ACGTACG"},
+		{"acgtNNNNacgRYnNN-", "ACGTNNNNACGRYnNN-"},
+		{"NNNN", "NNNN"},
+	}
+
+	for _, tt := range tests {
+		packed, exceptions := PackNucleotides([]byte(tt.seq))
+		if len(packed) != (len(tt.seq)+3)/4 {
+			t.Errorf("%q: got %d packed bytes, want %d", tt.seq, len(packed), (len(tt.seq)+3)/4)
+		}
+		got := UnpackNucleotides(packed, exceptions, len(tt.seq))
+		if string(got) != tt.want {
+			t.Errorf("%q: unpacked %q, want %q", tt.seq, got, tt.want)
+		}
+	}
+
+	packed, exceptions := PackNucleotides([]byte("ACGTN"))
+	if UnpackNucleotides(packed, exceptions, 9) != nil || UnpackNucleotides(packed, exceptions[:2], 5) != nil {
+		t.Errorf("expected nil for inconsistent streams")
+	}
+}
+
+func TestNucleotideCode(t *testing.T) {
+	for i, letter := range nucleotideLetters {
+		for _, b := range []byte{byte(letter), byte(letter) + 'a' - 'A'} {
+			if code, ok := NucleotideCode(b); !ok || code != byte(i) {
+				t.Errorf("NucleotideCode(%q) = %d, %v, want %d", b, code, ok, i)
+			}
+		}
+	}
+	for _, b := range []byte("NnUu-\n") {
+		if _, ok := NucleotideCode(b); ok {
+			t.Errorf("NucleotideCode(%q) should not be a nucleotide", b)
+		}
+	}
+}
+
+func TestManagedCompressorPacked(t *testing.T) {
+	fc := &fakeCompressor{}
+	mc := NewManagedCompressorPacked(fc)
+
+	mc.Send([]byte("ACGTA")) // 2 bytes
+	mc.Send([]byte("NN"))    // 1 byte
+	// Exceptions: 1 run, at distance 5, of length 2, of 'N'
+	if got := mc.Process(); got != 7 {
+		t.Errorf("got compressed size %d, want 7", got)
+	}
+
+	// The packer is reset by Process
+	mc.Send([]byte("ACGT"))
+	if got := mc.Process(); got != 2 {
+		t.Errorf("got compressed size %d after reset, want 2", got)
+	}
+}
+
+func TestManagedCompressorPacked_SelfNCD(t *testing.T) {
+	rng := rand.New(rand.NewPCG(1, 2))
+	seq := make([]byte, 10001) // Not a multiple of 4
+	for i := range seq {
+		seq[i] = "ACGT"[rng.IntN(4)]
+	}
+
+	mc := NewManagedCompressorPacked(NewManagedCompressorGzip())
+	cx := CXVector(&[][]byte{seq}, mc)
+	cxx := CXXVector(&[][]byte{seq}, mc)
+	if selfNCD := NCD(cx[0], cx[0], cxx[0]); selfNCD > 0.1 {
+		t.Errorf("self NCD with packing = %v, want close to 0", selfNCD)
+	}
+
+	// Random bases cannot be compressed below 2 bits each
+	if unpacked := CXVector(&[][]byte{seq}, NewManagedCompressorGzip()); cx[0] >= unpacked[0] || cx[0] < 2500 {
+		t.Errorf("packed size %v, unpacked size %v", cx[0], unpacked[0])
+	}
+}
@@ -0,0 +1,62 @@
+package ncd
+
+import (
+	"time"
+)
+
+// Progress of the computation of a distance matrix
+type Progress struct {
+	Done    int           // Number of pairs computed
+	Total   int           // Number of pairs to compute
+	Elapsed time.Duration // Time since the computation started
+}
+
+// Number of pairs computed per second
+func (p Progress) Throughput() float64 {
+	if p.Elapsed <= 0 {
+		return 0.0
+	}
+
+	return float64(p.Done) / p.Elapsed.Seconds()
+}
+
+// Estimated time until all pairs are computed, at the throughput so far (0 if no pair has been computed)
+func (p Progress) ETA() time.Duration {
+	if p.Done == 0 {
+		return 0
+	}
+
+	return time.Duration(float64(p.Elapsed) * float64(p.Total-p.Done) / float64(p.Done))
+}
+
+/*
+Function called with the progress of a computation, once before the first pair and after each pair.
+
+It is called from the goroutine of the computation, which it slows down, so it should return quickly and limit the
+rate of its own output.
+*/
+type ProgressFunc func(Progress)
+
+// Counter of computed pairs, which reports to a progress function if there is one
+type progressCounter struct {
+	progress ProgressFunc
+	done     int
+	total    int
+	start    time.Time
+}
+
+func newProgressCounter(progress ProgressFunc, total int) *progressCounter {
+	c := &progressCounter{progress, 0, total, time.Now()}
+	if progress != nil {
+		progress(Progress{0, total, 0})
+	}
+
+	return c
+}
+
+func (c *progressCounter) step() {
+	c.done += 1
+	if c.progress != nil {
+		c.progress(Progress{c.done, c.total, time.Since(c.start)})
+	}
+}
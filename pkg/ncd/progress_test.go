@@ -0,0 +1,45 @@
+package ncd
+
+import (
+	"testing"
+	"time"
+)
+
+func TestProgress(t *testing.T) {
+	p := Progress{Done: 25, Total: 100, Elapsed: 5 * time.Second}
+	if got := p.Throughput(); got != 5.0 {
+		t.Errorf("Throughput() = %v, want 5", got)
+	}
+	if got := p.ETA(); got != 15*time.Second {
+		t.Errorf("ETA() = %v, want 15s", got)
+	}
+
+	p = Progress{Done: 0, Total: 100, Elapsed: 0}
+	if p.Throughput() != 0.0 || p.ETA() != 0 {
+		t.Errorf("expected zero throughput and ETA before the first pair, got %v and %v", p.Throughput(), p.ETA())
+	}
+}
+
+func TestNCDMatrixFromProgress(t *testing.T) {
+	seqs := SliceSource{[]byte("A"), []byte("AB"), []byte("ABC"), []byte("ABCD")}
+	cx, _ := CXVectorFrom(seqs, &fakeCompressor{})
+
+	reports := make([]Progress, 0)
+	record := func(p Progress) { reports = append(reports, p) }
+
+	NCDMatrixFrom(seqs, &cx, &fakeCompressor{}, record)
+	if len(reports) != 7 {
+		t.Fatalf("%d reports, want 7", len(reports))
+	}
+	for k, p := range reports {
+		if p.Done != k || p.Total != 6 {
+			t.Errorf("report %d: %d of %d pairs done", k, p.Done, p.Total)
+		}
+	}
+
+	reports = reports[:0]
+	ConditionalNCDMatrixFrom(seqs, &cx, &fakePrimableCompressor{}, record)
+	if last := reports[len(reports)-1]; len(reports) != 13 || last.Done != 12 || last.Total != 12 {
+		t.Errorf("%d reports, the last with %d of %d pairs done", len(reports), last.Done, last.Total)
+	}
+}
@@ -0,0 +1,337 @@
+package ncd
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/sha256"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+/*========================================================================
+	CACHE OF COMPRESSED SIZES
+········································································*/
+
+// First bytes of a size cache file
+const sizeCacheMagic = "NCDSIZE1"
+
+// Size of a record of a size cache file: key, compressed size, time when it was added
+const sizeCacheRecordSize = SizeKeySize + 8 + 8
+
+// Size of the keys of a size cache, truncated SHA-256 digests
+const SizeKeySize = 16
+
+type SizeKey [SizeKeySize]byte
+
+// Entry of a size cache
+type SizeRecord struct {
+	Key   SizeKey
+	Size  int
+	Added time.Time
+}
+
+func (r SizeRecord) appendTo(b []byte) []byte {
+	b = append(b, r.Key[:]...)
+	b = binary.LittleEndian.AppendUint64(b, uint64(r.Size))
+	b = binary.LittleEndian.AppendUint64(b, uint64(r.Added.Unix()))
+
+	return b
+}
+
+func decodeSizeRecord(b []byte) SizeRecord {
+	var r SizeRecord
+	copy(r.Key[:], b)
+	r.Size = int(binary.LittleEndian.Uint64(b[SizeKeySize:]))
+	r.Added = time.Unix(int64(binary.LittleEndian.Uint64(b[SizeKeySize+8:])), 0)
+
+	return r
+}
+
+/*
+Read the records of a size cache file, in the order in which they were added.
+
+A truncated last record, left by an interrupted run, is ignored. Returns the records and the size of the valid part of
+the file.
+*/
+func ReadSizeCache(r io.Reader) ([]SizeRecord, int64, error) {
+	reader := bufio.NewReader(r)
+	magic := make([]byte, len(sizeCacheMagic))
+	if _, err := io.ReadFull(reader, magic); err != nil || string(magic) != sizeCacheMagic {
+		return nil, 0, errors.New("not a cache file of compressed sizes")
+	}
+
+	records := make([]SizeRecord, 0)
+	buf := make([]byte, sizeCacheRecordSize)
+	for {
+		_, err := io.ReadFull(reader, buf)
+		if err == io.EOF || err == io.ErrUnexpectedEOF {
+			break
+		}
+		if err != nil {
+			return nil, 0, err
+		}
+		records = append(records, decodeSizeRecord(buf))
+	}
+
+	return records, int64(len(sizeCacheMagic) + len(records)*sizeCacheRecordSize), nil
+}
+
+/*
+Persistent cache of compressed sizes, stored in a file to which new sizes are appended.
+
+Sizes are looked up by keys that identify the compressor and the data (see CachedCompressor). Several processes can
+append to the same file, as each record is written whole, but a file must not be pruned while it is in use.
+*/
+type SizeCache struct {
+	file   *os.File
+	writer *bufio.Writer
+	sizes  map[SizeKey]int
+	err    error // First error when writing to the file
+	Hits   int
+	Misses int
+}
+
+// Open a size cache file, which is created if it does not exist
+func OpenSizeCache(path string) (*SizeCache, error) {
+	file, err := os.OpenFile(path, os.O_RDWR|os.O_CREATE|os.O_APPEND, 0o644)
+	if err != nil {
+		return nil, err
+	}
+
+	info, err := file.Stat()
+	if err != nil {
+		file.Close()
+		return nil, err
+	}
+
+	sizes := make(map[SizeKey]int)
+	if info.Size() == 0 {
+		if _, err := file.WriteString(sizeCacheMagic); err != nil {
+			file.Close()
+			return nil, err
+		}
+	} else {
+		records, validSize, err := ReadSizeCache(file)
+		if err != nil {
+			file.Close()
+			return nil, fmt.Errorf("%s: %w", path, err)
+		}
+		for _, r := range records {
+			sizes[r.Key] = r.Size
+		}
+		if validSize < info.Size() {
+			if err := file.Truncate(validSize); err != nil {
+				file.Close()
+				return nil, err
+			}
+		}
+	}
+
+	// The buffer holds whole records, so that records are never split across writes
+	bufferSize := 128 * sizeCacheRecordSize
+
+	return &SizeCache{file: file, writer: bufio.NewWriterSize(file, bufferSize), sizes: sizes}, nil
+}
+
+// Number of sizes in the cache
+func (c *SizeCache) Len() int {
+	return len(c.sizes)
+}
+
+// Look up a size, counting hits and misses
+func (c *SizeCache) Get(key SizeKey) (int, bool) {
+	size, ok := c.sizes[key]
+	if ok {
+		c.Hits += 1
+	} else {
+		c.Misses += 1
+	}
+
+	return size, ok
+}
+
+// Add a size to the cache. Write errors are also returned by Close.
+func (c *SizeCache) Put(key SizeKey, size int) error {
+	c.sizes[key] = size
+	_, err := c.writer.Write(SizeRecord{key, size, time.Now()}.appendTo(nil))
+	if err != nil && c.err == nil {
+		c.err = err
+	}
+
+	return err
+}
+
+// Write the new sizes to the file and close it
+func (c *SizeCache) Close() error {
+	if err := c.writer.Flush(); err != nil && c.err == nil {
+		c.err = err
+	}
+	if err := c.file.Close(); err != nil && c.err == nil {
+		c.err = err
+	}
+
+	return c.err
+}
+
+/*
+Rewrite a size cache file with only the records that keep accepts, and without duplicate keys (keeping the latest
+record of each key). The new file replaces the old one atomically.
+
+Returns the numbers of records kept and removed.
+*/
+func PruneSizeCache(path string, keep func(r SizeRecord) bool) (int, int, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return 0, 0, err
+	}
+	records, _, err := ReadSizeCache(file)
+	file.Close()
+	if err != nil {
+		return 0, 0, fmt.Errorf("%s: %w", path, err)
+	}
+
+	latest := make(map[SizeKey]int, len(records))
+	for i, r := range records {
+		latest[r.Key] = i
+	}
+
+	var buf bytes.Buffer
+	buf.WriteString(sizeCacheMagic)
+	nbKept := 0
+	for i, r := range records {
+		if latest[r.Key] != i || !keep(r) {
+			continue
+		}
+		buf.Write(r.appendTo(nil))
+		nbKept += 1
+	}
+
+	tmp, err := os.CreateTemp(filepath.Dir(path), filepath.Base(path)+".*")
+	if err != nil {
+		return 0, 0, err
+	}
+	if _, err := tmp.Write(buf.Bytes()); err != nil {
+		tmp.Close()
+		os.Remove(tmp.Name())
+		return 0, 0, err
+	}
+	if err := tmp.Close(); err != nil {
+		os.Remove(tmp.Name())
+		return 0, 0, err
+	}
+	if err := os.Rename(tmp.Name(), path); err != nil {
+		os.Remove(tmp.Name())
+		return 0, 0, err
+	}
+
+	return nbKept, len(records) - nbKept, nil
+}
+
+/*========================================================================
+	CACHING COMPRESSOR
+········································································*/
+
+/*
+Wrapper of a compressor that looks up the compressed sizes of the data sent to it in a size cache, and only compresses
+the data that is not in the cache.
+
+The key of the data is a digest of the ID of the compressor, the reference of the compressor if it is primed, and the
+digests of the chunks sent to it since the last call to Process, in order. The ID must identify the compressor and all
+its settings, since sizes of different compressors share the cache. The chunks are kept until Process is called, and
+must not be modified before. Implements the PrimableCompressor interface, which fails when priming a compressor that
+does not implement it.
+
+Priming is deferred until a size is not found in the cache, so that a warm cache saves the priming as well as the
+compressions, and the reference must not be modified before the next call to Prime. As Process cannot fail, an error
+of a deferred priming is returned by the next call to Prime, and the sizes compressed after it are not cached.
+*/
+type CachedCompressor struct {
+	compressor ManagedCompressor
+	id         string
+	cache      *SizeCache
+	chunks     [][]byte
+	digests    []byte
+	reference  []byte // Digest of the reference
+	pending    []byte // Reference of the next priming of the compressor
+	hasPending bool   // The compressor must be primed with pending before compressing
+	primeErr   error  // Error of the last deferred priming
+}
+
+func NewCachedCompressor(compressor ManagedCompressor, id string, cache *SizeCache) *CachedCompressor {
+	return &CachedCompressor{compressor: compressor, id: id, cache: cache}
+}
+
+func (mc *CachedCompressor) Send(data []byte) (int, error) {
+	digest := sha256.Sum256(data)
+	mc.digests = append(mc.digests, digest[:]...)
+	mc.chunks = append(mc.chunks, data)
+
+	return len(data), nil
+}
+
+func (mc *CachedCompressor) key() SizeKey {
+	h := sha256.New()
+	h.Write(binary.AppendUvarint(nil, uint64(len(mc.id))))
+	h.Write([]byte(mc.id))
+	h.Write(binary.AppendUvarint(nil, uint64(len(mc.reference))))
+	h.Write(mc.reference)
+	h.Write(mc.digests)
+
+	var key SizeKey
+	copy(key[:], h.Sum(nil))
+
+	return key
+}
+
+func (mc *CachedCompressor) Process() int {
+	if len(mc.chunks) == 0 {
+		return mc.compressor.Process()
+	}
+
+	key := mc.key()
+	chunks := mc.chunks
+	mc.chunks = mc.chunks[:0]
+	mc.digests = mc.digests[:0]
+
+	if size, ok := mc.cache.Get(key); ok {
+		return size
+	}
+
+	if mc.hasPending {
+		mc.primeErr = mc.compressor.(PrimableCompressor).Prime(mc.pending)
+		mc.pending, mc.hasPending = nil, false
+	}
+	for _, chunk := range chunks {
+		mc.compressor.Send(chunk)
+	}
+	size := mc.compressor.Process()
+	if mc.primeErr == nil {
+		mc.cache.Put(key, size)
+	}
+
+	return size
+}
+
+func (mc *CachedCompressor) Prime(reference []byte) error {
+	if _, ok := mc.compressor.(PrimableCompressor); !ok {
+		return errors.New("the compressor cannot be primed")
+	}
+	if err := mc.primeErr; err != nil {
+		mc.primeErr = nil
+		return err
+	}
+
+	mc.pending, mc.hasPending = reference, true
+	mc.reference = nil
+	if reference != nil {
+		digest := sha256.Sum256(reference)
+		mc.reference = digest[:]
+	}
+
+	return nil
+}
@@ -0,0 +1,201 @@
+package ncd
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// Compressor that counts the calls to Process, and returns the number of bytes sent
+type countingCompressor struct {
+	fakeCompressor
+	nbProcessed int
+}
+
+func (fc *countingCompressor) Process() int {
+	fc.nbProcessed += 1
+	return fc.fakeCompressor.Process()
+}
+
+func TestCachedCompressor(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "sizes.cache")
+	seqs := [][]byte{[]byte("AAAA"), []byte("ACGTACGT"), []byte("AC")}
+
+	cache, err := OpenSizeCache(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	inner := &countingCompressor{}
+	mc := NewCachedCompressor(inner, "fake", cache)
+	cx := CXVector(&seqs, mc)
+	D := NCDMatrix(&seqs, &cx, mc)
+	// 3 sequences, 3 pairs, and the reset at the start of NCDMatrix, which is not cached
+	if inner.nbProcessed != 7 || cache.Hits != 0 || cache.Misses != 6 {
+		t.Errorf("first run: %d compressions, %d hits, %d misses", inner.nbProcessed, cache.Hits, cache.Misses)
+	}
+	if err := cache.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	// A second run finds all the sizes in the file
+	cache, err = OpenSizeCache(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cache.Len() != 6 {
+		t.Errorf("%d sizes read back, want 6", cache.Len())
+	}
+	inner = &countingCompressor{}
+	mc = NewCachedCompressor(inner, "fake", cache)
+	cx2 := CXVector(&seqs, mc)
+	D2 := NCDMatrix(&seqs, &cx2, mc)
+	if inner.nbProcessed != 1 || cache.Hits != 6 || cache.Misses != 0 {
+		t.Errorf("second run: %d compressions, %d hits, %d misses", inner.nbProcessed, cache.Hits, cache.Misses)
+	}
+	for i, v := range D.RawData {
+		if D2.RawData[i] != v {
+			t.Errorf("cached distance %d = %v, want %v", i, D2.RawData[i], v)
+		}
+	}
+
+	// Another compressor ID, or a primed compressor, does not share the sizes
+	mc = NewCachedCompressor(inner, "other", cache)
+	CXVector(&seqs, mc)
+	primable := &fakePrimableCompressor{}
+	mc = NewCachedCompressor(primable, "fake", cache)
+	mc.Prime(seqs[0])
+	CXVector(&seqs, mc)
+	if cache.Misses != 6 {
+		t.Errorf("%d misses with other settings, want 6", cache.Misses)
+	}
+	if err := NewCachedCompressor(inner, "fake", cache).Prime(seqs[0]); err == nil {
+		t.Error("expected an error when priming a compressor that cannot be primed")
+	}
+	cache.Close()
+}
+
+func TestSizeCacheTruncated(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "sizes.cache")
+	cache, _ := OpenSizeCache(path)
+	cache.Put(SizeKey{1}, 10)
+	cache.Put(SizeKey{2}, 20)
+	cache.Close()
+
+	// Simulate a record cut short by an interrupted run
+	info, _ := os.Stat(path)
+	os.Truncate(path, info.Size()-5)
+
+	cache, err := OpenSizeCache(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if size, ok := cache.Get(SizeKey{1}); !ok || size != 10 || cache.Len() != 1 {
+		t.Errorf("got size %d (%v) and %d records", size, ok, cache.Len())
+	}
+	cache.Put(SizeKey{3}, 30)
+	cache.Close()
+
+	cache, _ = OpenSizeCache(path)
+	if size, ok := cache.Get(SizeKey{3}); !ok || size != 30 || cache.Len() != 2 {
+		t.Errorf("got size %d (%v) and %d records after appending", size, ok, cache.Len())
+	}
+	cache.Close()
+
+	os.WriteFile(path, []byte("something else"), 0o644)
+	if _, err := OpenSizeCache(path); err == nil {
+		t.Error("expected an error for a file that is not a cache")
+	}
+}
+
+func TestPruneSizeCache(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "sizes.cache")
+	cache, _ := OpenSizeCache(path)
+	cache.Put(SizeKey{1}, 10)
+	cache.Put(SizeKey{2}, 20)
+	cache.Put(SizeKey{1}, 11)
+	cache.Put(SizeKey{3}, 30)
+	cache.Close()
+
+	kept, removed, err := PruneSizeCache(path, func(r SizeRecord) bool {
+		return r.Key != SizeKey{3} && time.Since(r.Added) < time.Hour
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if kept != 2 || removed != 2 {
+		t.Errorf("kept %d and removed %d records, want 2 and 2", kept, removed)
+	}
+
+	f, _ := os.Open(path)
+	records, _, err := ReadSizeCache(f)
+	f.Close()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(records) != 2 || records[0].Key != (SizeKey{2}) || records[1].Size != 11 {
+		t.Errorf("unexpected records after pruning: %v", records)
+	}
+}
+
+// Primable compressor that counts the primings, and fails them if err is set
+type countingPrimableCompressor struct {
+	fakePrimableCompressor
+	nbPrimed int
+	err      error
+}
+
+func (fc *countingPrimableCompressor) Prime(reference []byte) error {
+	fc.nbPrimed += 1
+	if fc.err != nil {
+		return fc.err
+	}
+	return fc.fakePrimableCompressor.Prime(reference)
+}
+
+func TestCachedCompressorDeferredPriming(t *testing.T) {
+	seqs := SliceSource{[]byte("AAAA"), []byte("ACGTACGT"), []byte("AC")}
+	cache, err := OpenSizeCache(filepath.Join(t.TempDir(), "sizes.cache"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer cache.Close()
+	cx := []float64{4, 8, 2}
+
+	inner := &countingPrimableCompressor{}
+	D, err := ConditionalNCDMatrixFrom(seqs, &cx, NewCachedCompressor(inner, "fake", cache), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if inner.nbPrimed != 3 {
+		t.Errorf("cold cache: %d primings, want 3", inner.nbPrimed)
+	}
+
+	// With all the sizes in the cache, the compressor is never primed
+	inner = &countingPrimableCompressor{}
+	nbMisses := cache.Misses
+	D2, err := ConditionalNCDMatrixFrom(seqs, &cx, NewCachedCompressor(inner, "fake", cache), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if inner.nbPrimed != 0 || cache.Misses != nbMisses {
+		t.Errorf("warm cache: %d primings and %d misses, want none", inner.nbPrimed, cache.Misses-nbMisses)
+	}
+	for i, v := range D.RawData {
+		if D2.RawData[i] != v {
+			t.Errorf("cached distance %d = %v, want %v", i, D2.RawData[i], v)
+		}
+	}
+
+	// A failed priming is reported by the next call to Prime, and the sizes compressed after it are not cached
+	inner = &countingPrimableCompressor{err: errors.New("priming failed")}
+	mc := NewCachedCompressor(inner, "failing", cache)
+	nbSizes := cache.Len()
+	if _, err := ConditionalNCDMatrixFrom(seqs, &cx, mc, nil); err == nil || err.Error() != "priming failed" {
+		t.Errorf("expected the priming error, got %v", err)
+	}
+	if cache.Len() != nbSizes {
+		t.Errorf("%d sizes cached after a failed priming", cache.Len()-nbSizes)
+	}
+}
@@ -0,0 +1,105 @@
+package ncd
+
+import (
+	"container/list"
+)
+
+/*
+Provider of the sequences of the taxa, which may fetch them on demand rather than hold them all in memory.
+
+Sequences returned by a source must not be modified.
+*/
+type SequenceSource interface {
+	// Number of sequences
+	Len() int
+
+	// Returns the i-th sequence
+	Sequence(i int) ([]byte, error)
+}
+
+// Sequence source backed by sequences in memory
+type SliceSource [][]byte
+
+func (s SliceSource) Len() int {
+	return len(s)
+}
+
+func (s SliceSource) Sequence(i int) ([]byte, error) {
+	return s[i], nil
+}
+
+/*========================================================================
+	CACHE
+········································································*/
+
+type cacheEntry struct {
+	index int
+	seq   []byte
+}
+
+/*
+Wrapper of a sequence source that keeps the most recently used sequences in memory, up to a total size.
+
+Sequences larger than the whole cache are never kept.
+*/
+type CachedSource struct {
+	source   SequenceSource
+	maxBytes int
+	nBytes   int
+	recency  *list.List            // Cache entries, the most recently used first
+	elements map[int]*list.Element // Sequence index -> element of recency
+	Hits     int
+	Misses   int
+}
+
+func NewCachedSource(source SequenceSource, maxBytes int) *CachedSource {
+	return &CachedSource{
+		source:   source,
+		maxBytes: maxBytes,
+		recency:  list.New(),
+		elements: make(map[int]*list.Element),
+	}
+}
+
+func (c *CachedSource) Len() int {
+	return c.source.Len()
+}
+
+func (c *CachedSource) Sequence(i int) ([]byte, error) {
+	if element, ok := c.elements[i]; ok {
+		c.Hits += 1
+		c.recency.MoveToFront(element)
+		return element.Value.(*cacheEntry).seq, nil
+	}
+
+	c.Misses += 1
+	seq, err := c.source.Sequence(i)
+	if err != nil || len(seq) > c.maxBytes {
+		return seq, err
+	}
+
+	for c.nBytes+len(seq) > c.maxBytes {
+		oldest := c.recency.Back()
+		entry := c.recency.Remove(oldest).(*cacheEntry)
+		delete(c.elements, entry.index)
+		c.nBytes -= len(entry.seq)
+	}
+	c.elements[i] = c.recency.PushFront(&cacheEntry{i, seq})
+	c.nBytes += len(seq)
+
+	return seq, nil
+}
+
+// Sequence source made of some of the sequences of another source, in the order of their indices
+type SubsetSource struct {
+	Source  SequenceSource
+	Indices []int
+}
+
+func (s SubsetSource) Len() int {
+	return len(s.Indices)
+}
+
+func (s SubsetSource) Sequence(i int) ([]byte, error) {
+	return s.Source.Sequence(s.Indices[i])
+}
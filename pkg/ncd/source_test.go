@@ -0,0 +1,97 @@
+package ncd
+
+import (
+	"errors"
+	"testing"
+)
+
+// Source that counts the sequences fetched from it, and fails on a given index
+type countingSource struct {
+	seqs    [][]byte
+	fetched int
+	failAt  int
+}
+
+func (s *countingSource) Len() int {
+	return len(s.seqs)
+}
+
+func (s *countingSource) Sequence(i int) ([]byte, error) {
+	if i == s.failAt {
+		return nil, errors.New("unreadable sequence")
+	}
+	s.fetched += 1
+	return s.seqs[i], nil
+}
+
+func TestCachedSource(t *testing.T) {
+	source := &countingSource{[][]byte{[]byte("AA"), []byte("CCC"), []byte("GGGG"), []byte("TTTTTTTTTT")}, 0, -1}
+	cache := NewCachedSource(source, 7)
+
+	accesses := []struct {
+		index int
+		hit   bool
+	}{
+		{0, false},
+		{1, false},
+		{0, true},
+		{2, false}, // Evicts 1, the least recently used
+		{0, true},
+		{1, false}, // Evicts 2
+		{3, false}, // Larger than the cache, not kept
+		{3, false},
+		{0, true},
+		{1, true},
+	}
+
+	for k, a := range accesses {
+		hits := cache.Hits
+		seq, err := cache.Sequence(a.index)
+		if err != nil || string(seq) != string(source.seqs[a.index]) {
+			t.Fatalf("access %d: got %q, %v, want %q", k, seq, err, source.seqs[a.index])
+		}
+		if (cache.Hits > hits) != a.hit {
+			t.Errorf("access %d to sequence %d: hit = %v, want %v", k, a.index, cache.Hits > hits, a.hit)
+		}
+	}
+	if cache.Misses != source.fetched {
+		t.Errorf("got %d misses for %d fetched sequences", cache.Misses, source.fetched)
+	}
+}
+
+func TestNCDMatrixFrom(t *testing.T) {
+	seqs := [][]byte{[]byte("A"), []byte("AB"), []byte("ABC"), []byte("ABCD"), []byte("AABBAABB")}
+	cx := CXVector(&seqs, &fakeCompressor{})
+	want := NCDMatrix(&seqs, &cx, &fakeCompressor{})
+
+	// A cache holding two sequences is enough to avoid fetching the start of each row again
+	source := &countingSource{seqs, 0, -1}
+	cache := NewCachedSource(source, 12)
+	got, err := NCDMatrixFrom(cache, &cx, &fakeCompressor{}, nil)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	for i := range len(seqs) {
+		for j := range i {
+			if got.Get(i, j) != want.Get(i, j) {
+				t.Errorf("Get(%d,%d) = %v, want %v", i, j, got.Get(i, j), want.Get(i, j))
+			}
+		}
+	}
+	if cache.Hits == 0 {
+		t.Errorf("no cache hits in %d accesses", cache.Misses)
+	}
+
+	for _, f := range []func() error{
+		func() error { _, err := CXVectorFrom(&countingSource{seqs, 0, 2}, &fakeCompressor{}); return err },
+		func() error { _, err := CXXVectorFrom(&countingSource{seqs, 0, 2}, &fakeCompressor{}); return err },
+		func() error {
+			_, err := NCDMatrixFrom(&countingSource{seqs, 0, 2}, &cx, &fakeCompressor{}, nil)
+			return err
+		},
+	} {
+		if f() == nil {
+			t.Errorf("expected error from failing source, got nil")
+		}
+	}
+}
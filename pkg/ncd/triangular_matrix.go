@@ -114,6 +114,37 @@ func (m *TriangularMatrix) ArgMin() (int, int) {
 	return min_i, min_j
 }
 
+/*
+Returns the column of the smallest value in row i among the active series, and that value. Returns (-1, NaN) if there
+is no other active series.
+*/
+func (m *TriangularMatrix) RowMin(i int) (int, float64) {
+	jMin := -1
+	vMin := math.NaN()
+	for j, v := range m.Sequence(i) {
+		if jMin < 0 || v < vMin {
+			jMin, vMin = j, v
+		}
+	}
+
+	return jMin, vMin
+}
+
+// Returns the mean of the values in row i among the active series, or NaN if there is no other active series
+func (m *TriangularMatrix) RowMean(i int) float64 {
+	sum := 0.0
+	n := 0
+	for _, v := range m.Sequence(i) {
+		sum += v
+		n += 1
+	}
+	if n == 0 {
+		return math.NaN()
+	}
+
+	return sum / float64(n)
+}
+
 func (m *TriangularMatrix) Show() {
 	for i := range m.N {
 		fmt.Printf("%d", i)
@@ -1,6 +1,7 @@
 package ncd
 
 import (
+	"math"
 	"reflect"
 	"testing"
 )
@@ -83,3 +84,36 @@ func TestArgMin(t *testing.T) {
 		t.Errorf("ArgMin: got (%d,%d)=%v, not minimum", i, j, m.Get(i, j))
 	}
 }
+
+func TestRowMinMean(t *testing.T) {
+	m := NewTriangularMatrix(4)
+	m.Set(1, 0, 0.5)
+	m.Set(2, 0, 0.2)
+	m.Set(3, 0, 0.8)
+	m.Set(2, 1, 0.3)
+	m.Set(3, 1, 0.1)
+	m.Set(3, 2, 0.4)
+
+	if j, v := m.RowMin(0); j != 2 || v != 0.2 {
+		t.Errorf("RowMin(0) = (%d, %v), want (2, 0.2)", j, v)
+	}
+	if j, v := m.RowMin(1); j != 3 || v != 0.1 {
+		t.Errorf("RowMin(1) = (%d, %v), want (3, 0.1)", j, v)
+	}
+	if mean := m.RowMean(0); math.Abs(mean-0.5) > 1e-12 {
+		t.Errorf("RowMean(0) = %v, want 0.5", mean)
+	}
+
+	m.Active[3] = false
+	if j, v := m.RowMin(1); j != 2 || v != 0.3 {
+		t.Errorf("RowMin(1) with an inactive series = (%d, %v), want (2, 0.3)", j, v)
+	}
+
+	single := NewTriangularMatrix(1)
+	if j, v := single.RowMin(0); j != -1 || !math.IsNaN(v) {
+		t.Errorf("RowMin of a 1x1 matrix = (%d, %v), want (-1, NaN)", j, v)
+	}
+	if mean := single.RowMean(0); !math.IsNaN(mean) {
+		t.Errorf("RowMean of a 1x1 matrix = %v, want NaN", mean)
+	}
+}
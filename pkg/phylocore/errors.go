@@ -0,0 +1,35 @@
+package phylocore
+
+import (
+	"errors"
+	"fmt"
+)
+
+var (
+	// A taxon name is used more than once in a taxon set
+	ErrDuplicateName = errors.New("duplicate taxon name")
+
+	// An operation needs more taxa than it was given
+	ErrTooFewTaxa = errors.New("too few taxa")
+
+	// A taxon that an operation refers to is not in the tree
+	ErrTaxonNotInTree = errors.New("taxon not in the tree")
+
+	// A distance matrix does not match the taxon set
+	ErrMatrixSize = errors.New("size of the distance matrix does not match the number of taxa")
+)
+
+// Error in the syntax of a Newick string or of a distance matrix file
+type SyntaxError struct {
+	Format string // "Newick" or "distance matrix"
+	Line   int    // Line number, or 0 if unknown
+	Msg    string
+}
+
+func (e *SyntaxError) Error() string {
+	if e.Line > 0 {
+		return fmt.Sprintf("%s, line %d: %s", e.Format, e.Line, e.Msg)
+	}
+
+	return e.Format + ": " + e.Msg
+}
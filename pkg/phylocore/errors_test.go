@@ -0,0 +1,91 @@
+package phylocore
+
+import (
+	"bufio"
+	"errors"
+	"ncdtree/pkg/ncd"
+	"strings"
+	"testing"
+)
+
+func TestTooFewTaxa(t *testing.T) {
+	for _, n := range []int{0, 1} {
+		taxa := makeTaxonSet(n)
+		if _, err := MakeBalancedTree(taxa); !errors.Is(err, ErrTooFewTaxa) {
+			t.Errorf("MakeBalancedTree(%d): got error %v, want ErrTooFewTaxa", n, err)
+		}
+		if _, err := MakeStarTree(taxa); !errors.Is(err, ErrTooFewTaxa) {
+			t.Errorf("MakeStarTree(%d): got error %v, want ErrTooFewTaxa", n, err)
+		}
+		if _, err := NeighbourJoining(taxa, ncd.NewTriangularMatrix(n)); !errors.Is(err, ErrTooFewTaxa) {
+			t.Errorf("NeighbourJoining(%d): got error %v, want ErrTooFewTaxa", n, err)
+		}
+	}
+
+	if _, err := NeighbourJoining(makeTaxonSet(3), ncd.NewTriangularMatrix(4)); !errors.Is(err, ErrMatrixSize) {
+		t.Errorf("NeighbourJoining with a larger matrix: got error %v, want ErrMatrixSize", err)
+	}
+}
+
+func TestNeighbourJoiningTwoTaxa(t *testing.T) {
+	taxa, _ := NewTaxonSet([]string{"A", "B"})
+	D := ncd.NewTriangularMatrix(2)
+	D.Set(1, 0, 0.5)
+
+	tree, err := NeighbourJoining(taxa, D)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := tree.NewickString(); got != "(A:0.25,B:0.25);" {
+		t.Errorf("got tree %s, want (A:0.25,B:0.25);", got)
+	}
+}
+
+func TestDuplicateName(t *testing.T) {
+	if _, err := NewTaxonSet([]string{"A", "B", "A"}); !errors.Is(err, ErrDuplicateName) {
+		t.Errorf("NewTaxonSet: got error %v, want ErrDuplicateName", err)
+	}
+
+	taxa, _ := NewTaxonSet([]string{"A"})
+	if id, err := taxa.NewTaxon("B"); err != nil || id != 1 {
+		t.Errorf("NewTaxon(B) = %d, %v", id, err)
+	}
+	if _, err := taxa.NewTaxon("A"); !errors.Is(err, ErrDuplicateName) {
+		t.Errorf("NewTaxon(A): got error %v, want ErrDuplicateName", err)
+	}
+	if taxa.Len() != 2 {
+		t.Errorf("%d taxa after a failed NewTaxon, want 2", taxa.Len())
+	}
+}
+
+func TestReadDistanceMatrixErrors(t *testing.T) {
+	tests := []struct {
+		input string
+		line  int // 0 if not a syntax error
+	}{
+		{"A\nB 1\nA 2 3\n", 0},
+		{"A\nB 1\nC 2\n", 3},
+		{"3\nA\nB x\nC 1 2\n", 3},
+	}
+
+	for _, tt := range tests {
+		_, _, err := ReadDistanceMatrix(bufio.NewScanner(strings.NewReader(tt.input)))
+		var syntaxErr *SyntaxError
+		switch {
+		case tt.line == 0 && !errors.Is(err, ErrDuplicateName):
+			t.Errorf("%q: got error %v, want ErrDuplicateName", tt.input, err)
+		case tt.line > 0 && (!errors.As(err, &syntaxErr) || syntaxErr.Line != tt.line):
+			t.Errorf("%q: got error %v, want a syntax error in line %d", tt.input, err, tt.line)
+		}
+	}
+}
+
+func TestReadNewickSyntaxError(t *testing.T) {
+	for _, input := range []string{"((A,B);", "(A,B)", "(A:xyz,B);", "('A,B);"} {
+		_, _, err := readNewickString(input)
+		var syntaxErr *SyntaxError
+		if !errors.As(err, &syntaxErr) {
+			t.Errorf("%q: got error %v, want a *SyntaxError", input, err)
+		}
+	}
+}
@@ -1,22 +1,26 @@
 package phylocore
 
+import (
+	"fmt"
+)
+
 /*
 Create a tree that is as balanced as possible for a given number of outer nodes (greater than 1).
 
 Node IDs are assigned in PHYLIP order.
 
 # Parameters
-  - nbOuter: The number of outer nodes for the tree. Must be greater than 1.
+  - taxa: The taxa of the outer nodes. There must be at least 2 (ErrTooFewTaxa otherwise).
 */
-func MakeBalancedTree(taxa *TaxonSet) *Tree {
-	tree := taxa.MakeUnassembledTree()
+func MakeBalancedTree(taxa *TaxonSet) (*Tree, error) {
 	nbOuter := taxa.Len()
 	if nbOuter < 2 {
-		panic("cannot make a balanced tree with less than 2 taxa")
+		return nil, fmt.Errorf("%w: cannot make a balanced tree with %d taxa", ErrTooFewTaxa, nbOuter)
 	}
+	tree := taxa.MakeUnassembledTree()
 	bifurcate(tree, tree.Root, nbOuter, nbOuter+1, 0)
 
-	return tree
+	return tree, nil
 }
 
 func bifurcate(tree *Tree, node *Node, nbOuter int, nextIdInner int, nextIdOuter int) (int, int) {
@@ -62,19 +66,19 @@ Create a star tree, i.e. a tree with only one inner node (the root).
 Node IDs are assigned in PHYLIP order.
 
 # Parameters
-  - nbOuter: The number of outer nodes for the tree. Must be greater than 1.
+  - taxa: The taxa of the outer nodes. There must be at least 2 (ErrTooFewTaxa otherwise).
 */
-func MakeStarTree(taxa *TaxonSet) *Tree {
-	tree := taxa.MakeUnassembledTree()
+func MakeStarTree(taxa *TaxonSet) (*Tree, error) {
 	nbOuter := taxa.Len()
 	if nbOuter < 2 {
-		panic("cannot make a star tree with less than 2 taxa")
+		return nil, fmt.Errorf("%w: cannot make a star tree with %d taxa", ErrTooFewTaxa, nbOuter)
 	}
+	tree := taxa.MakeUnassembledTree()
 
 	for i := range nbOuter {
 		outerNode := tree.Nodes[i]
 		tree.Root.AddChild(outerNode, tree.NewBranch())
 	}
 
-	return tree
+	return tree, nil
 }
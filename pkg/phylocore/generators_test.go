@@ -75,7 +75,10 @@ func TestMakeBalancedTree(t *testing.T) {
 	}
 	for _, tt := range tests {
 		taxa := makeTaxonSet(tt.nbOuter)
-		tree := MakeBalancedTree(taxa)
+		tree, err := MakeBalancedTree(taxa)
+		if err != nil {
+			t.Fatalf("MakeBalancedTree(%d): %v", tt.nbOuter, err)
+		}
 
 		isBalanced, _ := checkApproximateNodeBalance(tree.Root)
 
@@ -95,7 +98,10 @@ func TestMakeStarTree(t *testing.T) {
 	}
 	for _, tt := range tests {
 		taxa := makeTaxonSet(tt.nbOuter)
-		tree := MakeStarTree(taxa)
+		tree, err := MakeStarTree(taxa)
+		if err != nil {
+			t.Fatalf("MakeStarTree(%d): %v", tt.nbOuter, err)
+		}
 		root := tree.Root
 		if root == nil {
 			t.Errorf("MakeStarTree(%d): root is nil", tt.nbOuter)
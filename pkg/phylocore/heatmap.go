@@ -0,0 +1,204 @@
+package phylocore
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"ncdtree/pkg/ncd"
+	"strings"
+)
+
+/*========================================================================
+	HEATMAPS OF DISTANCE MATRICES
+········································································*/
+
+// Options of the drawing of a distance matrix with WriteHeatmapSVG
+type HeatmapOptions struct {
+	CellSize  float64 // Side of the cells in pixels
+	FontSize  float64 // Size of the taxon names in pixels
+	Cladogram bool    // Ignore the branch lengths of the dendrogram and align its tips
+	Ladderize bool    // Order the children of each node from the smallest to the largest clade
+}
+
+const (
+	DefaultHeatmapCellSize = 12.0
+	DefaultHeatmapFontSize = 10.0
+
+	heatmapDendrogramCells = 12  // Width of the dendrogram, in cells
+	heatmapLegendWidth     = 15  // Width of the colour bar of the legend in pixels
+	heatmapMaxLegendHeight = 200 // Height of the colour bar of the legend in pixels, if the matrix is high enough
+)
+
+// Colours of the scale of the heatmaps (viridis), from the smallest to the largest distances
+var heatmapColours = [][3]float64{
+	{68, 1, 84},
+	{59, 82, 139},
+	{33, 145, 140},
+	{94, 201, 98},
+	{253, 231, 37},
+}
+
+// Colour of the cells without a distance: the diagonal and missing values
+const heatmapEmptyColour = "#d9d9d9"
+
+// Return the colour of the scale at a fraction between 0 and 1, interpolated between heatmapColours
+func heatmapColour(fraction float64) string {
+	fraction = min(max(fraction, 0.0), 1.0)
+	position := fraction * float64(len(heatmapColours)-1)
+	k := min(int(position), len(heatmapColours)-2)
+	t := position - float64(k)
+	rgb := make([]int, 3)
+	for c := range rgb {
+		rgb[c] = int(math.Round(heatmapColours[k][c]*(1-t) + heatmapColours[k+1][c]*t))
+	}
+
+	return fmt.Sprintf("#%02x%02x%02x", rgb[0], rgb[1], rgb[2])
+}
+
+/*
+Draw a distance matrix as an SVG heatmap, with the rows and columns in the order of the tips of the tree, and the tree
+drawn as a dendrogram on the left of the rows.
+
+The tips of the tree are the taxa of the matrix, whose rows are their taxon IDs, and the taxon set gives their names.
+The colours go from the smallest to the largest distance of the matrix, as shown by a legend on the right. Fails with
+ErrMatrixSize if the tips are not the taxa of the matrix, or if the taxon set has no name for one of them.
+*/
+func (tree *Tree) WriteHeatmapSVG(w io.Writer, taxset *TaxonSet, D *ncd.TriangularMatrix, opts HeatmapOptions) error {
+	if tree.Root == nil {
+		return errors.New("the tree has no root")
+	}
+	cellSize := opts.CellSize
+	if cellSize <= 0 {
+		cellSize = DefaultHeatmapCellSize
+	}
+	fontSize := opts.FontSize
+	if fontSize <= 0 {
+		fontSize = DefaultHeatmapFontSize
+	}
+
+	plot := newTreePlot(tree, opts.Cladogram, opts.Ladderize)
+	n := len(plot.tips)
+	if n != D.N {
+		return fmt.Errorf("%w (%d rows for %d tips)", ErrMatrixSize, D.N, n)
+	}
+	rows := make([]int, n) // Row of the matrix of each tip, in drawing order
+	seen := make([]bool, n)
+	for i, tip := range plot.tips {
+		if tip.TaxonId < 0 || tip.TaxonId >= n || seen[tip.TaxonId] {
+			return fmt.Errorf("%w: the tips are not the taxa of the matrix", ErrMatrixSize)
+		}
+		seen[tip.TaxonId] = true
+		rows[i] = tip.TaxonId
+	}
+	names := make([]string, n)
+	for i, id := range rows {
+		name, ok := taxset.GetName(id)
+		if !ok {
+			return fmt.Errorf("%w: no name for taxon %d", ErrMatrixSize, id)
+		}
+		names[i] = name
+	}
+
+	minValue, maxValue := math.Inf(1), math.Inf(-1)
+	for _, v := range D.RawData {
+		if !math.IsNaN(v) {
+			minValue = min(minValue, v)
+			maxValue = max(maxValue, v)
+		}
+	}
+	if minValue > maxValue {
+		minValue, maxValue = 0.0, 0.0
+	}
+	fraction := func(v float64) float64 {
+		if maxValue == minValue {
+			return 0.5
+		}
+		return (v - minValue) / (maxValue - minValue)
+	}
+
+	labelWidth := 0.0
+	for _, name := range names {
+		labelWidth = max(labelWidth, svgTextWidth(name, fontSize))
+	}
+	labelWidth += fontSize / 2
+	legendLabels := []string{formatTextPlotValue(minValue), formatTextPlotValue((minValue + maxValue) / 2), formatTextPlotValue(maxValue)}
+	legendLabelWidth := 0.0
+	for _, s := range legendLabels {
+		legendLabelWidth = max(legendLabelWidth, svgTextWidth(s, fontSize))
+	}
+
+	margin := fontSize
+	top := margin
+	dendrogramWidth := heatmapDendrogramCells * cellSize
+	matrixLeft := margin + dendrogramWidth + cellSize/2
+	matrixSize := float64(n) * cellSize
+	legendLeft := matrixLeft + matrixSize + labelWidth + fontSize
+	legendHeight := max(min(matrixSize, heatmapMaxLegendHeight), 3*fontSize)
+	width := legendLeft + heatmapLegendWidth + fontSize/2 + legendLabelWidth + margin
+	height := top + max(matrixSize+labelWidth, legendHeight) + margin
+
+	scale := 0.0
+	if plot.maxDepth > 0 {
+		scale = dendrogramWidth / plot.maxDepth
+	}
+	x := func(node *Node) float64 { return margin + plot.depth[node]*scale }
+	y := func(slot float64) float64 { return top + (slot+0.5)*cellSize }
+
+	sw := &svgWriter{w: w}
+	writeSVGHeader(sw, width, height, fontSize)
+	sw.printf("<defs><linearGradient id=\"scale\" x1=\"0\" y1=\"1\" x2=\"0\" y2=\"0\">\n")
+	for k := range heatmapColours {
+		f := float64(k) / float64(len(heatmapColours)-1)
+		sw.printf("<stop offset=\"%s\" stop-color=\"%s\"/>\n", svgNum(f), heatmapColour(f))
+	}
+	sw.printf("</linearGradient></defs>\n")
+
+	// Dendrogram, with the tips joined to their rows by dotted lines
+	var d strings.Builder
+	for _, node := range plot.inner {
+		children := plot.children[node]
+		fmt.Fprintf(&d, "M %s %s V %s ", svgNum(x(node)), svgNum(y(plot.slot[children[0].Child])), svgNum(y(plot.slot[children[len(children)-1].Child])))
+		for _, branch := range children {
+			fmt.Fprintf(&d, "M %s %s H %s ", svgNum(x(node)), svgNum(y(plot.slot[branch.Child])), svgNum(x(branch.Child)))
+		}
+	}
+	sw.printf("<path d=\"%s\" fill=\"none\" stroke=\"black\" stroke-width=\"1.5\" stroke-linecap=\"square\"/>\n", strings.TrimSpace(d.String()))
+	for i, tip := range plot.tips {
+		if matrixLeft-x(tip) > cellSize/2+1 {
+			sw.printf("<path d=\"M %s %s H %s\" stroke=\"gray\" stroke-dasharray=\"2 2\"/>\n", svgNum(x(tip)+2), svgNum(y(float64(i))), svgNum(matrixLeft-2))
+		}
+	}
+
+	// Cells, row by row
+	for i := range n {
+		for j := range n {
+			colour := heatmapEmptyColour
+			if i != j && !math.IsNaN(D.Get(rows[i], rows[j])) {
+				colour = heatmapColour(fraction(D.Get(rows[i], rows[j])))
+			}
+			sw.printf("<rect x=\"%s\" y=\"%s\" width=\"%s\" height=\"%s\" fill=\"%s\"/>\n",
+				svgNum(matrixLeft+float64(j)*cellSize), svgNum(top+float64(i)*cellSize), svgNum(cellSize), svgNum(cellSize), colour)
+		}
+	}
+
+	// Names on the right of the rows, and below the columns
+	for i, name := range names {
+		sw.text(matrixLeft+matrixSize+fontSize/3, y(float64(i)), name, "")
+		cx, cy := matrixLeft+(float64(i)+0.5)*cellSize, top+matrixSize+fontSize/3
+		sw.text(cx, cy, name, fmt.Sprintf(" transform=\"rotate(90 %s %s)\"", svgNum(cx), svgNum(cy)))
+	}
+
+	// Legend: the colour bar, with the smallest, middle and largest distances
+	sw.printf("<rect x=\"%s\" y=\"%s\" width=\"%d\" height=\"%s\" fill=\"url(#scale)\" stroke=\"black\" stroke-width=\"0.5\"/>\n",
+		svgNum(legendLeft), svgNum(top), heatmapLegendWidth, svgNum(legendHeight))
+	for k, s := range legendLabels {
+		ly := top + legendHeight*(1-float64(k)/2)
+		sw.printf("<path d=\"M %s %s h 3\" stroke=\"black\"/>\n", svgNum(legendLeft+heatmapLegendWidth), svgNum(ly))
+		sw.text(legendLeft+heatmapLegendWidth+fontSize/2, ly, s, "")
+	}
+
+	sw.printf("</svg>\n")
+
+	return sw.err
+}
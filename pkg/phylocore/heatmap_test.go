@@ -0,0 +1,90 @@
+package phylocore
+
+import (
+	"errors"
+	"io"
+	"ncdtree/pkg/ncd"
+	"regexp"
+	"strings"
+	"testing"
+)
+
+func TestHeatmapColour(t *testing.T) {
+	tests := []struct {
+		fraction float64
+		want     string
+	}{
+		{0.0, "#440154"},
+		{-1.0, "#440154"},
+		{0.5, "#21918c"},
+		{1.0, "#fde725"},
+		{2.0, "#fde725"},
+		{0.125, "#402a70"},
+	}
+	for _, tt := range tests {
+		if got := heatmapColour(tt.fraction); got != tt.want {
+			t.Errorf("heatmapColour(%v) = %s, want %s", tt.fraction, got, tt.want)
+		}
+	}
+}
+
+func TestWriteHeatmapSVG(t *testing.T) {
+	tree, taxset, _ := readNewickString(textPlotNewick)
+	D, err := tree.PatristicMatrix()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var b strings.Builder
+	if err := tree.WriteHeatmapSVG(&b, taxset, D, HeatmapOptions{Ladderize: true}); err != nil {
+		t.Fatal(err)
+	}
+	svg := b.String()
+	counts := countSVGElements(t, svg)
+
+	// The background, 6 × 6 cells and the colour bar; the row and column names and 3 legend values
+	if counts["rect"] != 38 || counts["text"] != 15 {
+		t.Errorf("%d rect and %d text elements, want 38 and 15", counts["rect"], counts["text"])
+	}
+
+	// The rows follow the ladderized tree, whose first tip is e, and the largest distance (b-d) is yellow
+	names := regexp.MustCompile(`<text [^>]*>([^<]*)</text>`).FindAllStringSubmatch(svg, -1)
+	if names[0][1] != "e" {
+		t.Errorf("first row %s, want e", names[0][1])
+	}
+	if strings.Count(svg, `fill="#fde725"`) != 2 {
+		t.Errorf("expected 2 cells with the largest distance")
+	}
+
+	if err := tree.WriteHeatmapSVG(io.Discard, taxset, ncd.NewTriangularMatrix(5), HeatmapOptions{}); !errors.Is(err, ErrMatrixSize) {
+		t.Errorf("expected ErrMatrixSize for a matrix of another size, got %v", err)
+	}
+}
+
+func TestWriteHeatmapSVG_TwoTaxa(t *testing.T) {
+	taxset, _ := NewTaxonSet([]string{"a", "b"})
+	D := ncd.NewTriangularMatrix(2)
+	D.Set(1, 0, 0.5)
+	tree, err := NeighbourJoining(taxset, D.Copy())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var b strings.Builder
+	if err := tree.WriteHeatmapSVG(&b, taxset, D, HeatmapOptions{}); err != nil {
+		t.Fatal(err)
+	}
+	// The background, 2 × 2 cells and the colour bar
+	if counts := countSVGElements(t, b.String()); counts["rect"] != 6 {
+		t.Errorf("%d rect elements, want 6", counts["rect"])
+	}
+}
+
+func TestWriteHeatmapSVG_MissingName(t *testing.T) {
+	tree, taxset, _ := readNewickString("((a:1,b:1):1,c:1);")
+	D, _ := tree.PatristicMatrix()
+	fewer, _ := NewTaxonSet(taxset.Names[:2])
+	if err := tree.WriteHeatmapSVG(io.Discard, fewer, D, HeatmapOptions{}); !errors.Is(err, ErrMatrixSize) {
+		t.Errorf("expected ErrMatrixSize for a taxon without a name, got %v", err)
+	}
+}
@@ -8,25 +8,46 @@ import (
 	"strings"
 )
 
+/*
+Read a distance matrix with taxon names in the first column.
+
+Only the lower triangle of the matrix is read, so the diagonal and the upper triangle can be omitted. A leading line
+with only the number of taxa, as in the PHYLIP format, is skipped.
+*/
 func ReadDistanceMatrix(scanner *bufio.Scanner) (*TaxonSet, *ncd.TriangularMatrix, error) {
 	taxonNames := make([]string, 0)
 	data := make([]float64, 0)
 
 	i := 0
+	lineNo := 0
+	isFirstLine := true
 	for scanner.Scan() {
+		lineNo += 1
 		line := strings.TrimSpace(scanner.Text())
 		if line == "" {
 			continue
 		}
 
 		fields := strings.Fields(line)
+
+		if isFirstLine {
+			isFirstLine = false
+			if _, err := strconv.Atoi(line); err == nil && len(fields) == 1 {
+				continue
+			}
+		}
 		taxonName := fields[0]
 		taxonNames = append(taxonNames, taxonName)
 
+		if len(fields) < i+1 {
+			msg := fmt.Sprintf("expected %d distances for taxon \"%s\", found %d", i, taxonName, len(fields)-1)
+			return nil, nil, &SyntaxError{"distance matrix", lineNo, msg}
+		}
 		for j := 0; j < i; j += 1 {
 			value, err := strconv.ParseFloat(fields[j+1], 64)
 			if err != nil {
-				return nil, nil, fmt.Errorf("invalid value %w", err)
+				msg := fmt.Sprintf("invalid distance \"%s\"", fields[j+1])
+				return nil, nil, &SyntaxError{"distance matrix", lineNo, msg}
 			}
 			data = append(data, value)
 		}
@@ -39,7 +60,10 @@ func ReadDistanceMatrix(scanner *bufio.Scanner) (*TaxonSet, *ncd.TriangularMatri
 
 	nTaxa := i
 
-	taxonSet, _ := NewTaxonSet(taxonNames)
+	taxonSet, err := NewTaxonSet(taxonNames)
+	if err != nil {
+		return nil, nil, err
+	}
 
 	active := make([]bool, nTaxa)
 	for i := range active {
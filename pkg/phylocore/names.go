@@ -0,0 +1,265 @@
+package phylocore
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+// Characters with a meaning in Newick, which cannot appear in unquoted labels
+const newickReservedChars = "()[]':;,"
+
+// Tell if a name can be written as an unquoted Newick label
+func IsNewickSafe(name string) bool {
+	return name != "" && !strings.ContainsFunc(name, func(r rune) bool {
+		return unicode.IsSpace(r) || strings.ContainsRune(newickReservedChars, r)
+	})
+}
+
+// Return the label as written in Newick, single-quoted if it is not safe
+func newickLabel(label string) string {
+	if label == "" || IsNewickSafe(label) {
+		return label
+	}
+
+	return "'" + strings.ReplaceAll(label, "'", "''") + "'"
+}
+
+/*
+Make a name safe for Newick and other tree formats, by replacing whitespace and Newick reserved characters with
+underscores. Runs of replaced characters become a single underscore.
+*/
+func SanitizeName(name string) string {
+	var b strings.Builder
+	replaced := false
+	for _, r := range name {
+		if unicode.IsSpace(r) || strings.ContainsRune(newickReservedChars, r) {
+			if !replaced {
+				b.WriteByte('_')
+			}
+			replaced = true
+			continue
+		}
+		b.WriteRune(r)
+		replaced = false
+	}
+	if b.Len() == 0 {
+		return "_"
+	}
+
+	return b.String()
+}
+
+// How taxon names are made safe for output files
+type NameMode int
+
+const (
+	NamesKeep     NameMode = iota // Keep the names, quoting them in Newick if needed
+	NamesSanitize                 // Replace unsafe characters (see SanitizeName)
+	NamesEncode                   // Replace the names with short codes (T01, T02, ...), fitting in 10 characters
+)
+
+// Names of the name modes, in the order of the NameMode constants
+var NameModeNames = []string{"keep", "sanitize", "encode"}
+
+func (mode NameMode) String() string {
+	return NameModeNames[mode]
+}
+
+// Get the name mode that matches a name in NameModeNames
+func ParseNameMode(name string) (NameMode, error) {
+	for i, s := range NameModeNames {
+		if s == name {
+			return NameMode(i), nil
+		}
+	}
+
+	return NamesKeep, fmt.Errorf("unknown name mode \"%s\"", name)
+}
+
+/*
+Mapping from names to other names, in the order in which the mappings were added.
+
+Mapping files are tab-separated, with the name to replace in the first column and its replacement in the second.
+Empty lines and lines starting with '#' are ignored.
+*/
+type NameMap struct {
+	From  []string
+	To    []string
+	index map[string]int // From name -> position
+}
+
+func NewNameMap() *NameMap {
+	return &NameMap{make([]string, 0), make([]string, 0), make(map[string]int)}
+}
+
+// Add a mapping, which must be the only one from that name
+func (m *NameMap) Add(from string, to string) error {
+	if _, ok := m.index[from]; ok {
+		return fmt.Errorf("name \"%s\" mapped more than once", from)
+	}
+	m.index[from] = len(m.From)
+	m.From = append(m.From, from)
+	m.To = append(m.To, to)
+
+	return nil
+}
+
+// Get the replacement of a name
+func (m *NameMap) Get(from string) (string, bool) {
+	i, ok := m.index[from]
+	if !ok {
+		return "", false
+	}
+
+	return m.To[i], true
+}
+
+// Return the number of mappings
+func (m *NameMap) Len() int {
+	return len(m.From)
+}
+
+// Return the reverse mapping, which fails if several names are mapped to the same name
+func (m *NameMap) Inverse() (*NameMap, error) {
+	inverse := NewNameMap()
+	for i := range m.From {
+		if err := inverse.Add(m.To[i], m.From[i]); err != nil {
+			return nil, err
+		}
+	}
+
+	return inverse, nil
+}
+
+/*
+Replace the names that have a mapping, keeping the others.
+
+Returns an error if the renamed names are not unique.
+*/
+func (m *NameMap) Rename(names []string) ([]string, error) {
+	renamed := make([]string, len(names))
+	seen := make(map[string]string)
+
+	for i, name := range names {
+		renamed[i] = name
+		if to, ok := m.Get(name); ok {
+			renamed[i] = to
+		}
+		if first, ok := seen[renamed[i]]; ok {
+			return nil, fmt.Errorf("\"%s\" and \"%s\" would both be named \"%s\"", first, name, renamed[i])
+		}
+		seen[renamed[i]] = name
+	}
+
+	return renamed, nil
+}
+
+// Write the mappings as a tab-separated table, with a commented header line
+func (m *NameMap) Write(w io.Writer, fromHeader string, toHeader string) error {
+	writer := bufio.NewWriter(w)
+	fmt.Fprintf(writer, "#%s\t%s\n", fromHeader, toHeader)
+	for i := range m.From {
+		fmt.Fprintf(writer, "%s\t%s\n", m.From[i], m.To[i])
+	}
+
+	return writer.Flush()
+}
+
+// Read mappings from a tab-separated table
+func ReadNameMap(r io.Reader) (*NameMap, error) {
+	m := NewNameMap()
+	scanner := bufio.NewScanner(r)
+	lineNo := 0
+
+	for scanner.Scan() {
+		lineNo += 1
+		line := strings.TrimRight(scanner.Text(), "\r")
+		if strings.TrimSpace(line) == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+
+		fields := strings.Split(line, "\t")
+		if len(fields) != 2 || fields[0] == "" || fields[1] == "" {
+			return nil, fmt.Errorf("expected two non-empty tab-separated names in line %d of the name table", lineNo)
+		}
+		if err := m.Add(fields[0], fields[1]); err != nil {
+			return nil, fmt.Errorf("line %d of the name table: %w", lineNo, err)
+		}
+	}
+
+	return m, scanner.Err()
+}
+
+/*
+Make safe names for a list of unique names.
+
+Returns the safe names, and a map from the safe names to the original names, holding only the names that were
+changed. Safe names that would collide get a numeric suffix.
+*/
+func MakeSafeNames(names []string, mode NameMode) ([]string, *NameMap) {
+	safe := make([]string, len(names))
+	restore := NewNameMap()
+	used := make(map[string]bool, len(names))
+
+	// Names that are already safe keep priority over sanitized names
+	if mode == NamesSanitize {
+		for _, name := range names {
+			if IsNewickSafe(name) {
+				used[name] = true
+			}
+		}
+	}
+
+	width := len(strconv.Itoa(len(names)))
+	for i, name := range names {
+		switch mode {
+		case NamesKeep:
+			safe[i] = name
+			continue
+		case NamesEncode:
+			safe[i] = fmt.Sprintf("T%0*d", width, i+1)
+		case NamesSanitize:
+			if IsNewickSafe(name) {
+				safe[i] = name
+				continue
+			}
+			safe[i] = SanitizeName(name)
+			for k := 2; used[safe[i]]; k += 1 {
+				safe[i] = SanitizeName(name) + "_" + strconv.Itoa(k)
+			}
+			used[safe[i]] = true
+		}
+		restore.Add(safe[i], name)
+	}
+
+	return safe, restore
+}
+
+/*
+Add aliases of taxa, mapped to their names.
+
+Trees read with the taxon set then match labels that are aliases to their taxa, and relabel the nodes with the
+names of the taxa. This restores the original names on trees written with safe names.
+*/
+func (taxset *TaxonSet) AddAliases(aliases *NameMap) error {
+	if taxset.aliases == nil {
+		taxset.aliases = make(map[string]int)
+	}
+
+	for i, alias := range aliases.From {
+		id, ok := taxset.GetId(aliases.To[i])
+		if !ok {
+			return fmt.Errorf("alias \"%s\" of unknown taxon \"%s\"", alias, aliases.To[i])
+		}
+		if other, ok := taxset.nameMap[alias]; ok && other != id {
+			return fmt.Errorf("alias \"%s\" is the name of another taxon", alias)
+		}
+		taxset.aliases[alias] = id
+	}
+
+	return nil
+}
@@ -0,0 +1,163 @@
+package phylocore
+
+import (
+	"bytes"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestSanitizeName(t *testing.T) {
+	tests := []struct {
+		name string
+		want string
+	}{
+		{"Balaena_mysticetus-NC_005268.1", "Balaena_mysticetus-NC_005268.1"},
+		{"Homo sapiens (human)", "Homo_sapiens_human_"},
+		{"a:b,c;d'e[f]", "a_b_c_d_e_f_"},
+		{"", "_"},
+	}
+
+	for _, tt := range tests {
+		if got := SanitizeName(tt.name); got != tt.want {
+			t.Errorf("SanitizeName(%q) = %q, want %q", tt.name, got, tt.want)
+		}
+		if got := SanitizeName(tt.name); !IsNewickSafe(got) {
+			t.Errorf("SanitizeName(%q) = %q is not Newick-safe", tt.name, got)
+		}
+	}
+}
+
+func TestMakeSafeNames(t *testing.T) {
+	names := []string{"a b", "a_b", "a:b", "c"}
+
+	tests := []struct {
+		mode    NameMode
+		want    []string
+		changed int
+	}{
+		{NamesKeep, names, 0},
+		{NamesSanitize, []string{"a_b_2", "a_b", "a_b_3", "c"}, 2},
+		{NamesEncode, []string{"T1", "T2", "T3", "T4"}, 4},
+	}
+
+	for _, tt := range tests {
+		safe, restore := MakeSafeNames(names, tt.mode)
+		if !reflect.DeepEqual(safe, tt.want) || restore.Len() != tt.changed {
+			t.Errorf("%s: got %q with %d changes, want %q with %d", tt.mode, safe, restore.Len(), tt.want, tt.changed)
+		}
+		for i, s := range safe {
+			if original, ok := restore.Get(s); ok && original != names[i] {
+				t.Errorf("%s: %q restored as %q, want %q", tt.mode, s, original, names[i])
+			}
+		}
+	}
+}
+
+func TestNameMap_ReadWrite(t *testing.T) {
+	m := NewNameMap()
+	m.Add("T1", "Homo sapiens")
+	m.Add("T2", "a:b")
+	if err := m.Add("T1", "x"); err == nil {
+		t.Errorf("expected error for duplicate mapping, got nil")
+	}
+
+	var buf bytes.Buffer
+	if err := m.Write(&buf, "name", "original_name"); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	read, err := ReadNameMap(&buf)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if !reflect.DeepEqual(read.From, m.From) || !reflect.DeepEqual(read.To, m.To) {
+		t.Errorf("got %q -> %q, want %q -> %q", read.From, read.To, m.From, m.To)
+	}
+
+	for _, input := range []string{"a\n", "a\tb\tc\n", "a\tb\na\tc\n", "\tb\n"} {
+		if _, err := ReadNameMap(strings.NewReader(input)); err == nil {
+			t.Errorf("%q: expected error, got nil", input)
+		}
+	}
+}
+
+func TestNameMap_Rename(t *testing.T) {
+	m, err := ReadNameMap(strings.NewReader("# old\tnew\na\tx\r\nb\ty\n\nz\tw\n"))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	got, err := m.Rename([]string{"a", "b", "c"})
+	if err != nil || !reflect.DeepEqual(got, []string{"x", "y", "c"}) {
+		t.Errorf("got %q, %v, want [x y c]", got, err)
+	}
+	if _, err := m.Rename([]string{"a", "x"}); err == nil {
+		t.Errorf("expected error for renaming into an existing name, got nil")
+	}
+
+	inverse, err := m.Inverse()
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if from, _ := inverse.Get("y"); from != "b" {
+		t.Errorf("inverse of y: got %q, want b", from)
+	}
+}
+
+func TestNewickQuotedLabels(t *testing.T) {
+	names := []string{"Homo sapiens", "a:b,c", "O'Brien", "plain"}
+	taxset, _ := NewTaxonSet(names)
+	tree := taxset.MakeUnassembledTree()
+	for i := range len(names) {
+		tree.Nodes[len(names)].AddChild(tree.Nodes[i], tree.NewBranch())
+	}
+
+	newick := tree.NewickString()
+	want := "('Homo sapiens','a:b,c','O''Brien',plain);"
+	if newick != want {
+		t.Fatalf("got %s, want %s", newick, want)
+	}
+
+	read, readTaxset, err := readNewickString(newick)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if !reflect.DeepEqual(readTaxset.Names, names) {
+		t.Errorf("got names %q, want %q", readTaxset.Names, names)
+	}
+	if read.NewickString() != want {
+		t.Errorf("round trip: got %s, want %s", read.NewickString(), want)
+	}
+
+	if _, _, err := readNewickString("('unterminated,b);"); err == nil {
+		t.Errorf("expected error for unterminated quote, got nil")
+	}
+}
+
+func TestTaxonSet_AddAliases(t *testing.T) {
+	names := []string{"Homo sapiens", "a:b", "c"}
+	safe, restore := MakeSafeNames(names, NamesSanitize)
+
+	taxset, _ := NewTaxonSet(names)
+	if err := taxset.AddAliases(restore); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	tree, err := readNewickStringWithTaxset("(("+safe[0]+":1,"+safe[1]+":2):1,c:3);", taxset, false)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	nodes := nodesByLabel(tree)
+	for i, name := range names {
+		node, ok := nodes[name]
+		if !ok || node.TaxonId != i {
+			t.Errorf("taxon %q not restored", name)
+		}
+	}
+
+	bad := NewNameMap()
+	bad.Add("x", "unknown")
+	if err := taxset.AddAliases(bad); err == nil {
+		t.Errorf("expected error for alias of unknown taxon, got nil")
+	}
+}
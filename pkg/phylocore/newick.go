@@ -3,8 +3,10 @@ package phylocore
 import (
 	"bufio"
 	"fmt"
+	"io"
 	"math"
 	"os"
+	"runtime"
 	"strconv"
 	"strings"
 	"unicode"
@@ -32,7 +34,7 @@ func (node *Node) makeNewick(b *strings.Builder) {
 		}
 		b.WriteString(")")
 	}
-	b.WriteString(node.Label)
+	b.WriteString(newickLabel(node.Label))
 }
 
 /*
@@ -71,6 +73,20 @@ const (
 	tknValue // For raw strings, both labels and branch lengths
 )
 
+/*
+Abort the parsing of a Newick string with a syntax error. Parsing functions panic with a newickSyntaxPanic, which
+parseNewick turns into a *SyntaxError.
+*/
+type newickSyntaxPanic string
+
+// Abort the parsing after an error while reading the stream: a syntax error at the end of the stream, or an I/O error
+func newickReadPanic(err error) {
+	if err == io.EOF {
+		panic(newickSyntaxPanic("unexpected end of the Newick string"))
+	}
+	panic(err)
+}
+
 type newickTokenizer struct {
 	stream  *bufio.Reader
 	builder *strings.Builder
@@ -98,14 +114,14 @@ func recognizeNewickToken(c rune) newickToken {
 func (tokenizer *newickTokenizer) Read() {
 	c, _, err := tokenizer.stream.ReadRune()
 	if err != nil {
-		panic(fmt.Sprintf("error reading Newick string: %v", err))
+		newickReadPanic(err)
 	}
 
 	// Consume whitespace
 	for unicode.IsSpace(c) {
 		c, _, err = tokenizer.stream.ReadRune()
 		if err != nil {
-			panic(fmt.Sprintf("error reading Newick string: %v", err))
+			newickReadPanic(err)
 		}
 	}
 
@@ -121,6 +137,11 @@ func (tokenizer *newickTokenizer) Read() {
 	// Read value
 	tokenizer.builder.Reset()
 
+	if c == '\'' {
+		tokenizer.readQuoted()
+		return
+	}
+
 	for tkn == tknValue {
 		if unicode.IsSpace(c) {
 			break
@@ -129,7 +150,7 @@ func (tokenizer *newickTokenizer) Read() {
 
 		c, _, err = tokenizer.stream.ReadRune()
 		if err != nil {
-			panic(fmt.Sprintf("error reading Newick string: %v", err))
+			newickReadPanic(err)
 		}
 
 		tkn = recognizeNewickToken(c)
@@ -141,14 +162,44 @@ func (tokenizer *newickTokenizer) Read() {
 	tokenizer.stream.UnreadRune()
 }
 
-type newickParseContext struct {
+/*
+Read a single-quoted label, after the opening quote. Two consecutive quotes stand for a quote in the label.
+*/
+func (tokenizer *newickTokenizer) readQuoted() {
+	for {
+		c, _, err := tokenizer.stream.ReadRune()
+		if err == io.EOF {
+			panic(newickSyntaxPanic("unterminated quoted label"))
+		}
+		if err != nil {
+			panic(err)
+		}
+		if c == '\'' {
+			next, _, err := tokenizer.stream.ReadRune()
+			if err == nil && next == '\'' {
+				tokenizer.builder.WriteRune(c)
+				continue
+			}
+			if err == nil {
+				tokenizer.stream.UnreadRune()
+			}
+			break
+		}
+		tokenizer.builder.WriteRune(c)
+	}
+
+	tokenizer.token = tknValue
+	tokenizer.value = tokenizer.builder.String()
+}
+
+type treeParseContext struct {
 	taxset        *TaxonSet
 	tree          *Tree
 	taxonTargets  NodeGroup
 	acceptNewTaxa bool
 }
 
-func (ctx *newickParseContext) setTaxon(node *Node) {
+func (ctx *treeParseContext) setTaxon(node *Node) {
 	node.TaxonId = -1
 
 	switch ctx.taxonTargets {
@@ -163,9 +214,17 @@ func (ctx *newickParseContext) setTaxon(node *Node) {
 	}
 
 	taxonId, ok := ctx.taxset.GetId(node.Label)
+	if alias, isAlias := ctx.taxset.aliases[node.Label]; !ok && isAlias {
+		// Restore the name of the taxon
+		taxonId, ok = alias, true
+		node.Label = ctx.taxset.Names[taxonId]
+	}
 	if !ok {
 		if ctx.acceptNewTaxa {
-			taxonId = ctx.taxset.NewTaxon(node.Label)
+			taxonId, err := ctx.taxset.NewTaxon(node.Label)
+			if err != nil {
+				panic(err)
+			}
 			node.TaxonId = taxonId
 		}
 	} else {
@@ -175,8 +234,19 @@ func (ctx *newickParseContext) setTaxon(node *Node) {
 
 func (taxset *TaxonSet) parseNewick(reader *bufio.Reader, addNew bool) (tree *Tree, err error) {
 	defer func() {
-		if r := recover(); r != nil {
-			err = fmt.Errorf("newick: %v", r)
+		r := recover()
+		if r == nil {
+			return
+		}
+		if _, isBug := r.(runtime.Error); isBug {
+			panic(r)
+		}
+		if msg, ok := r.(newickSyntaxPanic); ok {
+			tree, err = nil, &SyntaxError{"Newick", 0, string(msg)}
+		} else if e, ok := r.(error); ok {
+			tree, err = nil, fmt.Errorf("newick: %w", e)
+		} else {
+			panic(r)
 		}
 	}()
 
@@ -187,7 +257,7 @@ func (taxset *TaxonSet) parseNewick(reader *bufio.Reader, addNew bool) (tree *Tr
 	}
 
 	var builder strings.Builder
-	ctx := newickParseContext{
+	ctx := treeParseContext{
 		taxset, tree, OuterNodes, addNew,
 	}
 	tokenizer := newickTokenizer{
@@ -199,7 +269,7 @@ func (taxset *TaxonSet) parseNewick(reader *bufio.Reader, addNew bool) (tree *Tr
 	ctx.parseRoot(&tokenizer)
 
 	if tokenizer.token != tknTerminate {
-		panic("Newick not terminated with ';'")
+		panic(newickSyntaxPanic("not terminated with ';'"))
 	}
 
 	return tree, nil
@@ -224,7 +294,7 @@ func parseBranchLength(node *Node, tokenizer *newickTokenizer) {
 	if tokenizer.token == tknValue {
 		brlength, err := strconv.ParseFloat(tokenizer.value, 64)
 		if err != nil {
-			panic(fmt.Sprintf("Could not parse branch length: \"%s\"", tokenizer.value))
+			panic(newickSyntaxPanic(fmt.Sprintf("invalid branch length \"%s\"", tokenizer.value)))
 		}
 
 		// Edge case: Root with branch length
@@ -241,7 +311,7 @@ func parseBranchLength(node *Node, tokenizer *newickTokenizer) {
 }
 
 /* Handles edge case of the root being a node without children. */
-func (ctx *newickParseContext) parseRoot(tokenizer *newickTokenizer) {
+func (ctx *treeParseContext) parseRoot(tokenizer *newickTokenizer) {
 	root := ctx.tree.NewNode()
 	ctx.tree.Root = root
 	if tokenizer.token == tknOpenParens {
@@ -251,7 +321,7 @@ func (ctx *newickParseContext) parseRoot(tokenizer *newickTokenizer) {
 	}
 }
 
-func (ctx *newickParseContext) parseOuterNode(node *Node, tokenizer *newickTokenizer) {
+func (ctx *treeParseContext) parseOuterNode(node *Node, tokenizer *newickTokenizer) {
 	if tokenizer.token == tknValue {
 		node.Label = tokenizer.value
 		ctx.setTaxon(node)
@@ -265,10 +335,10 @@ func (ctx *newickParseContext) parseOuterNode(node *Node, tokenizer *newickToken
 	}
 }
 
-func (ctx *newickParseContext) parseInnerNode(node *Node, tokenizer *newickTokenizer) {
+func (ctx *treeParseContext) parseInnerNode(node *Node, tokenizer *newickTokenizer) {
 	// Consume the open parens
 	if tokenizer.token != tknOpenParens {
-		panic("Expected '(' in Newick string")
+		panic(newickSyntaxPanic("expected '('"))
 	}
 	tokenizer.Read()
 
@@ -294,7 +364,7 @@ func (ctx *newickParseContext) parseInnerNode(node *Node, tokenizer *newickToken
 
 	// Now the current token must be a closing parens
 	if tokenizer.token != tknCloseParens {
-		panic("Unmatched '(' in Newick string")
+		panic(newickSyntaxPanic("unmatched '('"))
 	}
 
 	tokenizer.Read()
@@ -0,0 +1,280 @@
+package phylocore
+
+import (
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+const (
+	neXMLNamespace   = "http://www.nexml.org/2009"
+	neXMLVersion     = "0.9"
+	ncdtreeNamespace = "https://github.com/eascarrunz/ncdtree#"
+
+	// Meta property under which support values are stored
+	supportProperty = "ncdtree:support"
+)
+
+type neXMLDocument struct {
+	XMLName      xml.Name
+	Version      string       `xml:"version,attr"`
+	Xmlns        string       `xml:"xmlns,attr,omitempty"`
+	XmlnsNex     string       `xml:"xmlns:nex,attr,omitempty"`
+	XmlnsXsi     string       `xml:"xmlns:xsi,attr,omitempty"`
+	XmlnsXsd     string       `xml:"xmlns:xsd,attr,omitempty"`
+	XmlnsNcdtree string       `xml:"xmlns:ncdtree,attr,omitempty"`
+	Otus         []neXMLOtus  `xml:"otus"`
+	Trees        []neXMLTrees `xml:"trees"`
+}
+
+type neXMLOtus struct {
+	Id   string     `xml:"id,attr"`
+	Otus []neXMLOtu `xml:"otu"`
+}
+
+type neXMLOtu struct {
+	Id    string `xml:"id,attr"`
+	Label string `xml:"label,attr,omitempty"`
+}
+
+type neXMLTrees struct {
+	Id    string      `xml:"id,attr"`
+	Otus  string      `xml:"otus,attr"`
+	Trees []neXMLTree `xml:"tree"`
+}
+
+type neXMLTree struct {
+	Id    string      `xml:"id,attr"`
+	Type  string      `xml:"xsi:type,attr,omitempty"`
+	Nodes []neXMLNode `xml:"node"`
+	Edges []neXMLEdge `xml:"edge"`
+}
+
+type neXMLNode struct {
+	Id    string      `xml:"id,attr"`
+	Label string      `xml:"label,attr,omitempty"`
+	Otu   string      `xml:"otu,attr,omitempty"`
+	Root  bool        `xml:"root,attr,omitempty"`
+	Metas []neXMLMeta `xml:"meta"`
+}
+
+type neXMLEdge struct {
+	Id     string   `xml:"id,attr"`
+	Source string   `xml:"source,attr"`
+	Target string   `xml:"target,attr"`
+	Length *float64 `xml:"length,attr"`
+}
+
+type neXMLMeta struct {
+	Type     string `xml:"xsi:type,attr,omitempty"`
+	Property string `xml:"property,attr"`
+	Datatype string `xml:"datatype,attr,omitempty"`
+	Content  string `xml:"content,attr"`
+}
+
+/*
+Write the tree as a NeXML document.
+
+The taxon set is written as the block of OTUs, and outer nodes are linked to the OTU of their taxon. If the taxon set is
+nil, one OTU is created for each labelled outer node. Support values and node properties are written as literal meta
+annotations.
+
+Reference for the NeXML format: http://www.nexml.org
+*/
+func (tree *Tree) WriteNeXML(w io.Writer, taxset *TaxonSet) error {
+	otuIds := make(map[int]string)
+	otus := neXMLOtus{Id: "otus1"}
+
+	if taxset != nil {
+		for i, name := range taxset.Names {
+			otuIds[i] = "otu" + strconv.Itoa(i)
+			otus.Otus = append(otus.Otus, neXMLOtu{Id: otuIds[i], Label: name})
+		}
+	}
+
+	nexTree := neXMLTree{Id: "tree1", Type: "nex:FloatTree"}
+	nextOtu := len(otus.Otus)
+
+	addNode := func(node *Node) {
+		nexNode := neXMLNode{Id: "n" + strconv.Itoa(node.Id), Label: node.Label, Root: node == tree.Root}
+
+		if taxset != nil {
+			if node.TaxonId >= 0 {
+				nexNode.Otu = otuIds[node.TaxonId]
+			}
+		} else if node.IsOuter() && node.Label != "" {
+			nexNode.Otu = "otu" + strconv.Itoa(nextOtu)
+			otus.Otus = append(otus.Otus, neXMLOtu{Id: nexNode.Otu, Label: node.Label})
+			nextOtu += 1
+		}
+
+		if !math.IsNaN(node.Support) {
+			nexNode.Metas = append(nexNode.Metas, neXMLMeta{
+				Type:     "nex:LiteralMeta",
+				Property: supportProperty,
+				Datatype: "xsd:double",
+				Content:  formatXMLFloat(node.Support),
+			})
+		}
+
+		keys := make([]string, 0, len(node.Properties))
+		for key := range node.Properties {
+			keys = append(keys, key)
+		}
+		slices.Sort(keys)
+
+		for _, key := range keys {
+			property := key
+			if !strings.Contains(property, ":") {
+				property = propertyPrefix + property
+			}
+			nexNode.Metas = append(nexNode.Metas, neXMLMeta{
+				Type:     "nex:LiteralMeta",
+				Property: property,
+				Datatype: "xsd:string",
+				Content:  node.Properties[key],
+			})
+		}
+
+		nexTree.Nodes = append(nexTree.Nodes, nexNode)
+
+		for _, branch := range node.Out {
+			edge := neXMLEdge{
+				Id:     "e" + strconv.Itoa(branch.Id),
+				Source: nexNode.Id,
+				Target: "n" + strconv.Itoa(branch.Child.Id),
+			}
+			if !math.IsNaN(branch.Length) {
+				length := branch.Length
+				edge.Length = &length
+			}
+			nexTree.Edges = append(nexTree.Edges, edge)
+		}
+	}
+
+	tree.TraverseNodes(addNode, PreOrder)
+
+	doc := neXMLDocument{
+		XMLName:      xml.Name{Local: "nex:nexml"},
+		Version:      neXMLVersion,
+		Xmlns:        neXMLNamespace,
+		XmlnsNex:     neXMLNamespace,
+		XmlnsXsi:     xmlSchemaInstanceNamespace,
+		XmlnsXsd:     xmlSchemaNamespace,
+		XmlnsNcdtree: ncdtreeNamespace,
+		Otus:         []neXMLOtus{otus},
+		Trees:        []neXMLTrees{{Id: "trees1", Otus: otus.Id, Trees: []neXMLTree{nexTree}}},
+	}
+
+	return writeXMLDocument(w, doc)
+}
+
+func (taxset *TaxonSet) parseNeXML(r io.Reader, addNew bool) (tree *Tree, err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			err = fmt.Errorf("nexml: %v", r)
+		}
+	}()
+
+	var doc neXMLDocument
+	if err := xml.NewDecoder(r).Decode(&doc); err != nil {
+		return nil, fmt.Errorf("nexml: %w", err)
+	}
+	if len(doc.Trees) == 0 || len(doc.Trees[0].Trees) == 0 {
+		return nil, errors.New("nexml: no tree found")
+	}
+
+	otuLabels := make(map[string]string)
+	for _, otus := range doc.Otus {
+		for _, otu := range otus.Otus {
+			otuLabels[otu.Id] = otu.Label
+		}
+	}
+
+	nexTree := doc.Trees[0].Trees[0]
+	tree = NewEmptyTree(len(nexTree.Nodes))
+	nodeMap := make(map[string]*Node, len(nexTree.Nodes))
+
+	for _, nexNode := range nexTree.Nodes {
+		if _, ok := nodeMap[nexNode.Id]; ok {
+			return nil, fmt.Errorf("nexml: duplicate node id \"%s\"", nexNode.Id)
+		}
+		node := tree.NewNode()
+		node.Label = nexNode.Label
+		if node.Label == "" && nexNode.Otu != "" {
+			node.Label = otuLabels[nexNode.Otu]
+		}
+		for _, meta := range nexNode.Metas {
+			if meta.Property == supportProperty {
+				node.Support, err = strconv.ParseFloat(meta.Content, 64)
+				if err != nil {
+					return nil, fmt.Errorf("nexml: invalid support value \"%s\"", meta.Content)
+				}
+			} else {
+				node.SetProperty(strings.TrimPrefix(meta.Property, propertyPrefix), meta.Content)
+			}
+		}
+		if nexNode.Root {
+			tree.Root = node
+		}
+		nodeMap[nexNode.Id] = node
+	}
+
+	for _, edge := range nexTree.Edges {
+		parent, okParent := nodeMap[edge.Source]
+		child, okChild := nodeMap[edge.Target]
+		if !okParent || !okChild {
+			return nil, fmt.Errorf("nexml: edge \"%s\" refers to an unknown node", edge.Id)
+		}
+		if child.In != nil {
+			return nil, fmt.Errorf("nexml: node \"%s\" has more than one parent", edge.Target)
+		}
+		branch := tree.NewBranch()
+		if edge.Length != nil {
+			branch.Length = *edge.Length
+		}
+		parent.AddChild(child, branch)
+	}
+
+	// Fall back to the first node without a parent if no root is marked
+	if tree.Root == nil {
+		for _, node := range tree.Nodes {
+			if node.In == nil {
+				tree.Root = node
+				break
+			}
+		}
+	}
+	if tree.Root == nil {
+		return nil, errors.New("nexml: tree has no root")
+	}
+
+	ctx := treeParseContext{taxset, tree, OuterNodes, addNew}
+	setTaxon := func(node *Node) {
+		if node.Label != "" {
+			ctx.setTaxon(node)
+		}
+	}
+	tree.TraverseNodes(setTaxon, PreOrder)
+
+	return tree, nil
+}
+
+// Read the first tree of a NeXML document and return a tree with a matching taxon set
+func ReadNeXML(r io.Reader) (*Tree, *TaxonSet, error) {
+	taxset, _ := NewTaxonSet(make([]string, 0))
+	tree, err := taxset.parseNeXML(r, true)
+
+	return tree, taxset, err
+}
+
+// Read the first tree of a NeXML document and return a tree with taxa matching a given taxon set, optionally adding
+// new taxa
+func (taxset *TaxonSet) ReadNeXML(r io.Reader, addNew bool) (*Tree, error) {
+	return taxset.parseNeXML(r, addNew)
+}
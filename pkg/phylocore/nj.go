@@ -1,6 +1,7 @@
 package phylocore
 
 import (
+	"fmt"
 	"math"
 	"ncdtree/pkg/ncd"
 )
@@ -100,9 +101,16 @@ func selectLastTargets(D *ncd.TriangularMatrix) (int, int, float64) {
 
 // Construct a tree from a distance matrix D using the Neighbour Joining algorithm of Saitou and Nei (1987)
 // Outer node labels come from the taxon set where the taxon indices match the row order in the matrix D
-func NeighbourJoining(taxset *TaxonSet, D *ncd.TriangularMatrix) *Tree {
+// Fails with ErrTooFewTaxa if there are less than 2 taxa, and with ErrMatrixSize if D has another size
+func NeighbourJoining(taxset *TaxonSet, D *ncd.TriangularMatrix) (*Tree, error) {
 	nbTaxa := taxset.Len()
-	nbNode := 2*nbTaxa - 2
+	if nbTaxa < 2 {
+		return nil, fmt.Errorf("%w: at least 2 are needed for a tree, got %d", ErrTooFewTaxa, nbTaxa)
+	}
+	if D.N != nbTaxa {
+		return nil, fmt.Errorf("%w (%d rows for %d taxa)", ErrMatrixSize, D.N, nbTaxa)
+	}
+	nbNode := max(2*nbTaxa-2, 3) // With 2 taxa, a root between them
 	tree := MakeUnassembledTreePhylip(nbNode)
 	tree.Root = tree.Nodes[nbTaxa]
 
@@ -120,6 +128,16 @@ func NeighbourJoining(taxset *TaxonSet, D *ncd.TriangularMatrix) *Tree {
 		activeIndices[i] = true
 	}
 
+	// Nothing to join with 2 taxa: the root splits the distance between them in halves
+	if nbTaxa == 2 {
+		for _, node := range targetNodes {
+			branch := tree.NewBranch()
+			branch.Length = 0.5 * D.Get(1, 0)
+			tree.Root.AddChild(node, branch)
+		}
+		return tree, nil
+	}
+
 	// Vector of the sum of distances between each taxon and all the remaining nodes
 	// (notation from Studier & Keppler 1988)
 	R := make([]float64, nbTaxa)
@@ -175,5 +193,5 @@ func NeighbourJoining(taxset *TaxonSet, D *ncd.TriangularMatrix) *Tree {
 		panic("the root was not used in the last join")
 	}
 
-	return tree
+	return tree, nil
 }
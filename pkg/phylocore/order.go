@@ -0,0 +1,115 @@
+package phylocore
+
+import (
+	"cmp"
+	"slices"
+)
+
+/*========================================================================
+	ORDER OF THE CHILDREN OF NODES
+········································································*/
+
+// Return the number of tips of the clade of each node of the tree
+func (tree *Tree) cladeSizes() map[*Node]int {
+	nbTips := make(map[*Node]int, len(tree.Nodes))
+	tree.TraverseNodes(func(node *Node) {
+		if node.IsOuter() {
+			nbTips[node] = 1
+		}
+		for _, branch := range node.Out {
+			nbTips[node] += nbTips[branch.Child]
+		}
+	}, PostOrder)
+
+	return nbTips
+}
+
+// Return the smallest tip label of the clade of each node of the tree
+func (tree *Tree) smallestLabels() map[*Node]string {
+	labels := make(map[*Node]string, len(tree.Nodes))
+	tree.TraverseNodes(func(node *Node) {
+		if node.IsOuter() {
+			labels[node] = node.Label
+			return
+		}
+		labels[node] = labels[node.Out[0].Child]
+		for _, branch := range node.Out[1:] {
+			labels[node] = min(labels[node], labels[branch.Child])
+		}
+	}, PostOrder)
+
+	return labels
+}
+
+/*
+Sort the children of every node of the tree with a comparison function of the child nodes, which returns a negative
+number if a comes before b, a positive number if it comes after, and 0 to keep their order.
+*/
+func (tree *Tree) SortChildren(compare func(a *Node, b *Node) int) {
+	tree.TraverseNodes(func(node *Node) {
+		slices.SortStableFunc(node.Out, func(a, b *Branch) int {
+			return compare(a.Child, b.Child)
+		})
+	}, PreOrder)
+}
+
+/*
+Order the children of every node by the number of tips of their clades, from the smallest to the largest clade if
+ascending is set, or from the largest to the smallest otherwise. Clades of the same size keep their order.
+*/
+func (tree *Tree) Ladderize(ascending bool) {
+	nbTips := tree.cladeSizes()
+	tree.SortChildren(func(a, b *Node) int {
+		if ascending {
+			return cmp.Compare(nbTips[a], nbTips[b])
+		}
+		return cmp.Compare(nbTips[b], nbTips[a])
+	})
+}
+
+// Order the children of every node by the smallest tip label of their clades
+func (tree *Tree) SortByLabel() {
+	labels := tree.smallestLabels()
+	tree.SortChildren(func(a, b *Node) int {
+		return cmp.Compare(labels[a], labels[b])
+	})
+}
+
+// Reverse the order of the children of the node, which turns its clade around in drawings
+func (node *Node) Rotate() {
+	slices.Reverse(node.Out)
+}
+
+/*
+Put the tree in a form that only depends on its unrooted topology, its tip labels and its branch lengths, such as the
+same neighbour-joining tree whatever the order of the taxa in the matrix.
+
+A root with two children is removed, and the tree is rooted on the parent of the tip with the smallest label (see
+Reroot). The children of every node are then ordered from the smallest to the largest clade, and by the smallest tip
+label of the clades of the same size.
+*/
+func (tree *Tree) Canonicalize() {
+	if tree.Root.OutDegree() == 2 {
+		for _, branch := range tree.Root.Out {
+			if branch.Child.IsInner() {
+				tree.Reroot(branch.Child)
+				break
+			}
+		}
+	}
+	var first *Node
+	tree.TraverseNodes(func(node *Node) {
+		if node.IsOuter() && (first == nil || node.Label < first.Label) {
+			first = node
+		}
+	}, PreOrder)
+	if first.In != nil {
+		tree.Reroot(first.In.Parent)
+	}
+
+	nbTips := tree.cladeSizes()
+	labels := tree.smallestLabels()
+	tree.SortChildren(func(a, b *Node) int {
+		return cmp.Or(cmp.Compare(nbTips[a], nbTips[b]), cmp.Compare(labels[a], labels[b]))
+	})
+}
@@ -0,0 +1,157 @@
+package phylocore
+
+import (
+	"math"
+	"math/rand/v2"
+	"ncdtree/pkg/ncd"
+	"strings"
+	"testing"
+)
+
+// Read a tree from a Newick string, failing the test if it is invalid
+func mustReadNewick(t *testing.T, s string) *Tree {
+	t.Helper()
+	tree, _, err := readNewickString(s)
+	if err != nil {
+		t.Fatalf("cannot read %q: %v", s, err)
+	}
+
+	return tree
+}
+
+func TestLadderize(t *testing.T) {
+	tree := mustReadNewick(t, "((a,(b,c)),d,(e,f));")
+	tree.Ladderize(true)
+	if got, want := tree.NewickString(), "(d,(e,f),(a,(b,c)));"; got != want {
+		t.Errorf("ascending: %s, want %s", got, want)
+	}
+	tree.Ladderize(false)
+	if got, want := tree.NewickString(), "(((b,c),a),(e,f),d);"; got != want {
+		t.Errorf("descending: %s, want %s", got, want)
+	}
+}
+
+func TestSortChildren(t *testing.T) {
+	tree := mustReadNewick(t, "((d,c)x,(b,a)y);")
+	tree.SortByLabel()
+	if got, want := tree.NewickString(), "((a,b)y,(c,d)x);"; got != want {
+		t.Errorf("by label: %s, want %s", got, want)
+	}
+
+	// Custom order: by descending label of the nodes themselves
+	tree.SortChildren(func(a, b *Node) int { return strings.Compare(b.Label, a.Label) })
+	if got, want := tree.NewickString(), "((b,a)y,(d,c)x);"; got != want {
+		t.Errorf("custom order: %s, want %s", got, want)
+	}
+}
+
+func TestRotate(t *testing.T) {
+	tree := mustReadNewick(t, textPlotNewick)
+	nodes := nodesByLabel(tree)
+	nodes["df"].Rotate()
+	tree.Root.Rotate()
+	want := "(e:1,(c:1,(f:0.5,d:3)df:0.2):2,(a:1,b:2)ab:1);"
+	if got := tree.NewickString(); got != want {
+		t.Errorf("rotated: %s, want %s", got, want)
+	}
+}
+
+func TestCanonicalize(t *testing.T) {
+	// The same unrooted tree, rooted in different places, with the children in different orders
+	want := "(a:1,b:2,(c:3,(d:4,e:5):6):7);"
+	trees := []string{
+		"((c:3,(b:2,a:1):7):6,d:4,e:5);",
+		"(e:5,d:4,((a:1,b:2):7,c:3):6);",
+		"((a:1,b:2):3.5,(c:3,(d:4,e:5):6):3.5);",
+		"(((d:4,e:5):6,c:3):7,b:2,a:1);",
+		"(e:4,(d:4,(c:3,(a:1,b:2):7):6):1);",
+	}
+	for _, s := range trees {
+		tree := mustReadNewick(t, s)
+		tree.Canonicalize()
+		if got := tree.NewickString(); got != want {
+			t.Errorf("canonical form of %s: %s, want %s", s, got, want)
+		}
+	}
+}
+
+func TestReroot(t *testing.T) {
+	tree := mustReadNewick(t, "((a:1,b:2)ab:3,(c:1,d:2)cd:4)r;")
+	nodes := nodesByLabel(tree)
+	nodes["ab"].Support = 90
+	nodes["cd"].Support = 80
+	tree.Reroot(nodes["cd"])
+
+	// The root with 2 children is merged into the branch between the clades, which keeps the support of ab
+	if got, want := tree.NewickString(), "(c:1,d:2,(a:1,b:2)ab:7)cd;"; got != want {
+		t.Errorf("got %s, want %s", got, want)
+	}
+	if s := nodes["ab"].Support; s != 90 {
+		t.Errorf("support of ab %v, want 90", s)
+	}
+	if len(tree.Nodes) != 6 || len(tree.Branches) != 5 || tree.NbNodes() != 6 {
+		t.Errorf("%d nodes and %d branches, want 6 and 5", len(tree.Nodes), len(tree.Branches))
+	}
+	for i, node := range tree.Nodes {
+		if node.Id != i {
+			t.Errorf("node %d has ID %d", i, node.Id)
+		}
+	}
+
+	// Rooting on a tip's parent down the tree turns the branches of the path around
+	tree = mustReadNewick(t, "((a:1,(b:1,c:1)bc:2)x:1,d:1,e:1)r;")
+	nodes = nodesByLabel(tree)
+	nodes["bc"].Support = 70
+	nodes["x"].Support = 60
+	tree.Reroot(nodes["bc"])
+	if got, want := tree.NewickString(), "(b:1,c:1,(a:1,(d:1,e:1)r:1)x:2)bc;"; got != want {
+		t.Errorf("got %s, want %s", got, want)
+	}
+	if nodes["x"].Support != 70 || nodes["r"].Support != 60 || !math.IsNaN(nodes["bc"].Support) {
+		t.Errorf("supports after rerooting: x %v, r %v, bc %v", nodes["x"].Support, nodes["r"].Support, nodes["bc"].Support)
+	}
+}
+
+// Return a copy of a distance matrix with the taxa in another order, where order[i] is the former index of taxon i
+func permuteMatrix(D *ncd.TriangularMatrix, order []int) *ncd.TriangularMatrix {
+	P := ncd.NewTriangularMatrix(D.N)
+	for i := range D.N {
+		for j := range i {
+			P.Set(i, j, D.Get(order[i], order[j]))
+		}
+	}
+
+	return P
+}
+
+func TestCanonicalizeNeighbourJoining(t *testing.T) {
+	names := []string{"a", "b", "c", "d", "e", "f", "g", "h"}
+	rng := rand.New(rand.NewPCG(1, 2))
+	D := ncd.NewTriangularMatrix(len(names))
+	for i := range D.N {
+		for j := range i {
+			D.Set(i, j, 0.5+rng.Float64()/2)
+		}
+	}
+
+	want := ""
+	for k := range 20 {
+		order := rng.Perm(len(names))
+		permuted := make([]string, len(names))
+		for i, from := range order {
+			permuted[i] = names[from]
+		}
+		taxset, _ := NewTaxonSet(permuted)
+		tree, err := NeighbourJoining(taxset, permuteMatrix(D, order))
+		if err != nil {
+			t.Fatal(err)
+		}
+		tree.Canonicalize()
+		got := tree.NewickString()
+		if k == 0 {
+			want = got
+		} else if got != want {
+			t.Errorf("taxa in the order %v: %s, want %s", permuted, got, want)
+		}
+	}
+}
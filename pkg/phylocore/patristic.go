@@ -0,0 +1,148 @@
+package phylocore
+
+import (
+	"cmp"
+	"errors"
+	"fmt"
+	"math"
+	"ncdtree/pkg/ncd"
+	"ncdtree/pkg/stats"
+	"slices"
+)
+
+// Tip of the subtree of a node, with the length of the path from the node
+type tipDistance struct {
+	taxonId  int
+	distance float64
+}
+
+/*
+Return the matrix of patristic distances between the tips of the tree, i.e. the sums of the lengths of the branches on
+the paths between tips. The rows of the matrix are the taxon IDs of the tips.
+
+Fails if a tip has no taxon, if two tips have the same taxon, if a taxon ID below the largest one has no tip, or if a
+branch has no length.
+*/
+func (tree *Tree) PatristicMatrix() (*ncd.TriangularMatrix, error) {
+	nbTaxa := 0
+	for _, node := range tree.Nodes {
+		if node.IsOuter() && node.TaxonId >= nbTaxa {
+			nbTaxa = node.TaxonId + 1
+		}
+	}
+	P := ncd.NewTriangularMatrix(nbTaxa)
+	hasTip := make([]bool, nbTaxa)
+
+	// Each pair of tips is set at the node where their paths to the root meet
+	tips := make(map[*Node][]tipDistance, len(tree.Nodes))
+	var err error
+	tree.TraverseNodes(func(node *Node) {
+		if err != nil {
+			return
+		}
+		if node.IsOuter() {
+			switch {
+			case node.TaxonId < 0:
+				err = fmt.Errorf("%s has no taxon", node)
+			case hasTip[node.TaxonId]:
+				err = fmt.Errorf("several tips for taxon %d", node.TaxonId)
+			default:
+				hasTip[node.TaxonId] = true
+				tips[node] = []tipDistance{{node.TaxonId, 0.0}}
+			}
+			return
+		}
+
+		merged := make([]tipDistance, 0)
+		for _, branch := range node.Out {
+			if math.IsNaN(branch.Length) {
+				err = fmt.Errorf("%s has no length", branch)
+				return
+			}
+			below := tips[branch.Child]
+			delete(tips, branch.Child)
+			for k := range below {
+				below[k].distance += branch.Length
+			}
+			for _, a := range merged {
+				for _, b := range below {
+					P.Set(a.taxonId, b.taxonId, a.distance+b.distance)
+				}
+			}
+			merged = append(merged, below...)
+		}
+		tips[node] = merged
+	}, PostOrder)
+	if err != nil {
+		return nil, err
+	}
+
+	for id, ok := range hasTip {
+		if !ok {
+			return nil, fmt.Errorf("no tip for taxon %d", id)
+		}
+	}
+
+	return P, nil
+}
+
+// Pair of taxa, with their distance in a matrix and on a tree
+type Residual struct {
+	I, J      int
+	Distance  float64
+	Patristic float64
+	Residual  float64 // Patristic - Distance
+}
+
+// Measures of how well the patristic distances of a tree fit a distance matrix
+type TreeFit struct {
+	CopheneticCorrelation float64    // Pearson correlation between the distances and the patristic distances
+	Stress                float64    // sqrt(sum (patristic - distance)² / sum distance²)
+	Residuals             []Residual // The largest residuals in absolute value, largest first
+}
+
+/*
+Compare the patristic distances of the tree with a distance matrix whose rows are the taxon IDs of the tips, and keep
+the maxResiduals largest residuals.
+*/
+func (tree *Tree) Fit(D *ncd.TriangularMatrix, maxResiduals int) (*TreeFit, error) {
+	P, err := tree.PatristicMatrix()
+	if err != nil {
+		return nil, err
+	}
+	if P.N != D.N {
+		return nil, fmt.Errorf("%w (%d rows for %d tips)", ErrMatrixSize, D.N, P.N)
+	}
+	if D.N < 3 {
+		return nil, fmt.Errorf("%w: at least 3 are needed to assess the fit of a tree, got %d", ErrTooFewTaxa, D.N)
+	}
+
+	r, err := stats.CorrPearson(&D.RawData, &P.RawData, false)
+	if err != nil {
+		return nil, err
+	}
+
+	var sumSquaredResiduals, sumSquaredDistances float64
+	residuals := make([]Residual, 0, len(D.RawData))
+	for i := range D.N {
+		for j := range i {
+			d, p := D.Get(i, j), P.Get(i, j)
+			sumSquaredResiduals += (p - d) * (p - d)
+			sumSquaredDistances += d * d
+			residuals = append(residuals, Residual{i, j, d, p, p - d})
+		}
+	}
+	if sumSquaredDistances == 0.0 {
+		return nil, errors.New("all the distances are zero")
+	}
+
+	slices.SortStableFunc(residuals, func(a, b Residual) int {
+		return cmp.Compare(math.Abs(b.Residual), math.Abs(a.Residual))
+	})
+
+	return &TreeFit{
+		CopheneticCorrelation: r,
+		Stress:                math.Sqrt(sumSquaredResiduals / sumSquaredDistances),
+		Residuals:             residuals[:min(maxResiduals, len(residuals))],
+	}, nil
+}
@@ -0,0 +1,80 @@
+package phylocore
+
+import (
+	"math"
+	"ncdtree/pkg/ncd"
+	"testing"
+)
+
+func TestPatristicMatrix(t *testing.T) {
+	tree, taxset, err := readNewickString("((a:1,b:2):1,(c:1,d:3):2,e:1);")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	P, err := tree.PatristicMatrix()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if P.N != 5 {
+		t.Fatalf("expected 5 rows, got %d", P.N)
+	}
+
+	want := map[[2]string]float64{
+		{"a", "b"}: 3, {"a", "c"}: 5, {"a", "d"}: 7, {"a", "e"}: 3, {"b", "c"}: 6,
+		{"b", "d"}: 8, {"b", "e"}: 4, {"c", "d"}: 4, {"c", "e"}: 4, {"d", "e"}: 6,
+	}
+	for pair, d := range want {
+		i, _ := taxset.GetId(pair[0])
+		j, _ := taxset.GetId(pair[1])
+		if got := P.Get(i, j); math.Abs(got-d) > 1e-12 {
+			t.Errorf("patristic distance %s-%s = %v, want %v", pair[0], pair[1], got, d)
+		}
+	}
+
+	noLength, _, _ := readNewickString("((a,b),c);")
+	if _, err := noLength.PatristicMatrix(); err == nil {
+		t.Error("expected an error for branches without length")
+	}
+}
+
+func TestTreeFit(t *testing.T) {
+	// Additive distances are fitted exactly by neighbour-joining
+	tree, taxset, _ := readNewickString("((a:1,b:2):1,(c:1,d:3):2,e:1);")
+	D, _ := tree.PatristicMatrix()
+	njTree, err := NeighbourJoining(taxset, D.Copy())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	fit, err := njTree.Fit(D, 3)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if math.Abs(fit.CopheneticCorrelation-1.0) > 1e-9 || fit.Stress > 1e-9 {
+		t.Errorf("fit of an additive matrix: r = %v, stress = %v", fit.CopheneticCorrelation, fit.Stress)
+	}
+	if len(fit.Residuals) != 3 {
+		t.Errorf("expected 3 residuals, got %d", len(fit.Residuals))
+	}
+
+	// Lengthen one distance: its pair has the largest residual
+	i, _ := taxset.GetId("a")
+	j, _ := taxset.GetId("d")
+	D.Set(i, j, D.Get(i, j)+2)
+	fit, err = tree.Fit(D, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	r := fit.Residuals[0]
+	if !((r.I == i && r.J == j) || (r.I == j && r.J == i)) || math.Abs(r.Residual+2) > 1e-12 {
+		t.Errorf("largest residual %+v, want -2 for the pair (%d, %d)", r, i, j)
+	}
+	if fit.Stress <= 0 || fit.CopheneticCorrelation >= 1 {
+		t.Errorf("fit of a perturbed matrix: r = %v, stress = %v", fit.CopheneticCorrelation, fit.Stress)
+	}
+
+	if _, err := tree.Fit(ncd.NewTriangularMatrix(4), 1); err == nil {
+		t.Error("expected an error for a matrix of another size")
+	}
+}
@@ -0,0 +1,192 @@
+package phylocore
+
+import (
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"slices"
+	"strings"
+)
+
+const (
+	phyloXMLNamespace = "http://www.phyloxml.org"
+	phyloXMLSchema    = "http://www.phyloxml.org http://www.phyloxml.org/1.20/phyloxml.xsd"
+
+	// Prefix for property references without one, as the PhyloXML schema requires a "prefix:name" form
+	propertyPrefix = "ncdtree:"
+)
+
+type phyloXMLDocument struct {
+	XMLName        xml.Name            `xml:"phyloxml"`
+	Xmlns          string              `xml:"xmlns,attr,omitempty"`
+	XmlnsXsi       string              `xml:"xmlns:xsi,attr,omitempty"`
+	SchemaLocation string              `xml:"xsi:schemaLocation,attr,omitempty"`
+	Phylogenies    []phyloXMLPhylogeny `xml:"phylogeny"`
+}
+
+type phyloXMLPhylogeny struct {
+	Rooted bool           `xml:"rooted,attr"`
+	Clade  *phyloXMLClade `xml:"clade"`
+}
+
+type phyloXMLClade struct {
+	BranchLengthAttr *float64             `xml:"branch_length,attr"`
+	Name             string               `xml:"name,omitempty"`
+	BranchLength     *float64             `xml:"branch_length,omitempty"`
+	Confidences      []phyloXMLConfidence `xml:"confidence"`
+	Taxonomy         *phyloXMLTaxonomy    `xml:"taxonomy"`
+	Properties       []phyloXMLProperty   `xml:"property"`
+	Clades           []*phyloXMLClade     `xml:"clade"`
+}
+
+type phyloXMLConfidence struct {
+	Type  string  `xml:"type,attr"`
+	Value float64 `xml:",chardata"`
+}
+
+type phyloXMLTaxonomy struct {
+	Code           string `xml:"code,omitempty"`
+	ScientificName string `xml:"scientific_name,omitempty"`
+}
+
+type phyloXMLProperty struct {
+	Ref       string `xml:"ref,attr"`
+	Datatype  string `xml:"datatype,attr"`
+	AppliesTo string `xml:"applies_to,attr"`
+	Value     string `xml:",chardata"`
+}
+
+func makePhyloXMLClade(node *Node) *phyloXMLClade {
+	clade := &phyloXMLClade{Name: node.Label}
+
+	if node.In != nil && !math.IsNaN(node.In.Length) {
+		length := node.In.Length
+		clade.BranchLength = &length
+	}
+
+	if !math.IsNaN(node.Support) {
+		clade.Confidences = []phyloXMLConfidence{{Type: "support", Value: node.Support}}
+	}
+
+	keys := make([]string, 0, len(node.Properties))
+	for key := range node.Properties {
+		keys = append(keys, key)
+	}
+	slices.Sort(keys)
+
+	for _, key := range keys {
+		ref := key
+		if !strings.Contains(ref, ":") {
+			ref = propertyPrefix + ref
+		}
+		clade.Properties = append(clade.Properties, phyloXMLProperty{
+			Ref:       ref,
+			Datatype:  "xsd:string",
+			AppliesTo: "clade",
+			Value:     node.Properties[key],
+		})
+	}
+
+	for _, branch := range node.Out {
+		clade.Clades = append(clade.Clades, makePhyloXMLClade(branch.Child))
+	}
+
+	return clade
+}
+
+/*
+Write the tree as a PhyloXML document.
+
+The tree is declared rooted when the root is bifurcating. Support values are written as confidence elements, and node
+properties as property elements.
+
+Reference for the PhyloXML format: http://www.phyloxml.org
+*/
+func (tree *Tree) WritePhyloXML(w io.Writer) error {
+	doc := phyloXMLDocument{
+		Xmlns:          phyloXMLNamespace,
+		XmlnsXsi:       xmlSchemaInstanceNamespace,
+		SchemaLocation: phyloXMLSchema,
+		Phylogenies: []phyloXMLPhylogeny{
+			{Rooted: tree.Root.OutDegree() == 2, Clade: makePhyloXMLClade(tree.Root)},
+		},
+	}
+
+	return writeXMLDocument(w, doc)
+}
+
+func (ctx *treeParseContext) buildPhyloXMLClade(clade *phyloXMLClade, node *Node) {
+	for _, childClade := range clade.Clades {
+		child := ctx.tree.NewNode()
+		branch := ctx.tree.NewBranch()
+		node.AddChild(child, branch)
+
+		if childClade.BranchLength != nil {
+			branch.Length = *childClade.BranchLength
+		} else if childClade.BranchLengthAttr != nil {
+			branch.Length = *childClade.BranchLengthAttr
+		}
+
+		ctx.buildPhyloXMLClade(childClade, child)
+	}
+
+	node.Label = clade.Name
+	if node.Label == "" && clade.Taxonomy != nil {
+		if clade.Taxonomy.ScientificName != "" {
+			node.Label = clade.Taxonomy.ScientificName
+		} else {
+			node.Label = clade.Taxonomy.Code
+		}
+	}
+
+	if len(clade.Confidences) > 0 {
+		node.Support = clade.Confidences[0].Value
+	}
+
+	for _, property := range clade.Properties {
+		node.SetProperty(strings.TrimPrefix(property.Ref, propertyPrefix), property.Value)
+	}
+
+	if node.Label != "" {
+		ctx.setTaxon(node)
+	}
+}
+
+func (taxset *TaxonSet) parsePhyloXML(r io.Reader, addNew bool) (tree *Tree, err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			err = fmt.Errorf("phyloxml: %v", r)
+		}
+	}()
+
+	var doc phyloXMLDocument
+	if err := xml.NewDecoder(r).Decode(&doc); err != nil {
+		return nil, fmt.Errorf("phyloxml: %w", err)
+	}
+	if len(doc.Phylogenies) == 0 || doc.Phylogenies[0].Clade == nil {
+		return nil, errors.New("phyloxml: no phylogeny found")
+	}
+
+	tree = NewEmptyTree(0)
+	ctx := treeParseContext{taxset, tree, OuterNodes, addNew}
+	tree.Root = tree.NewNode()
+	ctx.buildPhyloXMLClade(doc.Phylogenies[0].Clade, tree.Root)
+
+	return tree, nil
+}
+
+// Read the first phylogeny of a PhyloXML document and return a tree with a matching taxon set
+func ReadPhyloXML(r io.Reader) (*Tree, *TaxonSet, error) {
+	taxset, _ := NewTaxonSet(make([]string, 0))
+	tree, err := taxset.parsePhyloXML(r, true)
+
+	return tree, taxset, err
+}
+
+// Read the first phylogeny of a PhyloXML document and return a tree with taxa matching a given taxon set, optionally
+// adding new taxa
+func (taxset *TaxonSet) ReadPhyloXML(r io.Reader, addNew bool) (*Tree, error) {
+	return taxset.parsePhyloXML(r, addNew)
+}
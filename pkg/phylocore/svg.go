@@ -0,0 +1,479 @@
+package phylocore
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"html"
+	"io"
+	"math"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+)
+
+/*========================================================================
+	SVG RENDERING OF TREES
+········································································*/
+
+// Shape of the drawing of a tree in SVG
+type TreeLayout int
+
+const (
+	LayoutRectangular TreeLayout = iota
+	LayoutCircular
+)
+
+// Names of the tree layouts, in the order of the TreeLayout constants
+var TreeLayoutNames = []string{"rectangular", "circular"}
+
+func (layout TreeLayout) String() string {
+	return TreeLayoutNames[layout]
+}
+
+// Get the tree layout that matches a name in TreeLayoutNames
+func ParseTreeLayout(name string) (TreeLayout, error) {
+	for i, s := range TreeLayoutNames {
+		if s == name {
+			return TreeLayout(i), nil
+		}
+	}
+
+	return LayoutRectangular, fmt.Errorf("unknown tree layout \"%s\"", name)
+}
+
+// Clade drawn over a coloured background: the smallest clade that contains the tips labelled with the taxa
+type CladeHighlight struct {
+	Name   string
+	Colour string // Any CSS colour, e.g. "#1f77b4" or "orange"
+	Taxa   []string
+}
+
+/*
+Read clades to highlight from a tab-separated table, with one clade per line: its name, its colour, and the labels of
+one or more of its tips in the following columns. Empty lines and lines that start with "#" are ignored.
+*/
+func ReadCladeHighlights(r io.Reader) ([]CladeHighlight, error) {
+	highlights := make([]CladeHighlight, 0)
+	scanner := bufio.NewScanner(r)
+	lineNo := 0
+
+	for scanner.Scan() {
+		lineNo += 1
+		line := strings.TrimRight(scanner.Text(), "\r")
+		if strings.TrimSpace(line) == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+
+		fields := strings.Split(line, "\t")
+		if len(fields) < 3 || fields[0] == "" || fields[1] == "" {
+			return nil, fmt.Errorf("expected a clade name, a colour and tip labels in line %d of the highlight table", lineNo)
+		}
+		taxa := make([]string, 0, len(fields)-2)
+		for _, taxon := range fields[2:] {
+			if taxon != "" {
+				taxa = append(taxa, taxon)
+			}
+		}
+		if len(taxa) == 0 {
+			return nil, fmt.Errorf("no tip labels in line %d of the highlight table", lineNo)
+		}
+		highlights = append(highlights, CladeHighlight{fields[0], fields[1], taxa})
+	}
+
+	return highlights, scanner.Err()
+}
+
+// Options of the drawing of a tree with WriteSVG
+type SVGOptions struct {
+	Layout     TreeLayout
+	Width      float64 // Width of the figure in pixels, enlarged if the labels are too long for the branches to fit
+	FontSize   float64 // Size of the tip labels in pixels
+	Cladogram  bool    // Ignore the branch lengths and align the tips
+	AlignTips  bool    // Align the tip labels, joined to their tips by dotted lines
+	Support    bool    // Write the support values of the clades next to their nodes
+	Ladderize  bool    // Draw the children of each node from the smallest to the largest clade
+	Highlights []CladeHighlight
+}
+
+const (
+	DefaultSVGWidth    = 800.0
+	DefaultSVGFontSize = 12.0
+
+	svgCharWidth    = 0.6     // Estimated width of a character of the labels, relative to the font size
+	svgLineHeight   = 1.5     // Distance between tips of rectangular layouts, relative to the font size
+	svgMinTreeShare = 1.0 / 3 // Smallest share of the width taken by the branches (by the radius in circular layouts)
+)
+
+// Positions of the nodes of a tree in a drawing, independent of its layout
+type treePlot struct {
+	tips      []*Node           // In drawing order
+	inner     []*Node           // In pre-order
+	slot      map[*Node]float64 // Index of the tips, halfway between the first and last children for inner nodes
+	firstTip  map[*Node]int     // Range of the indices of the tips of the clade of each node
+	lastTip   map[*Node]int
+	depth     map[*Node]float64 // Distance from the root, or number of levels above the tips for cladograms
+	maxDepth  float64
+	cladogram bool
+	children  map[*Node][]*Branch
+}
+
+// Place the nodes of a tree, drawn as a cladogram if asked or if a branch has no length
+func newTreePlot(tree *Tree, cladogram bool, ladderize bool) *treePlot {
+	nbTips := make(map[*Node]int, len(tree.Nodes))
+	height := make(map[*Node]int, len(tree.Nodes))
+	tree.TraverseNodes(func(node *Node) {
+		if node.IsOuter() {
+			nbTips[node] = 1
+			return
+		}
+		for _, branch := range node.Out {
+			nbTips[node] += nbTips[branch.Child]
+			height[node] = max(height[node], height[branch.Child]+1)
+			if math.IsNaN(branch.Length) {
+				cladogram = true
+			}
+		}
+	}, PostOrder)
+
+	plot := &treePlot{
+		tips:     make([]*Node, 0, nbTips[tree.Root]),
+		slot:     make(map[*Node]float64, len(tree.Nodes)),
+		firstTip: make(map[*Node]int, len(tree.Nodes)),
+		lastTip:  make(map[*Node]int, len(tree.Nodes)),
+		depth:    make(map[*Node]float64, len(tree.Nodes)),
+		children: make(map[*Node][]*Branch, len(tree.Nodes)),
+	}
+
+	var place func(node *Node)
+	place = func(node *Node) {
+		if node.IsOuter() {
+			plot.firstTip[node] = len(plot.tips)
+			plot.lastTip[node] = len(plot.tips)
+			plot.slot[node] = float64(len(plot.tips))
+			plot.tips = append(plot.tips, node)
+			return
+		}
+		children := drawnChildren(node, nbTips, ladderize)
+		plot.children[node] = children
+		plot.inner = append(plot.inner, node)
+		for _, branch := range children {
+			place(branch.Child)
+		}
+		first, last := children[0].Child, children[len(children)-1].Child
+		plot.firstTip[node] = plot.firstTip[first]
+		plot.lastTip[node] = plot.lastTip[last]
+		plot.slot[node] = (plot.slot[first] + plot.slot[last]) / 2
+	}
+	place(tree.Root)
+
+	tree.TraverseNodes(func(node *Node) {
+		if node.In != nil {
+			plot.depth[node] = plot.depth[node.In.Parent] + max(node.In.Length, 0.0)
+		}
+		plot.maxDepth = max(plot.maxDepth, plot.depth[node])
+	}, PreOrder)
+	plot.cladogram = cladogram || plot.maxDepth == 0.0
+	if plot.cladogram {
+		for node := range plot.slot {
+			plot.depth[node] = float64(height[tree.Root] - height[node])
+		}
+		plot.maxDepth = float64(height[tree.Root])
+	}
+
+	return plot
+}
+
+// Return the node of the smallest clade that contains the tips labelled with the given taxa
+func (plot *treePlot) findClade(h CladeHighlight) (*Node, error) {
+	var clade *Node
+	for _, taxon := range h.Taxa {
+		var tip *Node
+		for _, node := range plot.tips {
+			if node.Label == taxon {
+				tip = node
+				break
+			}
+		}
+		if tip == nil {
+			return nil, fmt.Errorf("clade \"%s\": %w: \"%s\"", h.Name, ErrTaxonNotInTree, taxon)
+		}
+		if clade == nil {
+			clade = tip
+			continue
+		}
+
+		// Go up from the clade until it contains the tip
+		for plot.firstTip[clade] > plot.firstTip[tip] || plot.lastTip[clade] < plot.lastTip[tip] {
+			clade = clade.In.Parent
+		}
+	}
+
+	return clade, nil
+}
+
+// Format a coordinate of the SVG figure
+func svgNum(x float64) string {
+	return strconv.FormatFloat(x, 'f', 2, 64)
+}
+
+// Estimate the width in pixels of a text
+func svgTextWidth(s string, fontSize float64) float64 {
+	return float64(utf8.RuneCountInString(s)) * svgCharWidth * fontSize
+}
+
+// Builder of an SVG document, which keeps the first error that occurs
+type svgWriter struct {
+	w   io.Writer
+	err error
+}
+
+func (sw *svgWriter) printf(format string, args ...any) {
+	if sw.err == nil {
+		_, sw.err = fmt.Fprintf(sw.w, format, args...)
+	}
+}
+
+func (sw *svgWriter) text(x float64, y float64, s string, attributes string) {
+	sw.printf("<text x=\"%s\" y=\"%s\" dy=\"0.35em\"%s>%s</text>\n", svgNum(x), svgNum(y), attributes, html.EscapeString(s))
+}
+
+/*
+Draw the tree as an SVG figure, from the root on the left to the tips on the right (rectangular layout) or from the
+root in the centre to the tips around it (circular layout).
+
+Phylograms (the default) draw the branches in proportion to their lengths, with a scale bar below the tree. Negative
+lengths are drawn as 0, and the tree is drawn as a cladogram if a branch has no length. The widths of the labels are
+estimated from their numbers of characters, as the fonts of the viewer are unknown. Fails with ErrTaxonNotInTree if a
+tip of a highlighted clade is not in the tree.
+*/
+func (tree *Tree) WriteSVG(w io.Writer, opts SVGOptions) error {
+	if tree.Root == nil {
+		return errors.New("the tree has no root")
+	}
+	width := opts.Width
+	if width <= 0 {
+		width = DefaultSVGWidth
+	}
+	fontSize := opts.FontSize
+	if fontSize <= 0 {
+		fontSize = DefaultSVGFontSize
+	}
+
+	plot := newTreePlot(tree, opts.Cladogram, opts.Ladderize)
+	clades := make([]*Node, len(opts.Highlights))
+	for i, h := range opts.Highlights {
+		clade, err := plot.findClade(h)
+		if err != nil {
+			return err
+		}
+		clades[i] = clade
+	}
+
+	labelWidth := 0.0
+	for _, tip := range plot.tips {
+		labelWidth = max(labelWidth, svgTextWidth(tip.Label, fontSize))
+	}
+	labelWidth += fontSize / 2
+	cladeLabelWidth := 0.0
+	for _, h := range opts.Highlights {
+		cladeLabelWidth = max(cladeLabelWidth, svgTextWidth(h.Name, fontSize)+fontSize)
+	}
+
+	sw := &svgWriter{w: w}
+	if opts.Layout == LayoutCircular {
+		writeCircularSVG(sw, plot, clades, opts, width, fontSize, labelWidth, cladeLabelWidth)
+	} else {
+		writeRectangularSVG(sw, plot, clades, opts, width, fontSize, labelWidth, cladeLabelWidth)
+	}
+	sw.printf("</svg>\n")
+
+	return sw.err
+}
+
+func writeSVGHeader(sw *svgWriter, width float64, height float64, fontSize float64) {
+	sw.printf("<?xml version=\"1.0\" encoding=\"UTF-8\"?>\n")
+	sw.printf("<svg xmlns=\"http://www.w3.org/2000/svg\" width=\"%s\" height=\"%s\" viewBox=\"0 0 %s %s\" font-family=\"sans-serif\" font-size=\"%s\">\n",
+		svgNum(width), svgNum(height), svgNum(width), svgNum(height), svgNum(fontSize))
+	sw.printf("<rect width=\"100%%\" height=\"100%%\" fill=\"white\"/>\n")
+}
+
+// Draw a scale bar of about a fifth of the size of the tree, whose left end is at (x, y)
+func writeSVGScaleBar(sw *svgWriter, x float64, y float64, scale float64, treeSize float64) {
+	length := roundScaleLength(treeSize / 5 / scale)
+	sw.printf("<path d=\"M %s %s h %s\" stroke=\"black\" stroke-width=\"1.5\"/>\n", svgNum(x), svgNum(y), svgNum(length*scale))
+	sw.text(x+length*scale+4, y, formatTextPlotValue(length), "")
+}
+
+func writeRectangularSVG(sw *svgWriter, plot *treePlot, clades []*Node, opts SVGOptions, width float64, fontSize float64, labelWidth float64, cladeLabelWidth float64) {
+	margin := fontSize
+	lineHeight := svgLineHeight * fontSize
+	treeWidth := max(width-2*margin-labelWidth-cladeLabelWidth, svgMinTreeShare*width)
+	width = 2*margin + treeWidth + labelWidth + cladeLabelWidth
+	scale := 0.0
+	if plot.maxDepth > 0 {
+		scale = treeWidth / plot.maxDepth
+	}
+	height := 2*margin + float64(len(plot.tips))*lineHeight
+	if !plot.cladogram {
+		height += 2 * lineHeight
+	}
+	x := func(node *Node) float64 { return margin + plot.depth[node]*scale }
+	y := func(slot float64) float64 { return margin + (slot+0.5)*lineHeight }
+
+	writeSVGHeader(sw, width, height, fontSize)
+
+	// Highlights, from the middle of the stem of the clade to the clade names
+	for i, clade := range clades {
+		h := opts.Highlights[i]
+		left := x(clade) - fontSize/2
+		if clade.In != nil {
+			left = (x(clade.In.Parent) + x(clade)) / 2
+		}
+		top := y(float64(plot.firstTip[clade])) - lineHeight/2
+		bottom := y(float64(plot.lastTip[clade])) + lineHeight/2
+		right := margin + treeWidth + labelWidth + cladeLabelWidth
+		sw.printf("<rect x=\"%s\" y=\"%s\" width=\"%s\" height=\"%s\" fill=\"%s\" fill-opacity=\"0.25\"/>\n",
+			svgNum(left), svgNum(top), svgNum(right-left), svgNum(bottom-top), html.EscapeString(h.Colour))
+		sw.text(margin+treeWidth+labelWidth+fontSize/2, (top+bottom)/2, h.Name, " font-weight=\"bold\"")
+	}
+
+	// Branches: a vertical line through the children of each inner node, and a horizontal line to each child
+	var d strings.Builder
+	for _, node := range plot.inner {
+		children := plot.children[node]
+		fmt.Fprintf(&d, "M %s %s V %s ", svgNum(x(node)), svgNum(y(plot.slot[children[0].Child])), svgNum(y(plot.slot[children[len(children)-1].Child])))
+		for _, branch := range children {
+			fmt.Fprintf(&d, "M %s %s H %s ", svgNum(x(node)), svgNum(y(plot.slot[branch.Child])), svgNum(x(branch.Child)))
+		}
+	}
+	sw.printf("<path d=\"%s\" fill=\"none\" stroke=\"black\" stroke-width=\"1.5\" stroke-linecap=\"square\"/>\n", strings.TrimSpace(d.String()))
+
+	alignX := margin + treeWidth
+	for _, tip := range plot.tips {
+		labelX := x(tip)
+		if opts.AlignTips {
+			if alignX-labelX > 1 {
+				sw.printf("<path d=\"M %s %s H %s\" stroke=\"gray\" stroke-dasharray=\"2 2\"/>\n", svgNum(labelX+2), svgNum(y(plot.slot[tip])), svgNum(alignX))
+			}
+			labelX = alignX
+		}
+		sw.text(labelX+fontSize/3, y(plot.slot[tip]), tip.Label, "")
+	}
+
+	if opts.Support {
+		for _, node := range plot.inner {
+			if !math.IsNaN(node.Support) {
+				sw.text(x(node)-2, y(plot.slot[node])-fontSize/2, formatTextPlotValue(node.Support),
+					fmt.Sprintf(" font-size=\"%s\" text-anchor=\"end\" fill=\"dimgray\"", svgNum(0.75*fontSize)))
+			}
+		}
+	}
+
+	if !plot.cladogram {
+		writeSVGScaleBar(sw, margin, height-margin-lineHeight/2, scale, treeWidth)
+	}
+}
+
+func writeCircularSVG(sw *svgWriter, plot *treePlot, clades []*Node, opts SVGOptions, width float64, fontSize float64, labelWidth float64, cladeLabelWidth float64) {
+	margin := fontSize
+	radius := max(width/2-margin-labelWidth-cladeLabelWidth, svgMinTreeShare*width/2)
+	width = 2 * (margin + radius + labelWidth + cladeLabelWidth)
+	centre := width / 2
+	scale := 0.0
+	if plot.maxDepth > 0 {
+		scale = radius / plot.maxDepth
+	}
+	height := width
+	if !plot.cladogram {
+		height += 2 * svgLineHeight * fontSize
+	}
+
+	step := 2 * math.Pi / float64(len(plot.tips)) // Angle between tips
+	r := func(node *Node) float64 { return plot.depth[node] * scale }
+	angle := func(slot float64) float64 { return slot * step }
+	point := func(r float64, a float64) string {
+		return svgNum(centre+r*math.Cos(a)) + " " + svgNum(centre+r*math.Sin(a))
+	}
+	// Text along a radius, kept upright on the left half of the circle
+	radialText := func(rText float64, a float64, s string, attributes string) {
+		degrees := a * 180 / math.Pi
+		anchor := "start"
+		if math.Cos(a) < 0 {
+			degrees += 180
+			anchor = "end"
+		}
+		tx, ty := centre+rText*math.Cos(a), centre+rText*math.Sin(a)
+		sw.text(tx, ty, s, fmt.Sprintf(" text-anchor=\"%s\" transform=\"rotate(%s %s %s)\"%s", anchor, svgNum(degrees), svgNum(tx), svgNum(ty), attributes))
+	}
+
+	writeSVGHeader(sw, width, height, fontSize)
+
+	// Highlights, as sectors of rings from the middle of the stem of the clade to the clade names
+	for i, clade := range clades {
+		h := opts.Highlights[i]
+		inner := 0.0
+		if clade.In != nil {
+			inner = (r(clade.In.Parent) + r(clade)) / 2
+		}
+		outer := radius + labelWidth
+		start := angle(float64(plot.firstTip[clade]) - 0.5)
+		end := angle(float64(plot.lastTip[clade]) + 0.5)
+		end = min(end, start+2*math.Pi-1e-6) // A closed arc would not be drawn
+		large := 0
+		if end-start > math.Pi {
+			large = 1
+		}
+		sw.printf("<path d=\"M %s A %s %s 0 %d 1 %s L %s A %s %s 0 %d 0 %s Z\" fill=\"%s\" fill-opacity=\"0.25\"/>\n",
+			point(outer, start), svgNum(outer), svgNum(outer), large, point(outer, end),
+			point(inner, end), svgNum(inner), svgNum(inner), large, point(inner, start),
+			html.EscapeString(h.Colour))
+		radialText(outer+fontSize/2, (start+end)/2, h.Name, " font-weight=\"bold\"")
+	}
+
+	// Branches: an arc through the children of each inner node, and a radial line to each child
+	var d strings.Builder
+	for _, node := range plot.inner {
+		children := plot.children[node]
+		rNode := r(node)
+		first, last := angle(plot.slot[children[0].Child]), angle(plot.slot[children[len(children)-1].Child])
+		if rNode > 0 && last > first {
+			large := 0
+			if last-first > math.Pi {
+				large = 1
+			}
+			fmt.Fprintf(&d, "M %s A %s %s 0 %d 1 %s ", point(rNode, first), svgNum(rNode), svgNum(rNode), large, point(rNode, last))
+		}
+		for _, branch := range children {
+			a := angle(plot.slot[branch.Child])
+			fmt.Fprintf(&d, "M %s L %s ", point(rNode, a), point(r(branch.Child), a))
+		}
+	}
+	sw.printf("<path d=\"%s\" fill=\"none\" stroke=\"black\" stroke-width=\"1.5\" stroke-linecap=\"round\"/>\n", strings.TrimSpace(d.String()))
+
+	for _, tip := range plot.tips {
+		a := angle(plot.slot[tip])
+		labelR := r(tip)
+		if opts.AlignTips {
+			if radius-labelR > 1 {
+				sw.printf("<path d=\"M %s L %s\" stroke=\"gray\" stroke-dasharray=\"2 2\"/>\n", point(labelR+2, a), point(radius, a))
+			}
+			labelR = radius
+		}
+		radialText(labelR+fontSize/3, a, tip.Label, "")
+	}
+
+	if opts.Support {
+		for _, node := range plot.inner {
+			if !math.IsNaN(node.Support) {
+				a := angle(plot.slot[node])
+				sw.text(centre+r(node)*math.Cos(a)-2, centre+r(node)*math.Sin(a)-fontSize/2, formatTextPlotValue(node.Support),
+					fmt.Sprintf(" font-size=\"%s\" text-anchor=\"end\" fill=\"dimgray\"", svgNum(0.75*fontSize)))
+			}
+		}
+	}
+
+	if !plot.cladogram {
+		writeSVGScaleBar(sw, margin, height-margin-svgLineHeight*fontSize/2, scale, radius)
+	}
+}
@@ -0,0 +1,129 @@
+package phylocore
+
+import (
+	"encoding/xml"
+	"errors"
+	"io"
+	"strings"
+	"testing"
+)
+
+func TestReadCladeHighlights(t *testing.T) {
+	table := "# clade\tcolour\ttaxa\n\nab\t#1f77b4\ta\tb\ne\torange\te\t\n"
+	highlights, err := ReadCladeHighlights(strings.NewReader(table))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(highlights) != 2 {
+		t.Fatalf("expected 2 clades, got %d", len(highlights))
+	}
+	if h := highlights[0]; h.Name != "ab" || h.Colour != "#1f77b4" || len(h.Taxa) != 2 || h.Taxa[1] != "b" {
+		t.Errorf("first clade: %+v", h)
+	}
+	if h := highlights[1]; len(h.Taxa) != 1 || h.Taxa[0] != "e" {
+		t.Errorf("second clade: %+v", h)
+	}
+
+	for _, bad := range []string{"ab\t#1f77b4\n", "\tred\ta\n", "ab\tred\t\t\n"} {
+		if _, err := ReadCladeHighlights(strings.NewReader(bad)); err == nil {
+			t.Errorf("expected an error for %q", bad)
+		}
+	}
+}
+
+func TestFindClade(t *testing.T) {
+	tree, _, _ := readNewickString(textPlotNewick)
+	nodes := nodesByLabel(tree)
+	plot := newTreePlot(tree, false, true)
+
+	tests := []struct {
+		taxa []string
+		want *Node
+	}{
+		{[]string{"d", "f"}, nodes["df"]},
+		{[]string{"f", "c"}, nodes["df"].In.Parent},
+		{[]string{"e"}, nodes["e"]},
+		{[]string{"a", "e"}, tree.Root},
+	}
+	for _, tt := range tests {
+		clade, err := plot.findClade(CladeHighlight{"x", "red", tt.taxa})
+		if err != nil || clade != tt.want {
+			t.Errorf("clade of %v: %v, %v, want %v", tt.taxa, clade, err, tt.want)
+		}
+	}
+
+	if _, err := plot.findClade(CladeHighlight{"x", "red", []string{"a", "z"}}); err == nil {
+		t.Error("expected an error for a taxon that is not in the tree")
+	}
+}
+
+// Count the elements of an SVG document by name, failing if it is not well-formed XML
+func countSVGElements(t *testing.T, svg string) map[string]int {
+	t.Helper()
+	counts := make(map[string]int)
+	decoder := xml.NewDecoder(strings.NewReader(svg))
+	for {
+		token, err := decoder.Token()
+		if errors.Is(err, io.EOF) {
+			return counts
+		}
+		if err != nil {
+			t.Fatalf("invalid SVG: %v\n%s", err, svg)
+		}
+		if start, ok := token.(xml.StartElement); ok {
+			counts[start.Name.Local] += 1
+		}
+	}
+}
+
+func TestWriteSVG(t *testing.T) {
+	tree, _, _ := readNewickString(textPlotNewick)
+	nodes := nodesByLabel(tree)
+	nodes["df"].Support = 75
+	nodes["e"].Label = "e <&> e"
+	highlights := []CladeHighlight{{"DF", "orange", []string{"d", "f"}}}
+
+	for _, layout := range []TreeLayout{LayoutRectangular, LayoutCircular} {
+		var b strings.Builder
+		opts := SVGOptions{Layout: layout, AlignTips: true, Support: true, Highlights: highlights}
+		if err := tree.WriteSVG(&b, opts); err != nil {
+			t.Fatal(err)
+		}
+		svg := b.String()
+		counts := countSVGElements(t, svg)
+
+		// Labels of the 6 tips, of the clade, of the support value and of the scale bar
+		if counts["text"] != 9 {
+			t.Errorf("%s layout: %d text elements, want 9", layout, counts["text"])
+		}
+		if !strings.Contains(svg, `fill="orange"`) || !strings.Contains(svg, "e &lt;&amp;&gt; e") {
+			t.Errorf("%s layout: missing highlight or escaped label:\n%s", layout, svg)
+		}
+	}
+
+	// Without lengths, no scale bar
+	cladogram, _, _ := readNewickString("((a,b),c);")
+	var b strings.Builder
+	if err := cladogram.WriteSVG(&b, SVGOptions{}); err != nil {
+		t.Fatal(err)
+	}
+	if counts := countSVGElements(t, b.String()); counts["text"] != 3 {
+		t.Errorf("cladogram: %d text elements, want 3", counts["text"])
+	}
+
+	if err := tree.WriteSVG(io.Discard, SVGOptions{Highlights: []CladeHighlight{{"x", "red", []string{"z"}}}}); !errors.Is(err, ErrTaxonNotInTree) {
+		t.Errorf("expected ErrTaxonNotInTree for a highlighted taxon that is not in the tree, got %v", err)
+	}
+}
+
+func TestParseTreeLayout(t *testing.T) {
+	for i, name := range TreeLayoutNames {
+		layout, err := ParseTreeLayout(name)
+		if err != nil || layout != TreeLayout(i) || layout.String() != name {
+			t.Errorf("ParseTreeLayout(%q) = %v, %v", name, layout, err)
+		}
+	}
+	if _, err := ParseTreeLayout("radial"); err == nil {
+		t.Error("expected an error for an unknown layout")
+	}
+}
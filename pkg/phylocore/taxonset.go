@@ -1,7 +1,6 @@
 package phylocore
 
 import (
-	"errors"
 	"fmt"
 )
 
@@ -11,6 +10,7 @@ A structure for handling a list of taxa represented by unique names associated t
 type TaxonSet struct {
 	nameMap map[string]int
 	Names   []string
+	aliases map[string]int // Other names of taxa, recognized when reading trees
 }
 
 func (taxset TaxonSet) String() string {
@@ -26,27 +26,25 @@ func NewTaxonSet(nameList []string) (*TaxonSet, error) {
 		if !err {
 			nameMap[name] = i
 		} else {
-			return nil, errors.New("duplicate name \"" + name + "\"")
+			return nil, fmt.Errorf("%w \"%s\"", ErrDuplicateName, name)
 		}
 	}
 
-	taxset := TaxonSet{nameMap, nameList}
+	taxset := TaxonSet{nameMap, nameList, nil}
 
 	return &taxset, nil
 }
 
-// Add a new taxon to the taxon set and return its ID.
-func (taxset *TaxonSet) NewTaxon(name string) int {
+// Add a new taxon to the taxon set and return its ID. Fails with ErrDuplicateName if the name is already used.
+func (taxset *TaxonSet) NewTaxon(name string) (int, error) {
 	i := len(taxset.Names)
-	_, err := taxset.nameMap[name]
-	if !err {
-		taxset.nameMap[name] = i
-		taxset.Names = append(taxset.Names, name)
-	} else {
-		panic(fmt.Sprintf("duplicate name \"%s\"", name))
+	if _, ok := taxset.nameMap[name]; ok {
+		return -1, fmt.Errorf("%w \"%s\"", ErrDuplicateName, name)
 	}
+	taxset.nameMap[name] = i
+	taxset.Names = append(taxset.Names, name)
 
-	return i
+	return i, nil
 }
 
 // Get the name of a taxon by its numeric ID
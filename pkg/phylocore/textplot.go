@@ -0,0 +1,284 @@
+package phylocore
+
+import (
+	"cmp"
+	"errors"
+	"io"
+	"math"
+	"os"
+	"slices"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+)
+
+/*========================================================================
+	TEXT RENDERING OF TREES
+········································································*/
+
+// Options of the drawing of a tree with WriteText
+type TextPlotOptions struct {
+	Width     int  // Number of columns of the drawing, labels included (see TerminalWidth if 0)
+	Cladogram bool // Ignore the branch lengths and align the tips
+	Lengths   bool // Write the lengths on the branches that are long enough
+	Support   bool // Write the support values of the clades before their nodes
+	Ladderize bool // Draw the children of each node from the smallest to the largest clade
+}
+
+// Width of the drawings when the width of the terminal is unknown
+const DefaultTextWidth = 80
+
+// Narrowest drawing, whatever the width that is asked for
+const minTextWidth = 20
+
+/*
+Return the width of the terminal, as given by the COLUMNS environment variable (set by most shells), or
+DefaultTextWidth.
+*/
+func TerminalWidth() int {
+	if columns, err := strconv.Atoi(os.Getenv("COLUMNS")); err == nil && columns > 0 {
+		return columns
+	}
+
+	return DefaultTextWidth
+}
+
+// Bits of the directions in which a cell of the drawing is connected to its neighbours
+const (
+	boxUp = 1 << iota
+	boxDown
+	boxLeft
+	boxRight
+)
+
+// Box-drawing characters, by the directions in which they connect
+var boxRunes = [16]rune{
+	' ', '│', '│', '│',
+	'─', '┘', '┐', '┤',
+	'─', '└', '┌', '├',
+	'─', '┴', '┬', '┼',
+}
+
+// Return the children of a node in the order in which they are drawn, by WriteText and WriteSVG
+func drawnChildren(node *Node, nbTips map[*Node]int, ladderize bool) []*Branch {
+	if !ladderize {
+		return node.Out
+	}
+
+	children := slices.Clone(node.Out)
+	slices.SortStableFunc(children, func(a, b *Branch) int {
+		return cmp.Compare(nbTips[a.Child], nbTips[b.Child])
+	})
+
+	return children
+}
+
+// Format a branch length or a support value in a few characters
+func formatTextPlotValue(x float64) string {
+	return strconv.FormatFloat(x, 'g', 3, 64)
+}
+
+// Return a round length (1, 2 or 5 times a power of 10) close to x, for scale bars
+func roundScaleLength(x float64) float64 {
+	magnitude := math.Pow(10, math.Floor(math.Log10(x)))
+	for _, k := range []float64{1, 2, 5} {
+		if x <= 1.5*k*magnitude {
+			return k * magnitude
+		}
+	}
+
+	return 10 * magnitude
+}
+
+/*
+Draw the tree with box-drawing characters, from the root on the left to the tips on the right, with one line per tip.
+
+Phylograms (the default) draw the branches in proportion to their lengths, with a scale bar below the tree. Negative
+lengths are drawn as 0, and the tree is drawn as a cladogram if a branch has no length. Branches are always at least
+one character long, so that the topology stays readable. Tip labels that do not fit in half of the width are
+shortened.
+*/
+func (tree *Tree) WriteText(w io.Writer, opts TextPlotOptions) error {
+	if tree.Root == nil {
+		return errors.New("the tree has no root")
+	}
+
+	width := opts.Width
+	if width <= 0 {
+		width = TerminalWidth()
+	}
+	width = max(width, minTextWidth)
+
+	nbTips := make(map[*Node]int, len(tree.Nodes))
+	height := make(map[*Node]int, len(tree.Nodes)) // Number of branches to the farthest tip
+	cladogram := opts.Cladogram
+	maxLabelWidth := 0
+	tree.TraverseNodes(func(node *Node) {
+		if node.IsOuter() {
+			nbTips[node] = 1
+			maxLabelWidth = max(maxLabelWidth, utf8.RuneCountInString(node.Label))
+			return
+		}
+		for _, branch := range node.Out {
+			nbTips[node] += nbTips[branch.Child]
+			height[node] = max(height[node], height[branch.Child]+1)
+			if math.IsNaN(branch.Length) {
+				cladogram = true
+			}
+		}
+	}, PostOrder)
+
+	labelWidth := min(maxLabelWidth, width/2)
+	plotWidth := width - labelWidth - 2 // Columns of the branches, without the space before the labels
+
+	// Rows: the tips in drawing order, and each inner node halfway between its first and last children
+	row := make(map[*Node]int, len(tree.Nodes))
+	nbRows := 0
+	var placeRows func(node *Node)
+	placeRows = func(node *Node) {
+		if node.IsOuter() {
+			row[node] = nbRows
+			nbRows += 1
+			return
+		}
+		children := drawnChildren(node, nbTips, opts.Ladderize)
+		for _, branch := range children {
+			placeRows(branch.Child)
+		}
+		row[node] = (row[children[0].Child] + row[children[len(children)-1].Child]) / 2
+	}
+	placeRows(tree.Root)
+
+	// Columns: the depth of the nodes, scaled to the width, from column 1 (column 0 is the stub of the root)
+	depth := make(map[*Node]float64, len(tree.Nodes))
+	maxDepth := 0.0
+	tree.TraverseNodes(func(node *Node) {
+		if node.In != nil {
+			depth[node] = depth[node.In.Parent] + max(node.In.Length, 0.0)
+		}
+		maxDepth = max(maxDepth, depth[node])
+	}, PreOrder)
+	if maxDepth == 0.0 {
+		cladogram = true
+	}
+
+	column := make(map[*Node]int, len(tree.Nodes))
+	scale := float64(plotWidth-1) / maxDepth // Columns per unit of length
+	tree.TraverseNodes(func(node *Node) {
+		var x int
+		if cladogram {
+			x = 1 + int(math.Round(float64(height[tree.Root]-height[node])/float64(max(height[tree.Root], 1))*float64(plotWidth-1)))
+		} else {
+			x = 1 + int(math.Round(depth[node]*scale))
+		}
+		if node.In != nil {
+			x = max(x, column[node.In.Parent]+2)
+		}
+		column[node] = x
+	}, PreOrder)
+
+	canvasWidth := 0
+	labels := make(map[*Node]string, nbRows)
+	for node := range nbTips {
+		if node.IsOuter() {
+			label := node.Label
+			if utf8.RuneCountInString(label) > labelWidth {
+				label = string([]rune(label)[:max(labelWidth-1, 0)]) + "…"
+			}
+			labels[node] = label
+			canvasWidth = max(canvasWidth, column[node]+2+utf8.RuneCountInString(label))
+		}
+	}
+
+	canvas := make([][]rune, nbRows)
+	for i := range canvas {
+		canvas[i] = []rune(strings.Repeat(" ", canvasWidth))
+	}
+	write := func(r int, x int, s string) {
+		for k, c := range []rune(s) {
+			canvas[r][x+k] = c
+		}
+	}
+
+	for x := range column[tree.Root] {
+		canvas[row[tree.Root]][x] = '─'
+	}
+	tree.TraverseNodes(func(node *Node) {
+		x := column[node]
+		r := row[node]
+		if node.IsOuter() {
+			canvas[r][x] = '─'
+			write(r, x+2, labels[node])
+			return
+		}
+
+		children := drawnChildren(node, nbTips, opts.Ladderize)
+		first, last := row[children[0].Child], row[children[len(children)-1].Child]
+		isChildRow := make(map[int]bool, len(children))
+		for _, branch := range children {
+			isChildRow[row[branch.Child]] = true
+		}
+		for k := first; k <= last; k++ {
+			directions := 0
+			if k > first {
+				directions |= boxUp
+			}
+			if k < last {
+				directions |= boxDown
+			}
+			if k == r {
+				directions |= boxLeft
+			}
+			if isChildRow[k] {
+				directions |= boxRight
+			}
+			canvas[k][x] = boxRunes[directions]
+		}
+
+		// Branches to the children, with their values where they fit
+		for _, branch := range children {
+			child := branch.Child
+			start, end := x+1, column[child]-1 // Columns of the horizontal line
+			if child.IsOuter() {
+				end = column[child]
+			}
+			k := row[child]
+			for c := start; c <= end; c++ {
+				canvas[k][c] = '─'
+			}
+
+			free := end - start + 1
+			if opts.Support && child.IsInner() && !math.IsNaN(child.Support) {
+				s := formatTextPlotValue(child.Support)
+				if len(s)+1 <= free {
+					write(k, end-len(s)+1, s)
+					free -= len(s) + 1
+				}
+			}
+			if opts.Lengths && !math.IsNaN(branch.Length) {
+				s := formatTextPlotValue(branch.Length)
+				if len(s)+2 <= free {
+					write(k, start+1, s)
+				}
+			}
+		}
+	}, PreOrder)
+
+	for _, line := range canvas {
+		if _, err := io.WriteString(w, strings.TrimRight(string(line), " ")+"\n"); err != nil {
+			return err
+		}
+	}
+
+	if cladogram {
+		return nil
+	}
+
+	// Scale bar of about a fifth of the width of the branches
+	length := roundScaleLength(float64(plotWidth-1) / 5 / scale)
+	nbColumns := max(int(math.Round(length*scale)), 2)
+	bar := " ├" + strings.Repeat("─", nbColumns-1) + "┤ " + formatTextPlotValue(length) + "\n"
+	_, err := io.WriteString(w, "\n"+bar)
+
+	return err
+}
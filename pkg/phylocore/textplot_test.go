@@ -0,0 +1,80 @@
+package phylocore
+
+import (
+	"strings"
+	"testing"
+)
+
+const textPlotNewick = "((a:1,b:2)ab:1,(c:1,(d:3,f:0.5)df:0.2):2,e:1);"
+
+func TestWriteTextPhylogram(t *testing.T) {
+	tree, _, err := readNewickString(textPlotNewick)
+	if err != nil {
+		t.Fatal(err)
+	}
+	nodes := nodesByLabel(tree)
+	nodes["ab"].Support = 90
+	nodes["df"].Support = 75
+
+	var b strings.Builder
+	if err := tree.WriteText(&b, TextPlotOptions{Width: 40, Lengths: true, Support: true}); err != nil {
+		t.Fatal(err)
+	}
+	want := ` ┌─1──90┬─1───── a
+ │      └─2──────────── b
+─┼─2───────────┬─1───── c
+ │             └─┬─3────────────────── d
+ │               └─── f
+ └─1───── e
+
+ ├──────┤ 1
+`
+	if b.String() != want {
+		t.Errorf("phylogram:\n%s\nwant:\n%s", b.String(), want)
+	}
+}
+
+func TestWriteTextCladogram(t *testing.T) {
+	tree, _, _ := readNewickString(textPlotNewick)
+
+	var b strings.Builder
+	if err := tree.WriteText(&b, TextPlotOptions{Width: 40, Cladogram: true, Ladderize: true}); err != nil {
+		t.Fatal(err)
+	}
+	want := ` ┌──────────────────────────────────── e
+─┼───────────────────────┬──────────── a
+ │                       └──────────── b
+ └───────────┬──────────────────────── c
+             └───────────┬──────────── d
+                         └──────────── f
+`
+	if b.String() != want {
+		t.Errorf("ladderized cladogram:\n%s\nwant:\n%s", b.String(), want)
+	}
+
+	// Drawing does not change the order of the children
+	if tree.NewickString() != textPlotNewick {
+		t.Errorf("the tree was modified: %s", tree.NewickString())
+	}
+}
+
+func TestWriteTextLabels(t *testing.T) {
+	tree, _, _ := readNewickString("(a_very_long_taxon_name_indeed,(b,c));")
+
+	var b strings.Builder
+	if err := tree.WriteText(&b, TextPlotOptions{Width: 30}); err != nil {
+		t.Fatal(err)
+	}
+	lines := strings.Split(strings.TrimSuffix(b.String(), "\n"), "\n")
+	if len(lines) != 3 {
+		t.Fatalf("expected 3 lines (no scale bar without lengths), got:\n%s", b.String())
+	}
+	if !strings.HasSuffix(lines[0], " a_very_long_ta…") {
+		t.Errorf("long label not shortened: %q", lines[0])
+	}
+	for _, line := range lines {
+		if n := len([]rune(line)); n > 30 {
+			t.Errorf("line of %d characters: %q", n, line)
+		}
+	}
+}
@@ -3,14 +3,17 @@ package phylocore
 import (
 	"fmt"
 	"math"
+	"slices"
 )
 
 type Node struct {
-	Id      int
-	TaxonId int
-	Label   string
-	In      *Branch
-	Out     []*Branch
+	Id         int
+	TaxonId    int
+	Label      string
+	In         *Branch
+	Out        []*Branch
+	Support    float64           // Support value of the clade, NaN if unknown
+	Properties map[string]string // Arbitrary metadata, written out by the XML tree formats
 }
 
 type NodeGroup int
@@ -54,6 +57,16 @@ func (node *Node) Degree() int {
 	return node.InDegree() + node.OutDegree()
 }
 
+/*
+Set a metadata property of the node
+*/
+func (node *Node) SetProperty(key string, value string) {
+	if node.Properties == nil {
+		node.Properties = make(map[string]string)
+	}
+	node.Properties[key] = value
+}
+
 /*
 Check whether the node is "inner" (also called "internal"), i.e. it has children
 */
@@ -134,7 +147,7 @@ func MakeUnassembledTreePhylip(nbNode int) *Tree {
 	branches := make([]*Branch, 0, nbBranch)
 
 	for i := range nbNode {
-		nodes[i] = &Node{i, -1, "", nil, make([]*Branch, 0, 3)}
+		nodes[i] = &Node{i, -1, "", nil, make([]*Branch, 0, 3), math.NaN(), nil}
 	}
 
 	return &Tree{
@@ -158,7 +171,7 @@ func (tree *Tree) NewBranch() *Branch {
 Create a new node in the tree. Use this function to ensure a valid ID is assigned to the node.
 */
 func (tree *Tree) NewNode() *Node {
-	newNode := &Node{len(tree.Nodes), -1, "", nil, make([]*Branch, 0, 3)}
+	newNode := &Node{len(tree.Nodes), -1, "", nil, make([]*Branch, 0, 3), math.NaN(), nil}
 	tree.Nodes = append(tree.Nodes, newNode)
 
 	return newNode
@@ -220,3 +233,106 @@ func (branch *Branch) JoinChild(node *Node) {
 	branch.Child = node
 	node.In = branch
 }
+
+/*
+Attach taxa with the same sequence as the taxon of a tip, as zero-length sister tips.
+
+The tip is replaced by an inner node on the same branch, with the tip and new tips for the identical taxa as
+children (a polytomy when there are several identical taxa). The new taxa are added to the taxon set.
+*/
+func (tree *Tree) AddIdenticalTaxa(taxset *TaxonSet, taxonId int, names []string) error {
+	var tip *Node
+	for _, node := range tree.Nodes {
+		if node.TaxonId == taxonId && node.IsOuter() {
+			tip = node
+			break
+		}
+	}
+	if tip == nil {
+		return fmt.Errorf("no tip for taxon %d in the tree", taxonId)
+	}
+	seen := make(map[string]bool, len(names))
+	for _, name := range names {
+		if _, ok := taxset.GetId(name); ok || seen[name] {
+			return fmt.Errorf("%w \"%s\"", ErrDuplicateName, name)
+		}
+		seen[name] = true
+	}
+	if len(names) == 0 {
+		return nil
+	}
+
+	group := tree.NewNode()
+	if tip.In == nil {
+		tree.Root = group
+	} else {
+		tip.In.JoinChild(group)
+		tip.In = nil
+	}
+
+	zeroBranch := func() *Branch {
+		branch := tree.NewBranch()
+		branch.Length = 0.0
+		return branch
+	}
+
+	group.AddChild(tip, zeroBranch())
+	for _, name := range names {
+		node := tree.NewNode()
+		node.TaxonId, _ = taxset.NewTaxon(name) // The names were checked above
+		node.Label = name
+		group.AddChild(node, zeroBranch())
+	}
+
+	return nil
+}
+
+/*
+Root the tree on one of its nodes, as if the tree was unrooted.
+
+The branches on the path from the node to the former root are turned around, and the support values of the clades
+move with their branches. If the former root is left with a single child, it is removed and its two branches are
+merged into one, with the sum of their lengths, so that rooting the tree back does not add a node.
+*/
+func (tree *Tree) Reroot(node *Node) {
+	if node == tree.Root {
+		return
+	}
+	oldRoot := tree.Root
+
+	path := []*Node{node} // From the node up to the former root
+	for top := node; top.In != nil; top = top.In.Parent {
+		path = append(path, top.In.Parent)
+	}
+	branches := make([]*Branch, len(path)-1)
+	supports := make([]float64, len(path)-1)
+	for i := range branches {
+		branches[i] = path[i].In
+		supports[i] = path[i].Support
+		branches[i].Separate()
+	}
+	for i, branch := range branches {
+		path[i].AddChild(path[i+1], branch)
+		path[i+1].Support = supports[i]
+	}
+	node.Support = math.NaN()
+	tree.Root = node
+
+	if oldRoot.OutDegree() != 1 {
+		return
+	}
+	in, out := oldRoot.In, oldRoot.Out[0]
+	child := out.Child
+	out.Separate()
+	in.SeparateChild()
+	in.JoinChild(child)
+	in.Length += out.Length
+	tree.Nodes = slices.DeleteFunc(tree.Nodes, func(n *Node) bool { return n == oldRoot })
+	tree.Branches = slices.DeleteFunc(tree.Branches, func(b *Branch) bool { return b == out })
+	for i, n := range tree.Nodes {
+		n.Id = i
+	}
+	for i, b := range tree.Branches {
+		b.Id = i
+	}
+}
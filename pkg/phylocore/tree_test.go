@@ -0,0 +1,64 @@
+package phylocore
+
+import (
+	"ncdtree/pkg/ncd"
+	"testing"
+)
+
+func TestTree_AddIdenticalTaxa(t *testing.T) {
+	tree, taxset, err := readNewickString("((a:1,b:2):3,c:4);")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if err := tree.AddIdenticalTaxa(taxset, 1, []string{"b2", "b3"}); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if err := tree.AddIdenticalTaxa(taxset, 2, []string{"c2"}); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if err := tree.AddIdenticalTaxa(taxset, 0, nil); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	want := "((a:1,(b:0,b2:0,b3:0):2):3,(c:0,c2:0):4);"
+	if got := tree.NewickString(); got != want {
+		t.Errorf("got %s, want %s", got, want)
+	}
+	if id, ok := taxset.GetId("c2"); !ok || nodesByLabel(tree)["c2"].TaxonId != id {
+		t.Errorf("taxon c2 not added to the taxon set")
+	}
+
+	if err := tree.AddIdenticalTaxa(taxset, 0, []string{"c"}); err == nil {
+		t.Errorf("expected error for duplicate name, got nil")
+	}
+	if err := tree.AddIdenticalTaxa(taxset, 42, []string{"x"}); err == nil {
+		t.Errorf("expected error for missing taxon, got nil")
+	}
+}
+
+func TestTree_AddIdenticalTaxa_SingleTip(t *testing.T) {
+	tree, taxset, _ := readNewickString("a;")
+	if err := tree.AddIdenticalTaxa(taxset, 0, []string{"a2"}); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if got := tree.NewickString(); got != "(a:0,a2:0);" {
+		t.Errorf("got %s, want (a:0,a2:0);", got)
+	}
+}
+
+func TestTree_AddIdenticalTaxa_TwoTaxa(t *testing.T) {
+	taxset, _ := NewTaxonSet([]string{"a", "b"})
+	D := ncd.NewTriangularMatrix(2)
+	D.Set(1, 0, 0.5)
+	tree, err := NeighbourJoining(taxset, D)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := tree.AddIdenticalTaxa(taxset, 0, []string{"a2"}); err != nil {
+		t.Fatal(err)
+	}
+	if got, want := tree.NewickString(), "((a:0,a2:0):0.25,b:0.25);"; got != want {
+		t.Errorf("got %s, want %s", got, want)
+	}
+}
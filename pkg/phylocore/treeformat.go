@@ -0,0 +1,102 @@
+package phylocore
+
+import (
+	"bufio"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"strconv"
+)
+
+const (
+	xmlSchemaInstanceNamespace = "http://www.w3.org/2001/XMLSchema-instance"
+	xmlSchemaNamespace         = "http://www.w3.org/2001/XMLSchema#"
+)
+
+// File format for reading and writing trees
+type TreeFormat int
+
+const (
+	FormatNewick TreeFormat = iota
+	FormatPhyloXML
+	FormatNeXML
+)
+
+// Names of the tree formats, in the order of the TreeFormat constants
+var TreeFormatNames = []string{"newick", "phyloxml", "nexml"}
+
+func (format TreeFormat) String() string {
+	return TreeFormatNames[format]
+}
+
+// Return the usual file extension of the tree format, without the leading dot
+func (format TreeFormat) Extension() string {
+	switch format {
+	case FormatPhyloXML:
+		return "phyloxml"
+	case FormatNeXML:
+		return "nexml"
+	default:
+		return "nwk"
+	}
+}
+
+// Get the tree format that matches a name in TreeFormatNames
+func ParseTreeFormat(name string) (TreeFormat, error) {
+	for i, s := range TreeFormatNames {
+		if s == name {
+			return TreeFormat(i), nil
+		}
+	}
+
+	return FormatNewick, fmt.Errorf("unknown tree format \"%s\"", name)
+}
+
+/*
+Write the tree in the given format.
+
+The taxon set is only used by formats that describe taxa separately from the tree (NeXML), and can be nil.
+*/
+func (tree *Tree) Write(w io.Writer, taxset *TaxonSet, format TreeFormat) error {
+	switch format {
+	case FormatPhyloXML:
+		return tree.WritePhyloXML(w)
+	case FormatNeXML:
+		return tree.WriteNeXML(w, taxset)
+	default:
+		_, err := fmt.Fprintln(w, tree.NewickString())
+		return err
+	}
+}
+
+// Read a tree in the given format, with taxa matching a given taxon set, optionally adding new taxa
+func (taxset *TaxonSet) ReadTree(r io.Reader, format TreeFormat, addNew bool) (*Tree, error) {
+	switch format {
+	case FormatPhyloXML:
+		return taxset.ReadPhyloXML(r, addNew)
+	case FormatNeXML:
+		return taxset.ReadNeXML(r, addNew)
+	default:
+		return taxset.ReadNewick(bufio.NewReader(r), addNew)
+	}
+}
+
+func writeXMLDocument(w io.Writer, doc any) error {
+	if _, err := io.WriteString(w, xml.Header); err != nil {
+		return err
+	}
+
+	encoder := xml.NewEncoder(w)
+	encoder.Indent("", "  ")
+	if err := encoder.Encode(doc); err != nil {
+		return err
+	}
+
+	_, err := io.WriteString(w, "\n")
+
+	return err
+}
+
+func formatXMLFloat(x float64) string {
+	return strconv.FormatFloat(x, 'g', -1, 64)
+}
@@ -0,0 +1,135 @@
+package phylocore
+
+import (
+	"bytes"
+	"math"
+	"strings"
+	"testing"
+)
+
+// annotatedTree returns a tree with a support value and a property on the inner node labelled "x".
+func annotatedTree(t *testing.T) (*Tree, *TaxonSet) {
+	tree, taxset, err := readNewickString("((A:1,B:2)x:3,C:4,D);")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	x := nodesByLabel(tree)["x"]
+	x.Support = 0.95
+	x.SetProperty("colour", "red")
+
+	return tree, taxset
+}
+
+// TestTreeFormats_RoundTrip writes a tree in each XML format and reads it back.
+func TestTreeFormats_RoundTrip(t *testing.T) {
+	for _, format := range []TreeFormat{FormatPhyloXML, FormatNeXML} {
+		tree, taxset := annotatedTree(t)
+
+		var buf bytes.Buffer
+		if err := tree.Write(&buf, taxset, format); err != nil {
+			t.Fatalf("%s: unexpected write error: %v", format, err)
+		}
+
+		newTaxset, _ := NewTaxonSet(make([]string, 0))
+		got, err := newTaxset.ReadTree(&buf, format, true)
+		if err != nil {
+			t.Fatalf("%s: unexpected read error: %v", format, err)
+		}
+
+		if got.NewickString() != tree.NewickString() {
+			t.Errorf("%s: round-trip got %q, want %q", format, got.NewickString(), tree.NewickString())
+		}
+		if newTaxset.Len() != 4 {
+			t.Errorf("%s: taxset.Len() = %d, want 4", format, newTaxset.Len())
+		}
+
+		x, ok := nodesByLabel(got)["x"]
+		if !ok {
+			t.Fatalf("%s: inner node x not found", format)
+		}
+		if x.Support != 0.95 {
+			t.Errorf("%s: x.Support = %g, want 0.95", format, x.Support)
+		}
+		if x.Properties["colour"] != "red" {
+			t.Errorf("%s: x.Properties[\"colour\"] = %q, want \"red\"", format, x.Properties["colour"])
+		}
+		if x.TaxonId != -1 {
+			t.Errorf("%s: x.TaxonId = %d, want -1", format, x.TaxonId)
+		}
+
+		a := nodesByLabel(got)["A"]
+		if !math.IsNaN(a.Support) {
+			t.Errorf("%s: A.Support = %g, want NaN", format, a.Support)
+		}
+	}
+}
+
+// TestWriteNeXML_NilTaxset checks that OTUs are made from the outer node labels when no taxon set is given.
+func TestWriteNeXML_NilTaxset(t *testing.T) {
+	tree, _ := annotatedTree(t)
+
+	var buf bytes.Buffer
+	if err := tree.WriteNeXML(&buf, nil); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if n := strings.Count(buf.String(), "<otu "); n != 4 {
+		t.Errorf("got %d OTUs, want 4", n)
+	}
+}
+
+// TestWritePhyloXML_Rooted checks that the rooted attribute reflects a bifurcating root.
+func TestWritePhyloXML_Rooted(t *testing.T) {
+	cases := []struct {
+		input string
+		want  string
+	}{
+		{"((A,B),C);", `rooted="true"`},
+		{"(A,B,C);", `rooted="false"`},
+	}
+	for _, tc := range cases {
+		tree, _, err := readNewickString(tc.input)
+		if err != nil {
+			t.Fatalf("%q: unexpected error: %v", tc.input, err)
+		}
+		var buf bytes.Buffer
+		if err := tree.WritePhyloXML(&buf); err != nil {
+			t.Fatalf("%q: unexpected error: %v", tc.input, err)
+		}
+		if !strings.Contains(buf.String(), tc.want) {
+			t.Errorf("%q: output does not contain %s", tc.input, tc.want)
+		}
+	}
+}
+
+// TestReadTreeFormats_Errors verifies that malformed documents return errors.
+func TestReadTreeFormats_Errors(t *testing.T) {
+	cases := []struct {
+		input  string
+		format TreeFormat
+		desc   string
+	}{
+		{"<phyloxml></phyloxml>", FormatPhyloXML, "no phylogeny"},
+		{"<phyloxml><phylogeny>", FormatPhyloXML, "truncated document"},
+		{"<nexml></nexml>", FormatNeXML, "no tree"},
+		{`<nexml><trees><tree><node id="n0"/><edge id="e0" source="n0" target="n1"/></tree></trees></nexml>`, FormatNeXML, "unknown edge target"},
+	}
+	for _, tc := range cases {
+		taxset, _ := NewTaxonSet(make([]string, 0))
+		_, err := taxset.ReadTree(strings.NewReader(tc.input), tc.format, true)
+		if err == nil {
+			t.Errorf("%s: expected error, got nil", tc.desc)
+		}
+	}
+}
+
+func TestParseTreeFormat(t *testing.T) {
+	for i, name := range TreeFormatNames {
+		format, err := ParseTreeFormat(name)
+		if err != nil || format != TreeFormat(i) {
+			t.Errorf("ParseTreeFormat(%q) = (%v, %v), want (%v, nil)", name, format, err, TreeFormat(i))
+		}
+	}
+	if _, err := ParseTreeFormat("nexus"); err == nil {
+		t.Errorf("ParseTreeFormat(\"nexus\"): expected error, got nil")
+	}
+}
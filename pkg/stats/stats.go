@@ -1,6 +1,8 @@
 package stats
 
 import (
+	"cmp"
+	"errors"
 	"fmt"
 	"math"
 	"slices"
@@ -10,6 +12,14 @@ type Numeric interface {
 	~int | ~float64
 }
 
+var (
+	// A statistic is undefined for an empty slice
+	ErrEmpty = errors.New("no values")
+
+	// Two variables have different numbers of values
+	ErrLengthMismatch = errors.New("variables of different lengths")
+)
+
 // Computes the variance of a slice of numeric values (int or float64)
 func Variance[T Numeric](X *[]T, sample bool) float64 {
 	n := len(*X)
@@ -39,13 +49,14 @@ func StandardDeviation[T Numeric](X *[]T, sample bool) float64 {
 	return math.Sqrt(Variance(X, sample))
 }
 
-func Covariance[T Numeric](X *[]T, Y *[]T, sample bool) float64 {
+// Computes the covariance of two variables, which must have the same number of values (ErrLengthMismatch otherwise)
+func Covariance[T Numeric](X *[]T, Y *[]T, sample bool) (float64, error) {
 	if len(*X) != len(*Y) {
-		panic(fmt.Sprintf("cannot compute covariance between vectors of different lengths: %d and %d", len(*X), len(*Y)))
+		return 0.0, fmt.Errorf("%w: %d and %d", ErrLengthMismatch, len(*X), len(*Y))
 	}
 	n := len(*X)
 	if n < 2 {
-		return 0
+		return 0, nil
 	}
 
 	var meanX, meanY, C float64
@@ -64,18 +75,62 @@ func Covariance[T Numeric](X *[]T, Y *[]T, sample bool) float64 {
 	if sample {
 		denom -= 1.0
 	}
-	return C / denom
+	return C / denom, nil
 }
 
-// Return the Pearson correlation coefficient between two variables
-func CorrPearson[T Numeric](X *[]T, Y *[]T, sample bool) float64 {
-	rho := Covariance(X, Y, sample)
+// Return the Pearson correlation coefficient between two variables (NaN if a variable is constant)
+func CorrPearson[T Numeric](X *[]T, Y *[]T, sample bool) (float64, error) {
+	rho, err := Covariance(X, Y, sample)
+	if err != nil {
+		return 0.0, err
+	}
 	rho /= StandardDeviation(X, sample) * StandardDeviation(Y, sample)
 
 	rho = min(rho, 1.0)
 	rho = max(rho, -1.0)
 
-	return rho
+	return rho, nil
+}
+
+/*
+Return the ranks of numeric values (int or float64), from 1 for the smallest. Tied values get the mean of their ranks.
+*/
+func Ranks[T Numeric](X *[]T) []float64 {
+	n := len(*X)
+	order := make([]int, n)
+	for i := range order {
+		order[i] = i
+	}
+	slices.SortStableFunc(order, func(a, b int) int {
+		return cmp.Compare((*X)[a], (*X)[b])
+	})
+
+	ranks := make([]float64, n)
+	for start := 0; start < n; {
+		end := start + 1
+		for end < n && (*X)[order[end]] == (*X)[order[start]] {
+			end += 1
+		}
+		// Ranks start+1 to end are tied
+		rank := float64(start+1+end) / 2.0
+		for _, i := range order[start:end] {
+			ranks[i] = rank
+		}
+		start = end
+	}
+
+	return ranks
+}
+
+// Return the Spearman rank correlation coefficient between two variables, the Pearson correlation of their ranks
+func CorrSpearman[T Numeric](X *[]T, Y *[]T) (float64, error) {
+	if len(*X) != len(*Y) {
+		return 0.0, fmt.Errorf("%w: %d and %d", ErrLengthMismatch, len(*X), len(*Y))
+	}
+	rankX := Ranks(X)
+	rankY := Ranks(Y)
+
+	return CorrPearson(&rankX, &rankY, false)
 }
 
 // Computes the sum of a slice of float64 values using Kahan's compensation algorithm
@@ -108,30 +163,30 @@ func MeanInt(X *[]int) float64 {
 	return float64(xSum) / float64(len(*X))
 }
 
-// Computes the median of a slice of numeric values (int or float64)
-func Median[T Numeric](X *[]T) float64 {
+// Computes the median of a slice of numeric values (int or float64), which fails with ErrEmpty if it is empty
+func Median[T Numeric](X *[]T) (float64, error) {
 	if len((*X)) == 0 {
-		panic("cannot compute median of an empty array")
+		return 0.0, fmt.Errorf("cannot compute the median: %w", ErrEmpty)
 	}
 	tmp := slices.Clone(*X)
 	slices.Sort(tmp)
 
 	n := len(tmp)
 	if n%2 == 0 {
-		return (float64(tmp[(n/2)-1]) + float64(tmp[n/2])) / 2.0
+		return (float64(tmp[(n/2)-1]) + float64(tmp[n/2])) / 2.0, nil
 	} else {
-		return float64(tmp[n/2])
+		return float64(tmp[n/2]), nil
 	}
 }
 
-// Returns the minimum value in a slice of int or float values
-func Minimum[T Numeric](X *[]T) T {
+// Returns the minimum value in a slice of int or float values, which fails with ErrEmpty if it is empty
+func Minimum[T Numeric](X *[]T) (T, error) {
 	N := len(*X)
 	if N == 0 {
-		panic("cannot compute minimum of an empty array")
+		return 0, fmt.Errorf("cannot compute the minimum: %w", ErrEmpty)
 	}
 	if N == 1 {
-		return (*X)[0]
+		return (*X)[0], nil
 	}
 	minX := (*X)[0]
 
@@ -141,17 +196,17 @@ func Minimum[T Numeric](X *[]T) T {
 		}
 	}
 
-	return minX
+	return minX, nil
 }
 
-// Returns the maximum value in a slice of int or float values
-func Maximum[T Numeric](X *[]T) T {
+// Returns the maximum value in a slice of int or float values, which fails with ErrEmpty if it is empty
+func Maximum[T Numeric](X *[]T) (T, error) {
 	N := len(*X)
 	if N == 0 {
-		panic("cannot compute maximum of an empty array")
+		return 0, fmt.Errorf("cannot compute the maximum: %w", ErrEmpty)
 	}
 	if N == 1 {
-		return (*X)[0]
+		return (*X)[0], nil
 	}
 	maxX := (*X)[0]
 
@@ -161,5 +216,23 @@ func Maximum[T Numeric](X *[]T) T {
 		}
 	}
 
-	return maxX
+	return maxX, nil
+}
+
+/*
+Computes the median absolute deviation from the median of a slice of numeric values (int or float64), a robust
+measure of spread. Multiply it by 1.4826 to estimate the standard deviation of normally distributed values. Fails with
+ErrEmpty if the slice is empty.
+*/
+func MedianAbsoluteDeviation[T Numeric](X *[]T) (float64, error) {
+	m, err := Median(X)
+	if err != nil {
+		return 0.0, err
+	}
+	deviations := make([]float64, len(*X))
+	for i, x := range *X {
+		deviations[i] = math.Abs(float64(x) - m)
+	}
+
+	return Median(&deviations)
 }
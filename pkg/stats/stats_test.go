@@ -0,0 +1,94 @@
+package stats
+
+import (
+	"errors"
+	"math"
+	"testing"
+)
+
+func TestMedian(t *testing.T) {
+	X := []int{5, 1, 4, 2}
+	if m, err := Median(&X); err != nil || m != 3.0 {
+		t.Errorf("Median = %v, %v, want 3", m, err)
+	}
+	Y := []float64{0.5, 0.1, 0.3}
+	if m, err := Median(&Y); err != nil || m != 0.3 {
+		t.Errorf("Median = %v, %v, want 0.3", m, err)
+	}
+
+	empty := []float64{}
+	if _, err := Median(&empty); !errors.Is(err, ErrEmpty) {
+		t.Errorf("expected ErrEmpty, got %v", err)
+	}
+}
+
+func TestMinimumMaximum(t *testing.T) {
+	X := []int{3, -1, 7, 2}
+	if m, err := Minimum(&X); err != nil || m != -1 {
+		t.Errorf("Minimum = %v, %v, want -1", m, err)
+	}
+	if m, err := Maximum(&X); err != nil || m != 7 {
+		t.Errorf("Maximum = %v, %v, want 7", m, err)
+	}
+
+	empty := []int{}
+	if _, err := Minimum(&empty); !errors.Is(err, ErrEmpty) {
+		t.Errorf("expected ErrEmpty, got %v", err)
+	}
+	if _, err := Maximum(&empty); !errors.Is(err, ErrEmpty) {
+		t.Errorf("expected ErrEmpty, got %v", err)
+	}
+}
+
+func TestCorrPearson(t *testing.T) {
+	X := []float64{1, 2, 3, 4}
+	Y := []float64{2, 4, 6, 8}
+	if r, err := CorrPearson(&X, &Y, true); err != nil || math.Abs(r-1.0) > 1e-12 {
+		t.Errorf("CorrPearson = %v, %v, want 1", r, err)
+	}
+
+	Z := []float64{1, 2, 3}
+	if _, err := CorrPearson(&X, &Z, true); !errors.Is(err, ErrLengthMismatch) {
+		t.Errorf("expected ErrLengthMismatch, got %v", err)
+	}
+}
+
+func TestMedianAbsoluteDeviation(t *testing.T) {
+	X := []float64{1, 1, 2, 2, 4, 6, 9}
+	if mad, err := MedianAbsoluteDeviation(&X); err != nil || mad != 1.0 {
+		t.Errorf("MedianAbsoluteDeviation = %v, %v, want 1", mad, err)
+	}
+
+	empty := []int{}
+	if _, err := MedianAbsoluteDeviation(&empty); !errors.Is(err, ErrEmpty) {
+		t.Errorf("expected ErrEmpty, got %v", err)
+	}
+}
+
+func TestRanks(t *testing.T) {
+	X := []float64{0.3, 0.1, 0.3, 0.2, 0.3}
+	want := []float64{4, 1, 4, 2, 4}
+	got := Ranks(&X)
+	for i := range want {
+		if got[i] != want[i] {
+			t.Fatalf("Ranks = %v, want %v", got, want)
+		}
+	}
+}
+
+func TestCorrSpearman(t *testing.T) {
+	// Monotonic but not linear
+	X := []float64{1, 2, 3, 4, 5}
+	Y := []float64{1, 4, 9, 16, 100}
+	if r, err := CorrSpearman(&X, &Y); err != nil || math.Abs(r-1.0) > 1e-12 {
+		t.Errorf("CorrSpearman = %v, %v, want 1", r, err)
+	}
+	Z := []float64{5, 4, 3, 2, 1}
+	if r, err := CorrSpearman(&X, &Z); err != nil || math.Abs(r+1.0) > 1e-12 {
+		t.Errorf("CorrSpearman = %v, %v, want -1", r, err)
+	}
+	short := []float64{1, 2}
+	if _, err := CorrSpearman(&X, &short); !errors.Is(err, ErrLengthMismatch) {
+		t.Errorf("expected ErrLengthMismatch, got %v", err)
+	}
+}
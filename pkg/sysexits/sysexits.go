@@ -0,0 +1,57 @@
+/*
+Exit codes of the command-line tools, after the sysexits.h convention of BSD.
+
+	 0  Success
+	64  Usage: invalid arguments or options
+	65  Data error: invalid input data (malformed file, duplicate taxa, too few taxa...)
+	66  No input: an input file does not exist or cannot be read
+	70  Software: internal error (a bug)
+	73  Can't create: an output file cannot be created
+	74  I/O error: an error while reading or writing data
+*/
+package sysexits
+
+import (
+	"errors"
+	"io/fs"
+	"os"
+	"strings"
+)
+
+const (
+	OK         = 0
+	Usage      = 64
+	DataErr    = 65
+	NoInput    = 66
+	Software   = 70
+	CantCreate = 73
+	IOErr      = 74
+)
+
+// Print an error on stderr and exit with a code
+func Exit(err error, code int) {
+	os.Stderr.WriteString(err.Error() + "\n")
+	os.Exit(code)
+}
+
+// Print a message on stderr, followed by a newline if it does not end with one, and exit with a code
+func ExitMsg(msg string, code int) {
+	os.Stderr.WriteString(strings.TrimSuffix(msg, "\n") + "\n")
+	os.Exit(code)
+}
+
+/*
+Code of an error while reading an input: NoInput if a file does not exist or cannot be opened, IOErr for other
+errors of the file system, and DataErr otherwise (errors in the content of the input).
+*/
+func InputCode(err error) int {
+	var pathErr *fs.PathError
+	switch {
+	case errors.Is(err, fs.ErrNotExist) || errors.Is(err, fs.ErrPermission):
+		return NoInput
+	case errors.As(err, &pathErr):
+		return IOErr
+	}
+
+	return DataErr
+}